@@ -1,144 +1,140 @@
 package handlers
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/jobs"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/llm"
 	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
 	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Databases"
-	whoisparser "github.com/likexian/whois-parser"
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/config"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/safedial"
 
-	//scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
 	scraperHandler "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/handlers"
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
 )
 
 type AIHandler struct {
 	PostgreSQL *Databases.PostgreSQL
+	LLM        llm.Provider
+	Risk       *scraperModels.RiskEngine
+	Jobs       *jobs.Queue
+	// MaxBatchSize bounds the urls of one scan batch
+	MaxBatchSize int
+	Cache        models.CachePolicy
+	Crawler      scraperModels.CrawlerConfig
+	// Brands are the protected brands the scanned domain is compared against
+	Brands []scraperModels.Brand
+	// Dialer confines every connection to the scanned site to public addresses
+	Dialer *safedial.Dialer
+	// HTTP is the client used for the scanned site, it dials through Dialer
+	HTTP *http.Client
+	// RedirectHTTP dials through Dialer too but stops at every redirect
+	RedirectHTTP *http.Client
+	// DNS asks the configured resolver about the scanned domain
+	DNS *DNSClient
+	// Whois looks up the registration data of the scanned domain
+	Whois *WhoisService
+	// Enamad looks up the Enamad certificate of the scanned domain
+	Enamad *scraperHandler.EnamadClient
+	// Registries are the business registries the scanned site is looked up in, Enamad included
+	Registries []scraperHandler.RegistryChecker
 }
 
-func NewAIhandler(PostgreSQL *Databases.PostgreSQL) *AIHandler {
-
-	return &AIHandler{PostgreSQL: PostgreSQL}
+func NewAIhandler(PostgreSQL *Databases.PostgreSQL, provider llm.Provider, cfg *config.Config, dialer *safedial.Dialer) *AIHandler {
+
+	var whoisCache WhoisCache
+	var enamadCache scraperHandler.EnamadCache
+	if PostgreSQL != nil {
+		whoisCache = PostgreSQL
+		enamadCache = PostgreSQL
+	}
+
+	enamad := scraperHandler.NewEnamadClient(cfg.Enamad, enamadCache)
+
+	return &AIHandler{
+		PostgreSQL:   PostgreSQL,
+		LLM:          provider,
+		Risk:         cfg.Risk.Engine(),
+		Cache:        cfg.Cache,
+		MaxBatchSize: cfg.Scans.MaxBatchSize,
+		Crawler:      cfg.Crawler,
+		Brands:       cfg.Brands,
+		Dialer:       dialer,
+		HTTP:         dialer.Client(cfg.Crawler.RequestTimeout),
+		RedirectHTTP: redirectClient(dialer),
+		DNS:          NewDNSClient(cfg.DNS.Resolver, cfg.DNS.Timeout, cfg.DNS.CheckTimeout),
+		Whois:        NewWhoisService(cfg.Whois, whoisCache),
+		Enamad:       enamad,
+		Registries: []scraperHandler.RegistryChecker{
+			enamad,
+			scraperHandler.NewSamandehiClient(cfg.Samandehi),
+			scraperHandler.BusinessIDChecker{},
+		},
+	}
 
 }
 
+// ExtractMainDomain returns the registrable domain of rawURL, shop.co.ir for https://a.shop.co.ir/x
 func ExtractMainDomain(rawURL string) (string, error) {
-	// Parse the URL
-	u, err := url.Parse(rawURL)
+	site, err := scraperModels.NormalizeURL(rawURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse URL: %v", err)
-	}
-
-	// Get the hostname (removes port if present)
-	host := u.Hostname()
-
-	// Split the host into parts
-	parts := strings.Split(host, ".")
-	if len(parts) < 2 {
-		return "", fmt.Errorf("invalid domain: %s", host)
+		return "", err
 	}
-
-	if len(parts) > 2 {
-		// For most cases, take the last two parts
-		return parts[len(parts)-2] + "." + parts[len(parts)-1], nil
-	}
-
-	return host, nil
+	return site.Domain, nil
 }
 
-func checkDomainAge(whois whoisparser.WhoisInfo) int {
-
-	creationDate, err := time.Parse(time.RFC3339, whois.Domain.CreatedDate)
+// siteKey normalizes the url a client asked about into the key its scans are stored under
+func siteKey(rawURL string) (string, error) {
+	site, err := scraperModels.NormalizeURL(rawURL)
 	if err != nil {
-		log.Println(err)
+		return "", err
 	}
-
-	ageInDays := int(time.Since(creationDate).Hours() / 24)
-
-	return ageInDays
+	return site.Key, nil
 }
-func HostUp(site string) bool {
+
+// HostUp reports whether site answers 200 before ctx is done, over https unless site names
+// http, client must refuse internal addresses since site comes from the user
+func HostUp(ctx context.Context, client *http.Client, site string) bool {
 	// url, err := ExtractMainDomain(site)
 	// if err != nil {
 	// 	log.Printf("Extracting main domain went wrong %v", err)
 	// }
-	url := fmt.Sprintf("https://%s", site)
-	res, err := http.Get(url)
-	if err != nil {
-		log.Printf("Get request did't went right : %d", err)
-	}
-
-	if res.StatusCode == 200 {
-		return true
-	}
-	return false
-
-}
-
-func SendToAI(site string) models.CompletionResponse {
-
-	var response models.CompletionResponse
-	url := "https://openrouter.ai/api/v1/chat/completions"
-
-	err := godotenv.Load()
+	u, err := scraperModels.ParseSiteURL(site)
 	if err != nil {
-		log.Fatalln("Error loading .env file")
+		log.Printf("Parsing the url %s went wrong : %v", site, err)
+		return false
 	}
-	apiKey := os.Getenv("APIKEY")
-
-	//scraper_result := handlers.Do_scrape(site)
-
-	scraperData := scraperHandler.Do_scrape(site)
-	//urlObj, err := ExtractMainDomain(site)
-
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
-		log.Println(err)
+		log.Printf("Building the request for %s went wrong : %v", site, err)
+		return false
 	}
-
-	whoisData := Whois(site)
-	jsonWhoisData, err := json.MarshalIndent(whoisData, "", "  ")
+	res, err := client.Do(req)
 	if err != nil {
-		log.Printf("jsoning the whois data went wrong : %s", err)
+		log.Printf("Get request did't went right : %v", err)
+		return false
 	}
-	fmt.Println("this is whois data")
-	fmt.Println(string(jsonWhoisData))
-	scraperData["domain_age"] = checkDomainAge(whoisData)
+	defer res.Body.Close()
 
-	jsonScraperData, err := json.MarshalIndent(scraperData, "", "  ")
-	if err != nil {
-		log.Printf("marshaling the scraperData went wrong : %s \n", err)
+	if res.StatusCode == 200 {
+		return true
 	}
-	fmt.Println("this is scraper data:")
-	fmt.Println(string(jsonScraperData))
+	return false
 
-	Enamad, err := scraperHandler.Enamad_GetData(site)
-	if err != nil {
-		log.Printf("Enamd geting data in AI handler function went wrong : %s\n", err)
-	}
+}
 
-	jsonEnamad, err := json.MarshalIndent(Enamad, "", "  ")
-	if err != nil {
-		log.Printf("jasonizing the Enamad went wrotng the error is : %s \n", err)
-	}
-	fmt.Println("this is enamad data")
-	fmt.Println(string(jsonEnamad))
-	payload := map[string]interface{}{
-		"model": "qwen/qwq-32b",
-		"messages": []map[string]string{
-			{
-				"role": "system",
-				"content": `You are an expert website security analyst. Your task is to analyze websites for trustworthiness and reliability.
+// systemPrompt instructs the model how to score a website and which JSON to return
+const systemPrompt = `You are an expert website security analyst. Your task is to analyze websites for trustworthiness and reliability.
 
 ANALYSIS CRITERIA:
 1. Domain Trust Factors:
@@ -152,16 +148,19 @@ ANALYSIS CRITERIA:
    - Clear contact information and policies
    - Proper grammar and spelling
    - Absence of suspicious promises or urgency tactics
+   - No imitation of a known brand (lookalike domain, brand name in the title or logo of another domain)
 
 3. Technical Security:
    - Proper security headers implementation
    - Clean, non-obfuscated code
    - No suspicious redirects
+   - Stable hosting (no fast flux, no addresses shared with flagged sites) and mail policies (SPF, DMARC)
    - Secure content delivery
 
 4. Regional Compliance (Iran):
    - Enamad certification for Iranian websites
-   - Proper business registration verification
+   - Enamad and Samandehi badges on the page that open a certificate of this very domain (page_seals); a forged badge is a strong scam sign
+   - Proper business registration verification: Samandehi registration and a national business id with a valid checksum; a registration claimed on the site that does not hold (compliance issues) is a strong scam sign
 
 SCORING SYSTEM:
 - Calculate TRUST SCORE from 0-100 where:
@@ -207,84 +206,367 @@ IMPORTANT:
 - Focus on reliability indicators
 - Positive flags ADD to trust score
 - Negative flags SUBTRACT from trust score
-- If you change the JSON format and its structure you will make a great system unfunctional`,
-			},
-			{
-				"role": "user",
-				"content": fmt.Sprintf(`Analyze this website for trustworthiness and reliability:
+- If you change the JSON format and its structure you will make a great system unfunctional`
+
+// userPromptFormat is filled with the url, scraper, tls, redirect, dns, whois and compliance data
+const userPromptFormat = `Analyze this website for trustworthiness and reliability:
 
 URL: %s
 
 SCRAPED DATA:
 %s
 
+TLS CERTIFICATE:
+%s
+
+REDIRECTS AND CLOAKING:
+%s
+
+DNS AND HOSTING:
+%s
+
 WHOIS DATA:
 %s
 
-ENAMAD DATA:
+REGULATORY COMPLIANCE (ENAMAD, SAMANDEHI, NATIONAL BUSINESS ID):
 %s
 
-Provide a comprehensive trust analysis focusing on what makes this website reliable or unreliable. Score from 0 (very untrustworthy) to 100 (highly trustworthy).`, site, jsonScraperData, string(jsonWhoisData), string(jsonEnamad)),
-			},
-		},
+Provide a comprehensive trust analysis focusing on what makes this website reliable or unreliable. Score from 0 (very untrustworthy) to 100 (highly trustworthy).`
+
+func buildMessages(site string, scraperData, tlsData, redirectData, dnsData, whoisData, complianceData []byte) []llm.Message {
+	return []llm.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: fmt.Sprintf(userPromptFormat, site, scraperData, tlsData, redirectData, dnsData, whoisData, complianceData)},
 	}
+}
 
-	// conveting the payload to json
-	jsonPayload, err := json.Marshal(payload)
+// SendToAI collects the scan inputs, scores them with the rule engine and asks the model
+// for a verdict. When the model fails the rule engine verdict is returned instead.
+// events receives the progress of every stage, it may be nil.
+func (h *AIHandler) SendToAI(ctx context.Context, site string, events models.EventFunc) (*models.ScanResult, error) {
+	if events == nil {
+		events = func(models.ScanEvent) {}
+	}
 
+	events(models.StageEvent(models.StageScrape, models.StatusRunning, nil))
+	report := scraperHandler.Do_scrape(ctx, site, h.Crawler, h.Dialer, func(page scraperModels.PageResult) {
+		events(models.ScanEvent{Type: models.EventPage, Stage: models.StageScrape, Data: page})
+	})
+	if ctx.Err() != nil {
+		events(models.StageEvent(models.StageScrape, models.StatusFailed, ctx.Err()))
+		return nil, ctx.Err()
+	}
+	events(models.StageEvent(models.StageScrape, models.StatusDone, nil))
+
+	tlsReport := CheckTLS(ctx, h.Dialer, nil, site)
+	events(models.ScanEvent{Type: models.EventTLS, Data: tlsReport})
+	jsonTLS, err := json.MarshalIndent(tlsReport, "", "  ")
 	if err != nil {
-		fmt.Println("unable to marshal the payload", err)
+		log.Printf("marshaling the tls report went wrong : %v", err)
 	}
 
-	// creating a post request
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
+	redirects := CheckRedirects(ctx, h.RedirectHTTP, site)
+	events(models.ScanEvent{Type: models.EventRedirects, Data: redirects})
+	jsonRedirects, err := json.MarshalIndent(redirects, "", "  ")
 	if err != nil {
-		log.Fatalln(err)
+		log.Printf("marshaling the redirect report went wrong : %v", err)
 	}
-	fmt.Println(apiKey)
-	//req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
 
-	// sending the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	dnsReport := h.checkDNS(ctx, site)
+	events(models.ScanEvent{Type: models.EventDNS, Data: dnsReport})
+	jsonDNS, err := json.MarshalIndent(dnsReport, "", "  ")
 	if err != nil {
-		fmt.Println("Unable to send the request to the api", err)
+		log.Printf("marshaling the dns report went wrong : %v", err)
 	}
-	defer resp.Body.Close()
 
-	// read the response
-	body, err := io.ReadAll(resp.Body)
+	events(models.StageEvent(models.StageWhois, models.StatusRunning, nil))
+	// the model is told when the registration data is missing rather than getting empty data
+	var whoisData interface{}
+	whoisRecord, err := h.Whois.Lookup(ctx, site)
+	if err != nil {
+		log.Printf("Whois lookup of %s failed : %v", site, err)
+		events(models.StageEvent(models.StageWhois, models.StatusFailed, err))
+		whoisRecord = &models.WhoisRecord{Domain: site}
+		whoisData = map[string]string{"error": err.Error()}
+	} else {
+		events(models.ScanEvent{Type: models.EventWhois, Stage: models.StageWhois, Data: whoisRecord.Summary(time.Now())})
+		events(models.StageEvent(models.StageWhois, models.StatusDone, nil))
+		whoisData = whoisRecord
+	}
+	jsonWhoisData, err := json.MarshalIndent(whoisData, "", "  ")
 	if err != nil {
-		fmt.Println("unable to read the response body", err)
+		log.Printf("jsoning the whois data went wrong : %s", err)
 	}
+	domainAge := whoisRecord.DomainAge(time.Now())
 
-	// return body
-	//fmt.Println(string(body))
+	impersonation := scraperModels.DetectImpersonation(site, h.Brands, report.Site.Titles, report.Site.LogoTexts)
 
-	if err = json.Unmarshal(body, &response); err != nil {
-		fmt.Println("Unable to unmarshal the json", err)
+	// the model gets the site wide summary, the per page results are kept in the history
+	jsonScraperData, err := json.MarshalIndent(struct {
+		scraperModels.SiteSummary
+		DomainAge     int                                  `json:"domain_age"`
+		Impersonation []scraperModels.ImpersonationFinding `json:"impersonation"`
+	}{report.Site, domainAge, impersonation}, "", "  ")
+	if err != nil {
+		log.Printf("marshaling the scraperData went wrong : %s \n", err)
+	}
+
+	events(models.StageEvent(models.StageEnamad, models.StatusRunning, nil))
+	compliance := scraperHandler.CheckRegistries(ctx, h.Registries, scraperModels.RegistryQuery{
+		Site:        site,
+		Seals:       report.Site.TrustSeals,
+		BusinessIDs: report.Site.BusinessIDs,
+	})
+	// the model is told when a lookup failed through the error of its registry
+	var Enamad *scraperModels.Enamad_Data
+	var enamadErr error
+	if result := compliance.Result(scraperModels.RegistryEnamad); result != nil {
+		Enamad = result.Enamad
+		if result.Error != "" {
+			enamadErr = errors.New(result.Error)
+			log.Printf("Enamd geting data in AI handler function went wrong : %s\n", enamadErr)
+		} else {
+			events(models.ScanEvent{Type: models.EventEnamad, Stage: models.StageEnamad, Data: Enamad})
+		}
+	}
+	events(models.ScanEvent{Type: models.EventCompliance, Stage: models.StageEnamad, Data: compliance})
+	// the badges are only compared with the registered certificate when the lookup worked
+	seals := VerifyTrustSeals(ctx, h.HTTP, site, report.Site.TrustSeals, Enamad)
+	events(models.ScanEvent{Type: models.EventSeals, Stage: models.StageEnamad, Data: seals})
+	if enamadErr != nil {
+		events(models.StageEvent(models.StageEnamad, models.StatusFailed, enamadErr))
+	} else {
+		events(models.StageEvent(models.StageEnamad, models.StatusDone, nil))
 	}
-	return response
+
+	jsonCompliance, err := json.MarshalIndent(struct {
+		*scraperModels.ComplianceReport
+		PageSeals []scraperModels.SealVerification `json:"page_seals"`
+	}{compliance, seals}, "", "  ")
+	if err != nil {
+		log.Printf("jasonizing the compliance report went wrong : %s \n", err)
+	}
+
+	inputs := &models.ScanInputs{
+		Scraper:    report,
+		Whois:      whoisRecord.Info,
+		Enamad:     Enamad,
+		TLS:        tlsReport,
+		Redirects:  redirects,
+		DNS:        dnsReport,
+		TrustSeals: seals,
+		Compliance: compliance,
+		RiskInput:  scraperModels.RiskInputFromReport(report, domainAge, impersonation, tlsReport, seals, compliance),
+	}
+	ruleScore := h.Risk.Evaluate(inputs.RiskInput)
+
+	messages := buildMessages(site, jsonScraperData, jsonTLS, jsonRedirects, jsonDNS, jsonWhoisData, jsonCompliance)
+
+	events(models.StageEvent(models.StageLLM, models.StatusRunning, nil))
+	verdict, err := requestVerdict(ctx, h.LLM, messages, func(token string) {
+		events(models.ScanEvent{Type: models.EventToken, Stage: models.StageLLM, Data: token})
+	}, func(err error) {
+		events(models.StageEvent(models.StageLLM, models.StatusRetry, err))
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			events(models.StageEvent(models.StageLLM, models.StatusFailed, ctx.Err()))
+			return nil, ctx.Err()
+		}
+		log.Printf("Falling back to the rule engine for %s : %v", site, err)
+		events(models.StageEvent(models.StageLLM, models.StatusFailed, err))
+		return &models.ScanResult{
+			TrustVerdict: models.VerdictFromRiskScore(ruleScore),
+			Source:       models.VerdictSourceRules,
+			RuleScore:    ruleScore,
+			Compliance:   compliance,
+			Inputs:       inputs,
+		}, nil
+	}
+	events(models.StageEvent(models.StageLLM, models.StatusDone, nil))
+
+	return &models.ScanResult{
+		TrustVerdict: *verdict,
+		Source:       models.VerdictSourceAI,
+		RuleScore:    ruleScore,
+		Compliance:   compliance,
+		Inputs:       inputs,
+	}, nil
 
 }
-func convertTojson(AI_response string) ([]byte, string) {
 
-	result := strings.Trim(AI_response, "```json")
-	result = strings.TrimSpace(result)
+var errHostDown = errors.New("host is not up")
 
-	//fmt.Println(result)
-	// jsonResult, err := json.MarshalIndent(result, "", "  ")
-	// if err != nil {
-	// 	log.Printf("jsonizing the AI response went wrong ")
-	// }
-	jsonResult := []byte(result)
-	return jsonResult, result
+// ScanOptions override the cache policy for one scan
+type ScanOptions struct {
+	// Force skips the cache and always runs the pipeline
+	Force bool
+	// MaxAge replaces the policy window of the last verdict when positive
+	MaxAge time.Duration
 }
+
+// ScanURL scans site with the default cache policy. It is the jobs.ScanFunc of the queue.
+func (h *AIHandler) ScanURL(ctx context.Context, site string, events models.EventFunc) (json.RawMessage, error) {
+	return h.ScanURLWithOptions(ctx, site, ScanOptions{}, events)
+}
+
+// ScanURLWithOptions returns the stored result for site while it is fresh according to the
+// cache policy or opts, otherwise runs the whole pipeline and stores the new result
+func (h *AIHandler) ScanURLWithOptions(ctx context.Context, site string, opts ScanOptions, events models.EventFunc) (json.RawMessage, error) {
+	if events == nil {
+		events = func(models.ScanEvent) {}
+	}
+
+	// the page asked about is fetched, its verdict is stored under the key of the site
+	identity, err := scraperModels.NormalizeURL(site)
+	if err != nil {
+		return nil, err
+	}
+	site = identity.Key
+
+	// checking if host is up then continue
+	up := HostUp(ctx, h.HTTP, identity.URL)
+	events(models.ScanEvent{Type: models.EventHost, Data: models.HostStatus{URL: identity.URL, Up: up}})
+	if !up {
+		return nil, errHostDown
+	}
+
+	if !opts.Force {
+		if cached, ok := h.cachedResult(ctx, site, opts); ok {
+			for _, stage := range models.ScanStages {
+				events(models.StageEvent(stage, models.StatusSkipped, nil))
+			}
+			events(models.ScanEvent{Type: models.EventCached, Data: cached.Cache})
+			return json.Marshal(cached)
+		}
+	}
+
+	result, err := h.SendToAI(ctx, identity.URL, events)
+	if err != nil {
+		return nil, err
+	}
+	scannedAt := time.Now()
+
+	jsonResult, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling the scan result went wrong : %v", err)
+	}
+
+	// rule engine fallbacks are not cached so the next scan asks the model again
+	if result.Source == models.VerdictSourceAI {
+		// save the json response into database
+		if _, err := h.PostgreSQL.SaveAIResponse("url_storage", site, jsonResult); err != nil {
+			log.Printf("Failed to save the AI response : %v", err)
+		}
+	}
+
+	// every scan is kept in the history, fallbacks included
+	entry := &models.ScanHistoryEntry{
+		URL:        site,
+		ScannedAt:  scannedAt,
+		Source:     result.Source,
+		TrustScore: result.TrustScore,
+		RiskLevel:  result.RiskLevel,
+		Result:     *result,
+		Inputs:     result.Inputs,
+	}
+	if _, err := h.PostgreSQL.SaveScanHistory(ctx, entry); err != nil {
+		log.Printf("Failed to save the scan history of %s : %v", site, err)
+	}
+
+	result.Cache = &models.CacheInfo{
+		Hit:       false,
+		ScannedAt: scannedAt,
+		MaxAge:    int64(h.maxAge(result.RiskLevel, opts).Seconds()),
+	}
+	return json.Marshal(result)
+}
+
+// maxAge is the freshness window of a verdict, opts.MaxAge wins over the policy
+func (h *AIHandler) maxAge(riskLevel string, opts ScanOptions) time.Duration {
+	if opts.MaxAge > 0 {
+		return opts.MaxAge
+	}
+	return h.Cache.MaxAge(riskLevel)
+}
+
+// cachedResult returns the newest stored result of site when it is still fresh
+func (h *AIHandler) cachedResult(ctx context.Context, site string, opts ScanOptions) (*models.ScanResult, bool) {
+	desc, savedAt, err := h.PostgreSQL.LatestSavedData(ctx, site, "url_storage")
+	if err != nil {
+		if !errors.Is(err, Databases.ErrNotFound) {
+			log.Printf("Failed to read the cached result of %s : %v", site, err)
+		}
+		return nil, false
+	}
+
+	var cached models.ScanResult
+	if err := json.Unmarshal([]byte(desc), &cached); err != nil {
+		// unreadable rows are treated as stale and replaced by a new scan
+		log.Printf("Ignoring the unreadable cached result of %s : %v", site, err)
+		return nil, false
+	}
+
+	return &cached, checkFreshness(&cached, savedAt, h.maxAge(cached.RiskLevel, opts), time.Now())
+}
+
+// checkFreshness fills the cache info of a stored result and reports whether it can be reused
+func checkFreshness(result *models.ScanResult, savedAt time.Time, maxAge time.Duration, now time.Time) bool {
+	age := now.Sub(savedAt)
+	result.Cache = &models.CacheInfo{
+		Hit:        true,
+		ScannedAt:  savedAt,
+		AgeSeconds: int64(age.Seconds()),
+		MaxAge:     int64(maxAge.Seconds()),
+	}
+	return age <= maxAge
+}
+
+// scanOptionsFromRequest reads the force and max_age query parameters. max_age is
+// a number of seconds, a Go duration like 36h or a number of days like 7d
+func scanOptionsFromRequest(r *http.Request) (ScanOptions, error) {
+	var opts ScanOptions
+	query := r.URL.Query()
+
+	if force := query.Get("force"); force != "" {
+		parsed, err := strconv.ParseBool(force)
+		if err != nil {
+			return opts, fmt.Errorf("invalid force parameter %q", force)
+		}
+		opts.Force = parsed
+	}
+
+	if maxAge := query.Get("max_age"); maxAge != "" {
+		parsed, err := parseMaxAge(maxAge)
+		if err != nil || parsed < 0 {
+			return opts, fmt.Errorf("invalid max_age parameter %q", maxAge)
+		}
+		// a zero window never matches, that is a forced scan
+		if parsed == 0 {
+			opts.Force = true
+		}
+		opts.MaxAge = parsed
+	}
+
+	return opts, nil
+}
+
+func parseMaxAge(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
 func (h *AIHandler) Scan(w http.ResponseWriter, r *http.Request) {
 
-	var response models.CompletionResponse
 	vars := mux.Vars(r)
 	w.Header().Set("Content-Type", "application/json")
 
@@ -294,55 +576,105 @@ func (h *AIHandler) Scan(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing url term in the request", http.StatusBadRequest)
 		return
 	}
-	fmt.Println(urlterm)
-	// checking if host is up then continue
-	if !HostUp(urlterm) {
+	// the scan fetches the url as asked and stores it under the key of the site
+	if _, err := siteKey(urlterm); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts, err := scanOptionsFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.ScanURLWithOptions(r.Context(), urlterm, opts, nil)
+	if err == errHostDown {
 		http.Error(w, "Host is not Up", http.StatusBadRequest)
 		return
 	}
-	//checking if the url exists in database or not
-	//site, _ := ExtractMainDomain(urlterm)
-	exists := h.PostgreSQL.CheckIfurlExistsInDB(urlterm, "url_storage")
-	fmt.Println(exists)
-	if exists {
-		//check if date is exceed or not
-		if h.PostgreSQL.IsRecent(urlterm, "url_storage") {
-
-			// retrieve if not
-			desc := h.PostgreSQL.RetreiveSavedData(urlterm, "url_storage")
-			w.Write([]byte(desc))
-			return
-		}
+	if err != nil {
+		log.Printf("Scanning %s went wrong : %v", urlterm, err)
+		http.Error(w, "No valid response from AI model", http.StatusBadGateway)
+		return
+	}
 
-		// proceed to send to AI if exceed
+	w.Write(result)
+}
+
+// ScanJobRequest is the body of POST /scans
+type ScanJobRequest struct {
+	URL string `json:"url"`
+}
+
+// CreateScanJob handles POST requests that enqueue an asynchronous scan
+func (h *AIHandler) CreateScanJob(w http.ResponseWriter, r *http.Request) {
+	var request ScanJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
 	}
-	//Prepare_AI()
-	//fmt.Fprintf(w, "%s", prepare_ai.Choices[0].Message.Content)
-	response = SendToAI(urlterm)
-	//fmt.Println(response)
 
-	if len(response.Choices) == 0 {
-		http.Error(w, "No response from AI model", http.StatusInternalServerError)
+	request.URL = strings.TrimSpace(request.URL)
+	if request.URL == "" {
+		http.Error(w, "url is required", http.StatusBadRequest)
+		return
+	}
+	site, err := scraperModels.NormalizeURL(request.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	//fmt.Fprintf(w, "%s", response.Choices[0].Message.Content)
-	jsonFraudDetectorResponseAI, stringFraudDetectorResponseAI := convertTojson(response.Choices[0].Message.Content)
+	job, err := h.Jobs.Enqueue(r.Context(), site.URL)
+	if err == jobs.ErrQueueFull {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "Too many scans in progress, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to enqueue scan of %s: %v", request.URL, err)
+		http.Error(w, "Failed to enqueue scan", http.StatusInternalServerError)
+		return
+	}
 
-	fmt.Printf("%s", stringFraudDetectorResponseAI)
+	log.Printf("Enqueued scan job %s for %s", job.ID, job.URL)
 
-	w.Write(jsonFraudDetectorResponseAI)
-	//sending to frontend
-	// if err := json.NewEncoder(w).Encode(jsonFraudDetectorResponseAI); err != nil {
-	// 	log.Printf("sending the json to front end went wrong : %s, \n", err)
-	// }
-	// save the json response into database
-	_, err := h.PostgreSQL.SaveAIResponse("url_storage", urlterm, jsonFraudDetectorResponseAI)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/ai/scans/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	response := map[string]interface{}{
+		"status": "success",
+		"id":     job.ID,
+		"job":    job,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// GetScanJob handles GET requests for the status of an asynchronous scan
+func (h *AIHandler) GetScanJob(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	job, err := h.Jobs.Get(r.Context(), id)
+	if errors.Is(err, Databases.ErrNotFound) {
+		http.Error(w, "Scan job not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		log.Printf("Failed to save the AI response : %v", err)
+		log.Printf("Failed to retrieve scan job %s: %v", id, err)
+		http.Error(w, "Failed to retrieve scan job", http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(job); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
 }
 
 // url storage handlers added:
@@ -1,181 +1,117 @@
 package handlers
 
 import (
-	"reflect"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
 	"testing"
-)
+	"time"
 
-func TestConvertTojson(t *testing.T) {
-	tests := []struct {
-		name           string
-		input          string
-		expectedBytes  []byte
-		expectedString string
-	}{
-		{
-			name:           "JSON with backticks and json text",
-			input:          "```json{\"name\": \"test\", \"value\": 123}```",
-			expectedBytes:  []byte("{\"name\": \"test\", \"value\": 123}"),
-			expectedString: "{\"name\": \"test\", \"value\": 123}",
-		},
-		{
-			name:           "JSON with partial markdown",
-			input:          "```json{\"status\": \"success\"}",
-			expectedBytes:  []byte("{\"status\": \"success\"}"),
-			expectedString: "{\"status\": \"success\"}",
-		},
-		{
-			name:           "JSON with trailing backticks only",
-			input:          "{\"error\": \"none\"}```",
-			expectedBytes:  []byte("{\"error\": \"none\"}"),
-			expectedString: "{\"error\": \"none\"}",
-		},
-		{
-			name:           "Plain JSON without any trim characters",
-			input:          "{\"plain\": \"json\"}",
-			expectedBytes:  []byte("{\"plain\": \"json\"}"),
-			expectedString: "{\"plain\": \"json\"}",
-		},
-		{
-			name:           "Input with leading and trailing whitespace only",
-			input:          "  {\"whitespace\": \"test\"}  ",
-			expectedBytes:  []byte("{\"whitespace\": \"test\"}"),
-			expectedString: "{\"whitespace\": \"test\"}",
-		},
-		{
-			name:           "Empty string",
-			input:          "",
-			expectedBytes:  []byte(""),
-			expectedString: "",
-		},
-		{
-			name:           "Only trim characters",
-			input:          "```json```",
-			expectedBytes:  []byte(""),
-			expectedString: "",
-		},
-		{
-			name:           "JSON with newlines and markdown",
-			input:          "```json\n{\n  \"multiline\": \"json\"\n}\n```",
-			expectedBytes:  []byte("{\n  \"multiline\": \"json\"\n}"),
-			expectedString: "{\n  \"multiline\": \"json\"\n}",
-		},
-		{
-			name:           "Complex nested JSON with markdown",
-			input:          "```json{\"users\": [{\"id\": 1, \"name\": \"John\"}, {\"id\": 2, \"name\": \"Jane\"}], \"count\": 2}```",
-			expectedBytes:  []byte("{\"users\": [{\"id\": 1, \"name\": \"John\"}, {\"id\": 2, \"name\": \"Jane\"}], \"count\": 2}"),
-			expectedString: "{\"users\": [{\"id\": 1, \"name\": \"John\"}, {\"id\": 2, \"name\": \"Jane\"}], \"count\": 2}",
-		},
-		{
-			name:           "JSON with special characters and markdown",
-			input:          "```json{\"message\": \"Hello\\nWorld\", \"symbol\": \"@#$%\"}```",
-			expectedBytes:  []byte("{\"message\": \"Hello\\nWorld\", \"symbol\": \"@#$%\"}"),
-			expectedString: "{\"message\": \"Hello\\nWorld\", \"symbol\": \"@#$%\"}",
-		},
-		{
-			name:           "Mixed trim characters scattered",
-			input:          "jjj```test content```nnn",
-			expectedBytes:  []byte("test content"),
-			expectedString: "test content",
-		},
-	}
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/llm"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+)
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			gotBytes, gotString := convertTojson(tt.input)
+func TestRequestVerdict(t *testing.T) {
+	valid := "<think>the site looks fine</think>\n```json\n" + llm.FakeVerdict + "\n```"
 
-			// Check if byte slices are equal
-			if !reflect.DeepEqual(gotBytes, tt.expectedBytes) {
-				t.Errorf("convertTojson() gotBytes = %v, want %v", gotBytes, tt.expectedBytes)
-				t.Errorf("convertTojson() gotBytes as string = %q, want %q", string(gotBytes), string(tt.expectedBytes))
-			}
+	fake := llm.NewFake(valid)
+	got, err := requestVerdict(context.Background(), fake, []llm.Message{{Role: "user", Content: "analyze"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("requestVerdict returned an error: %v", err)
+	}
+	if got.TrustScore != 50 || got.RiskLevel != "medium" {
+		t.Errorf("got %+v", got)
+	}
+	if len(fake.Calls()) != 1 {
+		t.Errorf("got %d calls, wanted 1", len(fake.Calls()))
+	}
+}
 
-			// Check if strings are equal
-			if gotString != tt.expectedString {
-				t.Errorf("convertTojson() gotString = %q, want %q", gotString, tt.expectedString)
-			}
+func TestRequestVerdictRepairsInvalidOutput(t *testing.T) {
+	invalid := `{"trustScore": 150, "riskLevel": "extreme", "positivePoints": [], "negativePoints": [], "description": "x", "technicalFlags": {}}`
 
-			// Ensure consistency between returned byte slice and string
-			if string(gotBytes) != gotString {
-				t.Errorf("convertTojson() byte slice and string are inconsistent: %q != %q", string(gotBytes), gotString)
-			}
-		})
+	fake := llm.NewFake("I cannot produce JSON today", invalid, llm.FakeVerdict)
+	got, err := requestVerdict(context.Background(), fake, []llm.Message{{Role: "user", Content: "analyze"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("requestVerdict returned an error: %v", err)
+	}
+	if got.TrustScore != 50 {
+		t.Errorf("got trust score %d, wanted 50", got.TrustScore)
 	}
-}
 
-// Test edge cases with different combinations of trim characters
-func TestConvertTojsonEdgeCases(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name:     "Only backticks",
-			input:    "```",
-			expected: "",
-		},
-		{
-			name:     "Only 'json' text",
-			input:    "json",
-			expected: "",
-		},
-		{
-			name:     "Backticks and json intermixed",
-			input:    "`j`s`o`n`",
-			expected: "",
-		},
+	calls := fake.Calls()
+	if len(calls) != 3 {
+		t.Fatalf("got %d calls, wanted 3", len(calls))
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			_, gotString := convertTojson(tt.input)
-			if gotString != tt.expected {
-				t.Errorf("convertTojson() = %q, want %q", gotString, tt.expected)
-			}
-		})
+	// the last repair prompt must tell the model what was wrong with its previous answer
+	last := calls[2][len(calls[2])-1]
+	if last.Role != "user" || !strings.Contains(last.Content, "trustScore 150 is outside 0-100") {
+		t.Errorf("repair prompt does not describe the problem: %q", last.Content)
+	}
+	if previous := calls[2][len(calls[2])-2]; previous.Role != "assistant" || previous.Content != invalid {
+		t.Errorf("repair prompt does not include the previous answer: %+v", previous)
 	}
 }
 
-// Benchmark test to measure performance
-func BenchmarkConvertTojson(b *testing.B) {
-	input := "```json{\"name\": \"benchmark\", \"value\": 42, \"active\": true}```"
+func TestRequestVerdictSignalsRetries(t *testing.T) {
+	fake := llm.NewFake("I cannot produce JSON today", llm.FakeVerdict)
 
-	for i := 0; i < b.N; i++ {
-		convertTojson(input)
+	var streamed strings.Builder
+	var retries []error
+	onToken := func(token string) { streamed.WriteString(token) }
+	onRetry := func(err error) {
+		// the client drops what the invalid answer streamed so far
+		retries = append(retries, err)
+		streamed.Reset()
 	}
-}
-
-// Test with very large JSON string
-func TestConvertTojsonLargeInput(t *testing.T) {
-	// Create a large JSON-like string
-	largeJSON := "```json{\"data\": \""
-	for i := 0; i < 1000; i++ {
-		largeJSON += "test"
+	if _, err := requestVerdict(context.Background(), fake, []llm.Message{{Role: "user", Content: "analyze"}}, onToken, onRetry); err != nil {
+		t.Fatalf("requestVerdict returned an error: %v", err)
 	}
-	largeJSON += "\"}```"
 
-	gotBytes, gotString := convertTojson(largeJSON)
+	if len(retries) != 1 || retries[0] == nil {
+		t.Fatalf("got retries %v, wanted one with the reason", retries)
+	}
+	if got := streamed.String(); got != llm.FakeVerdict {
+		t.Errorf("got %q streamed after the retry, wanted only the valid answer", got)
+	}
+}
 
-	// Verify consistency
-	if string(gotBytes) != gotString {
-		t.Error("convertTojson() byte slice and string should be consistent")
+func TestRequestVerdictGivesUp(t *testing.T) {
+	fake := llm.NewFake("no json here")
+	if _, err := requestVerdict(context.Background(), fake, nil, nil, nil); err == nil {
+		t.Fatal("expected an error when the model never returns a valid verdict")
+	}
+	if len(fake.Calls()) != maxRepairAttempts+1 {
+		t.Errorf("got %d calls, wanted %d", len(fake.Calls()), maxRepairAttempts+1)
 	}
 
-	// Should start with opening brace after trimming
-	if len(gotString) > 0 && gotString[0] != '{' {
-		t.Errorf("Expected trimmed string to start with '{', got: %c", gotString[0])
+	fake = llm.NewFake()
+	fake.Err = errors.New("provider down")
+	if _, err := requestVerdict(context.Background(), fake, nil, nil, nil); err == nil {
+		t.Fatal("expected the provider error to be returned")
+	}
+	if len(fake.Calls()) != 1 {
+		t.Errorf("provider errors must not be retried, got %d calls", len(fake.Calls()))
 	}
 }
-func TestCheckDomainAge(t *testing.T) {
-	whoisData := Whois("digikala.com")
-	got := checkDomainAge(whoisData)
-	want := 6777
 
-	if got != want {
-		t.Errorf("got %v, wanted %v", got, want)
-	}
+func TestSSEWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	stream := &sseWriter{w: rec, flusher: rec}
+
+	stream.send(models.StageEvent(models.StageWhois, models.StatusFailed, errors.New("timeout")))
+	stream.send(models.ScanEvent{Type: models.EventToken, Stage: models.StageLLM, Data: "{\"trust"})
 
+	want := "event: stage\ndata: {\"type\":\"stage\",\"stage\":\"whois\",\"status\":\"failed\",\"error\":\"timeout\"}\n\n" +
+		"event: token\ndata: {\"type\":\"token\",\"stage\":\"llm\",\"data\":\"{\\\"trust\"}\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	if !rec.Flushed {
+		t.Error("events must be flushed as soon as they are written")
+	}
 }
 
 func TestExtractMainDomain(t *testing.T) {
@@ -186,4 +122,75 @@ func TestExtractMainDomain(t *testing.T) {
 		t.Errorf("got %q, wanted %q", got, want)
 	}
 
+	// the last two labels of these hosts are a public suffix
+	for raw, want := range map[string]string{"https://shop.co.ir/x": "shop.co.ir", "www.example.ac.ir": "example.ac.ir"} {
+		if got, err := ExtractMainDomain(raw); err != nil || got != want {
+			t.Errorf("ExtractMainDomain(%q) got %q, %v, wanted %q", raw, got, err, want)
+		}
+	}
+}
+
+func TestCacheFreshnessDependsOnRiskLevel(t *testing.T) {
+	h := &AIHandler{Cache: models.DefaultCachePolicy()}
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	savedAt := now.Add(-3 * 24 * time.Hour)
+
+	tests := []struct {
+		riskLevel string
+		opts      ScanOptions
+		fresh     bool
+	}{
+		{riskLevel: models.RiskHigh, fresh: false},
+		{riskLevel: models.RiskMedium, fresh: true},
+		{riskLevel: "Low", fresh: true},
+		{riskLevel: "", fresh: true},
+		{riskLevel: models.RiskLow, opts: ScanOptions{MaxAge: 48 * time.Hour}, fresh: false},
+		{riskLevel: models.RiskHigh, opts: ScanOptions{MaxAge: 96 * time.Hour}, fresh: true},
+	}
+
+	for _, tt := range tests {
+		result := &models.ScanResult{TrustVerdict: models.TrustVerdict{RiskLevel: tt.riskLevel}}
+		got := checkFreshness(result, savedAt, h.maxAge(tt.riskLevel, tt.opts), now)
+		if got != tt.fresh {
+			t.Errorf("risk level %q with %+v: got fresh %v, wanted %v", tt.riskLevel, tt.opts, got, tt.fresh)
+		}
+		if !result.Cache.Hit || result.Cache.AgeSeconds != 3*24*3600 {
+			t.Errorf("got cache info %+v", result.Cache)
+		}
+	}
+}
+
+func TestScanOptionsFromRequest(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    ScanOptions
+		wantErr bool
+	}{
+		{query: "", want: ScanOptions{}},
+		{query: "force=true", want: ScanOptions{Force: true}},
+		{query: "max_age=3600", want: ScanOptions{MaxAge: time.Hour}},
+		{query: "max_age=36h", want: ScanOptions{MaxAge: 36 * time.Hour}},
+		{query: "max_age=2d", want: ScanOptions{MaxAge: 48 * time.Hour}},
+		{query: "max_age=0", want: ScanOptions{Force: true}},
+		{query: "force=maybe", wantErr: true},
+		{query: "max_age=-5", wantErr: true},
+		{query: "max_age=soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := scanOptionsFromRequest(httptest.NewRequest("GET", "/scan/example.com?"+tt.query, nil))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error", tt.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.query, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %+v, wanted %+v", tt.query, got, tt.want)
+		}
+	}
 }
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Databases"
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	"github.com/gorilla/mux"
+)
+
+// maxBatchBody bounds the body of a batch request, a thousand urls fit many times over
+const maxBatchBody = 8 << 20
+
+var errTooManyURLs = errors.New("too many urls in the batch")
+
+// readBatchURLs reads the urls of a batch request. The body is a JSON array, a CSV or
+// newline separated list, or a multipart form with the list in its file field.
+func readBatchURLs(r *http.Request) ([]string, error) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "application/json"
+	}
+
+	switch mediaType {
+	case "application/json":
+		var urls []string
+		if err := json.NewDecoder(r.Body).Decode(&urls); err != nil {
+			return nil, fmt.Errorf("the body is not a JSON array of urls: %v", err)
+		}
+		return urls, nil
+	case "multipart/form-data":
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return nil, errors.New("the form has no file field")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading the form: %v", err)
+			}
+			if part.FormName() != "file" {
+				continue
+			}
+			if strings.HasSuffix(strings.ToLower(part.FileName()), ".json") {
+				var urls []string
+				if err := json.NewDecoder(part).Decode(&urls); err != nil {
+					return nil, fmt.Errorf("the file is not a JSON array of urls: %v", err)
+				}
+				return urls, nil
+			}
+			return parseURLList(part)
+		}
+	default:
+		return parseURLList(r.Body)
+	}
+}
+
+// parseURLList reads a CSV file or a list with one url per line. A header row naming a
+// url column picks that column, otherwise the first field of every row is the url.
+func parseURLList(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	urls := []string{}
+	column := 0
+	for row := 0; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return urls, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading the url list: %v", err)
+		}
+
+		if row == 0 {
+			if header := urlColumn(record); header >= 0 {
+				column = header
+				continue
+			}
+		}
+		if column < len(record) {
+			urls = append(urls, record[column])
+		}
+	}
+}
+
+// urlColumn returns the index of the url column of a header row, -1 when record is no header
+func urlColumn(record []string) int {
+	for i, field := range record {
+		switch strings.ToLower(strings.TrimSpace(field)) {
+		case "url", "urls", "site", "domain":
+			return i
+		}
+	}
+	return -1
+}
+
+// uniqueURLs normalizes urls and drops the ones whose site is already listed, keeping the
+// first occurrence. The urls that name no site are returned apart.
+func uniqueURLs(urls []string) ([]string, []string) {
+	unique, invalid := []string{}, []string{}
+	seen := make(map[string]bool)
+	for _, url := range urls {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			continue
+		}
+		site, err := scraperModels.NormalizeURL(url)
+		if err != nil {
+			invalid = append(invalid, url)
+			continue
+		}
+		if seen[site.Key] {
+			continue
+		}
+		seen[site.Key] = true
+		unique = append(unique, site.URL)
+	}
+	return unique, invalid
+}
+
+// CreateScanBatch handles POST requests that scan a list of urls asynchronously. Urls with
+// a fresh verdict in the cache are answered from it, the others are scanned by the queue.
+func (h *AIHandler) CreateScanBatch(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBody)
+
+	submitted, err := readBatchURLs(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	urls, invalid := uniqueURLs(submitted)
+	if len(urls) == 0 {
+		http.Error(w, "the batch has no valid url", http.StatusBadRequest)
+		return
+	}
+	if len(urls) > h.MaxBatchSize {
+		http.Error(w, fmt.Sprintf("%v, at most %d are accepted", errTooManyURLs, h.MaxBatchSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	cached := make(map[string]json.RawMessage)
+	for _, url := range urls {
+		key, err := siteKey(url)
+		if err != nil {
+			continue
+		}
+		result, ok := h.cachedResult(r.Context(), key, ScanOptions{})
+		if !ok {
+			continue
+		}
+		jsonResult, err := json.Marshal(result)
+		if err != nil {
+			log.Printf("Failed to marshal the cached result of %s: %v", url, err)
+			continue
+		}
+		cached[url] = jsonResult
+	}
+
+	batch, err := h.Jobs.EnqueueBatch(r.Context(), urls, cached)
+	if err != nil {
+		log.Printf("Failed to enqueue a batch of %d urls: %v", len(urls), err)
+		http.Error(w, "Failed to enqueue scan batch", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Enqueued scan batch %s with %d urls, %d from the cache", batch.ID, len(urls), len(cached))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/ai/scans/batch/"+batch.ID)
+	w.WriteHeader(http.StatusAccepted)
+	response := map[string]interface{}{
+		"status":     "success",
+		"id":         batch.ID,
+		"submitted":  len(submitted),
+		"invalid":    invalid,
+		"duplicates": len(submitted) - len(urls) - len(invalid),
+		"cached":     len(cached),
+		"queued":     len(urls) - len(cached),
+		"batch":      batch,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// GetScanBatch handles GET requests for the aggregated results of a batch. format=csv or an
+// Accept header asking for text/csv returns a CSV file, format=json downloads the JSON report.
+func (h *AIHandler) GetScanBatch(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		format = "csv"
+	}
+	if format != "" && format != "csv" && format != "json" {
+		http.Error(w, fmt.Sprintf("invalid format parameter %q", format), http.StatusBadRequest)
+		return
+	}
+
+	batch, jobs, err := h.Jobs.GetBatch(r.Context(), id)
+	if errors.Is(err, Databases.ErrNotFound) {
+		http.Error(w, "Scan batch not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to retrieve scan batch %s: %v", id, err)
+		http.Error(w, "Failed to retrieve scan batch", http.StatusInternalServerError)
+		return
+	}
+
+	writeBatchReport(w, models.NewBatchReport(batch, jobs), format)
+}
+
+// writeBatchReport writes report in format, an explicit format is sent as a download
+func writeBatchReport(w http.ResponseWriter, report *models.BatchReport, format string) {
+	if format != "" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"scan-batch-%s.%s\"", report.ID, format))
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		if err := report.WriteCSV(w); err != nil {
+			log.Printf("Failed to write the CSV of scan batch %s: %v", report.ID, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
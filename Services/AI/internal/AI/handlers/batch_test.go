@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadBatchURLs(t *testing.T) {
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	writer.WriteField("note", "reported today")
+	file, _ := writer.CreateFormFile("file", "reports.csv")
+	file.Write([]byte("id,url,reporter\n1,shop.ir,ali\n2, bank.ir ,sara\n"))
+	writer.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []string
+	}{
+		{"json array", "application/json", `["shop.ir", "bank.ir"]`, []string{"shop.ir", "bank.ir"}},
+		{"no content type", "", `["shop.ir"]`, []string{"shop.ir"}},
+		{"newline list", "text/plain", "shop.ir\n# reported twice\n\nbank.ir\n", []string{"shop.ir", "bank.ir"}},
+		{"csv without header", "text/csv", "shop.ir,phishing\nbank.ir,scam\n", []string{"shop.ir", "bank.ir"}},
+		{"multipart csv", writer.FormDataContentType(), form.String(), []string{"shop.ir", "bank.ir "}},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/scans/batch", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			r.Header.Set("Content-Type", tt.contentType)
+		}
+		got, err := readBatchURLs(r)
+		if err != nil {
+			t.Errorf("%s: got error %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, wanted %q", tt.name, got, tt.want)
+		}
+	}
+
+	r := httptest.NewRequest("POST", "/scans/batch", strings.NewReader(`{"url": "shop.ir"}`))
+	r.Header.Set("Content-Type", "application/json")
+	if _, err := readBatchURLs(r); err == nil {
+		t.Errorf("got no error for a JSON object")
+	}
+}
+
+func TestUniqueURLs(t *testing.T) {
+	got, invalid := uniqueURLs([]string{"shop.ir", " bank.ir", "", "https://www.shop.ir/", "localhost", "BANK.ir", "http://shop.ir"})
+	// the http spelling of a listed site shares its verdict and is not scanned again
+	if want := []string{"https://shop.ir/", "https://bank.ir/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+	if want := []string{"localhost"}; !reflect.DeepEqual(invalid, want) {
+		t.Errorf("got invalid %q, wanted %q", invalid, want)
+	}
+}
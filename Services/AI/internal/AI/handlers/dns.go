@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// maxHostingLookups bounds the ASN lookups, a flux domain can have dozens of addresses
+	maxHostingLookups = 10
+	// fallbackResolver is used when resolv.conf names no server
+	fallbackResolver = "127.0.0.1:53"
+)
+
+// DNSClient sends its queries to a single recursive resolver, so tests and deployments
+// can choose which one answers
+type DNSClient struct {
+	// Server is the host:port of the resolver
+	Server  string
+	Timeout time.Duration
+	// CheckTimeout bounds every lookup made for one domain by CheckDNS
+	CheckTimeout time.Duration
+}
+
+// NewDNSClient returns a client of server, an empty server uses the first one in /etc/resolv.conf.
+// timeout bounds a query, checkTimeout all the queries about one domain.
+func NewDNSClient(server string, timeout, checkTimeout time.Duration) *DNSClient {
+	if server == "" {
+		server = systemResolver("/etc/resolv.conf")
+	}
+	return &DNSClient{Server: server, Timeout: timeout, CheckTimeout: checkTimeout}
+}
+
+// systemResolver reads the first nameserver of a resolv.conf file
+func systemResolver(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return fallbackResolver
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53")
+		}
+	}
+	return fallbackResolver
+}
+
+// Lookup returns the records of type qtype for name, a name without such records gives none
+func (c *DNSClient) Lookup(ctx context.Context, name string, qtype dnsmessage.Type) ([]scraperModels.DNSRecord, error) {
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid name %q: %v", name, err)
+	}
+
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: uint16(rand.Intn(1 << 16)), RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("error packing the query: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	answer, err := c.exchange(ctx, "udp", packed, query.ID)
+	// answers too large for a datagram are asked again over tcp
+	if err == nil && answer.Truncated {
+		answer, err = c.exchange(ctx, "tcp", packed, query.ID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s lookup of %s failed: %v", qtype, name, err)
+	}
+
+	switch answer.RCode {
+	case dnsmessage.RCodeSuccess, dnsmessage.RCodeNameError:
+	default:
+		return nil, fmt.Errorf("%s lookup of %s failed: %s", qtype, name, answer.RCode)
+	}
+
+	records := []scraperModels.DNSRecord{}
+	for _, rr := range answer.Answers {
+		// the answer also carries the CNAME chain leading to the records
+		if rr.Header.Type != qtype {
+			continue
+		}
+		if value := recordValue(rr.Body); value != "" {
+			records = append(records, scraperModels.DNSRecord{Type: strings.TrimPrefix(qtype.String(), "Type"), Value: value, TTL: rr.Header.TTL})
+		}
+	}
+	return records, nil
+}
+
+// exchange sends a packed query to the resolver over network and waits for the answer with id
+func (c *DNSClient) exchange(ctx context.Context, network string, packed []byte, id uint16) (*dnsmessage.Message, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, c.Server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "tcp" {
+		// dns over tcp prefixes every message with its length
+		framed := binary.BigEndian.AppendUint16(nil, uint16(len(packed)))
+		if _, err := conn.Write(append(framed, packed...)); err != nil {
+			return nil, err
+		}
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, err
+		}
+		var msg dnsmessage.Message
+		if err := msg.Unpack(buf); err != nil {
+			return nil, err
+		}
+		return &msg, nil
+	}
+
+	if _, err := conn.Write(packed); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		var msg dnsmessage.Message
+		// stray datagrams and answers to other queries are skipped
+		if err := msg.Unpack(buf[:n]); err != nil || msg.ID != id || !msg.Response {
+			continue
+		}
+		return &msg, nil
+	}
+}
+
+// recordValue is the text form of the records collected in a DNSReport
+func recordValue(body dnsmessage.ResourceBody) string {
+	switch b := body.(type) {
+	case *dnsmessage.AResource:
+		return netip.AddrFrom4(b.A).String()
+	case *dnsmessage.AAAAResource:
+		return netip.AddrFrom16(b.AAAA).String()
+	case *dnsmessage.NSResource:
+		return strings.TrimSuffix(b.NS.String(), ".")
+	case *dnsmessage.MXResource:
+		return fmt.Sprintf("%d %s", b.Pref, strings.TrimSuffix(b.MX.String(), "."))
+	case *dnsmessage.TXTResource:
+		return strings.Join(b.TXT, "")
+	}
+	return ""
+}
+
+// cymruOriginName is the Team Cymru name answering which network announces ip
+func cymruOriginName(ip netip.Addr) string {
+	ip = ip.Unmap()
+	if ip.Is4() {
+		b := ip.As4()
+		return fmt.Sprintf("%d.%d.%d.%d.origin.asn.cymru.com", b[3], b[2], b[1], b[0])
+	}
+	b := ip.As16()
+	nibbles := make([]string, 0, 32)
+	for i := len(b) - 1; i >= 0; i-- {
+		nibbles = append(nibbles, fmt.Sprintf("%x", b[i]&0xf), fmt.Sprintf("%x", b[i]>>4))
+	}
+	return strings.Join(nibbles, ".") + ".origin6.asn.cymru.com"
+}
+
+// CheckDNS collects the records of site from the resolver of client, the hosting network
+// of its addresses and the fast flux signs. Failed lookups are listed in the Errors field.
+func CheckDNS(ctx context.Context, client *DNSClient, site string) *scraperModels.DNSReport {
+	host := site
+	if u, err := scraperModels.ParseSiteURL(site); err == nil {
+		host = u.Hostname()
+	}
+	report := scraperModels.NewDNSReport(host, client.Server)
+
+	ctx, cancel := context.WithTimeout(ctx, client.CheckTimeout)
+	defer cancel()
+
+	lookup := func(name string, qtype dnsmessage.Type) []scraperModels.DNSRecord {
+		records, err := client.Lookup(ctx, name, qtype)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return []scraperModels.DNSRecord{}
+		}
+		return records
+	}
+
+	// name servers, mail and its policies belong to the registered domain
+	domain := scraperModels.RegisteredDomain(host)
+
+	report.A = lookup(host, dnsmessage.TypeA)
+	report.AAAA = lookup(host, dnsmessage.TypeAAAA)
+	report.NS = lookup(domain, dnsmessage.TypeNS)
+	report.MX = lookup(domain, dnsmessage.TypeMX)
+	report.SetTXT(lookup(domain, dnsmessage.TypeTXT))
+	report.SetDMARC(lookup("_dmarc."+domain, dnsmessage.TypeTXT))
+
+	for _, rec := range append(append([]scraperModels.DNSRecord{}, report.A...), report.AAAA...) {
+		report.Addresses = append(report.Addresses, rec.Value)
+	}
+
+	providers := map[string]string{}
+	for i, addr := range report.Addresses {
+		if i == maxHostingLookups {
+			break
+		}
+		ip, err := netip.ParseAddr(addr)
+		if err != nil {
+			continue
+		}
+		info := scraperModels.HostingInfo{IP: addr}
+		if origin := lookup(cymruOriginName(ip), dnsmessage.TypeTXT); len(origin) > 0 {
+			info.ASN, info.Prefix, info.Country = scraperModels.ParseCymruOrigin(origin[0].Value)
+		}
+		if info.ASN != "" {
+			provider, ok := providers[info.ASN]
+			if !ok {
+				if names := lookup("AS"+info.ASN+".asn.cymru.com", dnsmessage.TypeTXT); len(names) > 0 {
+					provider = scraperModels.ParseCymruASName(names[0].Value)
+				}
+				providers[info.ASN] = provider
+			}
+			info.Provider = provider
+		}
+		report.Hosting = append(report.Hosting, info)
+	}
+
+	report.DetectFastFlux()
+	return report
+}
+
+// checkDNS runs CheckDNS and looks for flagged domains sharing the addresses of site
+func (h *AIHandler) checkDNS(ctx context.Context, site string) *scraperModels.DNSReport {
+	report := CheckDNS(ctx, h.DNS, site)
+	if h.PostgreSQL == nil || len(report.Addresses) == 0 {
+		return report
+	}
+
+	// the history is keyed by site, the url being scanned is not one of the others
+	key, err := siteKey(site)
+	if err != nil {
+		return report
+	}
+	shared, err := h.PostgreSQL.FlaggedSharingAddresses(ctx, key, report.Addresses)
+	if err != nil {
+		log.Printf("Looking up the domains sharing the addresses of %s went wrong : %v", site, err)
+		report.Errors = append(report.Errors, err.Error())
+		return report
+	}
+	report.SharedWith = shared
+	return report
+}
+
+// GetDNSData handles GET requests describing the DNS records of a url
+func (h *AIHandler) GetDNSData(w http.ResponseWriter, r *http.Request) {
+	urlterm, ok := mux.Vars(r)["url"]
+	if !ok {
+		http.Error(w, "Missing url term in the request", http.StatusBadRequest)
+		return
+	}
+	urlterm, err := siteKey(urlterm)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report := h.checkDNS(r.Context(), urlterm)
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"status": "success",
+		"url":    urlterm,
+		"issues": report.Issues(),
+		"data":   report,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
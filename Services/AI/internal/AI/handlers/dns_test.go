@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubResolver answers the questions found in records over udp, anything else is NXDOMAIN
+func stubResolver(t *testing.T, records map[string][]dnsmessage.Resource) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			q := query.Questions[0]
+
+			answer := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+				Questions: query.Questions,
+			}
+			key := strings.ToLower(q.Name.String()) + " " + q.Type.String()
+			if rrs, ok := records[key]; ok {
+				for _, rr := range rrs {
+					rr.Header.Name, rr.Header.Class = q.Name, dnsmessage.ClassINET
+					answer.Answers = append(answer.Answers, rr)
+				}
+			} else {
+				answer.RCode = dnsmessage.RCodeNameError
+			}
+			packed, err := answer.Pack()
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			conn.WriteTo(packed, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func aRecord(ip string, ttl uint32) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeA, TTL: ttl},
+		Body:   &dnsmessage.AResource{A: netip.MustParseAddr(ip).As4()},
+	}
+}
+
+func txt(value string) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeTXT, TTL: 3600},
+		Body:   &dnsmessage.TXTResource{TXT: []string{value}},
+	}
+}
+
+func TestCheckDNS(t *testing.T) {
+	// name servers and mail are asked of the registered domain
+	records := map[string][]dnsmessage.Resource{
+		"shop.example.ir. TypeA": {aRecord("203.0.113.1", 60), aRecord("203.0.113.2", 60), aRecord("198.51.100.3", 60), aRecord("192.0.2.4", 60), aRecord("192.0.2.5", 60)},
+		"example.ir. TypeNS": {{
+			Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeNS, TTL: 3600},
+			Body:   &dnsmessage.NSResource{NS: dnsmessage.MustNewName("ns1.host.ir.")},
+		}},
+		"example.ir. TypeMX": {{
+			Header: dnsmessage.ResourceHeader{Type: dnsmessage.TypeMX, TTL: 3600},
+			Body:   &dnsmessage.MXResource{Pref: 10, MX: dnsmessage.MustNewName("mail.example.ir.")},
+		}},
+		"example.ir. TypeTXT": {txt("google-site-verification=abc"), txt("v=spf1 mx -all")},
+
+		"1.113.0.203.origin.asn.cymru.com. TypeTXT":  {txt("64500 | 203.0.113.0/24 | NL | ripencc | 2020-01-01")},
+		"2.113.0.203.origin.asn.cymru.com. TypeTXT":  {txt("64500 | 203.0.113.0/24 | NL | ripencc | 2020-01-01")},
+		"3.100.51.198.origin.asn.cymru.com. TypeTXT": {txt("64501 | 198.51.100.0/24 | RU | ripencc | 2021-01-01")},
+		"4.2.0.192.origin.asn.cymru.com. TypeTXT":    {txt("64502 64503 | 192.0.2.0/24 | US | arin | 2019-01-01")},
+		"as64500.asn.cymru.com. TypeTXT":             {txt("64500 | NL | ripencc | 2001-01-01 | BULLETPROOF-HOST, NL")},
+	}
+
+	client := NewDNSClient(stubResolver(t, records), 2*time.Second, 10*time.Second)
+	report := CheckDNS(context.Background(), client, "https://shop.example.ir/login")
+
+	if report.Domain != "shop.example.ir" || len(report.Addresses) != 5 || len(report.NS) != 1 || report.MX[0].Value != "10 mail.example.ir" {
+		t.Errorf("got report %+v", report)
+	}
+	if !report.HasSPF || report.HasDMARC {
+		t.Errorf("got spf %v dmarc %v, wanted spf only", report.HasSPF, report.HasDMARC)
+	}
+	if len(report.Errors) != 0 {
+		t.Errorf("got errors %q, a missing record is not an error", report.Errors)
+	}
+
+	if len(report.Hosting) != 5 || report.Hosting[0].Provider != "BULLETPROOF-HOST, NL" || report.Hosting[3].ASN != "64502" {
+		t.Errorf("got hosting %+v", report.Hosting)
+	}
+	if !report.FastFlux.Suspected || report.FastFlux.Networks != 3 || len(report.FastFlux.Evidence) != 3 {
+		t.Errorf("got fast flux %+v", report.FastFlux)
+	}
+}
+
+func TestDNSClientServerFailure(t *testing.T) {
+	// nothing listens on a closed port, the lookup fails instead of hanging
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := conn.LocalAddr().String()
+	conn.Close()
+
+	report := CheckDNS(context.Background(), NewDNSClient(addr, 200*time.Millisecond, time.Second), "example.ir")
+	if len(report.Errors) == 0 || len(report.Addresses) != 0 {
+		t.Errorf("got report %+v from a dead resolver", report)
+	}
+}
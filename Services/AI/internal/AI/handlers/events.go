@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+	"github.com/gorilla/mux"
+)
+
+// sseWriter serializes scan events as server-sent events, the pipeline emits from several goroutines
+type sseWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (s *sseWriter) send(event models.ScanEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode scan event: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event.Type, data)
+	s.flusher.Flush()
+}
+
+// ScanEvents handles GET requests running a scan while streaming its progress as server-sent events
+func (h *AIHandler) ScanEvents(w http.ResponseWriter, r *http.Request) {
+	urlterm, ok := mux.Vars(r)["url"]
+	if !ok {
+		http.Error(w, "Missing url term in the request", http.StatusBadRequest)
+		return
+	}
+	// the scan fetches the url as asked and stores it under the key of the site
+	if _, err := siteKey(urlterm); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts, err := scanOptionsFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	stream := &sseWriter{w: w, flusher: flusher}
+
+	result, err := h.ScanURLWithOptions(r.Context(), urlterm, opts, stream.send)
+	if err != nil {
+		log.Printf("Streaming scan of %s went wrong : %v", urlterm, err)
+		stream.send(models.ScanEvent{Type: models.EventError, Error: err.Error()})
+		return
+	}
+
+	stream.send(models.ScanEvent{Type: models.EventResult, Data: result})
+}
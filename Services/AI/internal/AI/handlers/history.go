@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+	"github.com/gorilla/mux"
+)
+
+// limitParam reads the optional limit query parameter, capped at 100 for safety
+func limitParam(r *http.Request, def int) (int, error) {
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return def, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		return 0, err
+	}
+	if limit <= 0 || limit > 100 {
+		return def, nil
+	}
+	return limit, nil
+}
+
+// thresholdParam reads the optional sharp drop threshold query parameter
+func thresholdParam(r *http.Request) (int, error) {
+	thresholdStr := r.URL.Query().Get("threshold")
+	if thresholdStr == "" {
+		return models.DefaultSharpDropThreshold, nil
+	}
+	return strconv.Atoi(thresholdStr)
+}
+
+// GetScanHistory handles GET requests listing every stored scan of a url, newest first
+func (h *AIHandler) GetScanHistory(w http.ResponseWriter, r *http.Request) {
+	urlterm, ok := mux.Vars(r)["url"]
+	if !ok {
+		http.Error(w, "Missing url term in the request", http.StatusBadRequest)
+		return
+	}
+	urlterm, err := siteKey(urlterm)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := limitParam(r, 20)
+	if err != nil {
+		http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.PostgreSQL.GetScanHistory(r.Context(), urlterm, limit)
+	if err != nil {
+		log.Printf("Failed to retrieve scan history of %s: %v", urlterm, err)
+		http.Error(w, "Failed to retrieve scan history", http.StatusInternalServerError)
+		return
+	}
+
+	// the inputs are large, clients ask for them explicitly
+	if r.URL.Query().Get("inputs") != "true" {
+		for i := range entries {
+			entries[i].Inputs = nil
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"status": "success",
+		"url":    urlterm,
+		"count":  len(entries),
+		"data":   entries,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetScanDiff handles GET requests comparing consecutive scans of a url. limit is the
+// number of scans to compare, by default the latest two.
+func (h *AIHandler) GetScanDiff(w http.ResponseWriter, r *http.Request) {
+	urlterm, ok := mux.Vars(r)["url"]
+	if !ok {
+		http.Error(w, "Missing url term in the request", http.StatusBadRequest)
+		return
+	}
+	urlterm, err := siteKey(urlterm)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := limitParam(r, 2)
+	if err != nil {
+		http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+		return
+	}
+	threshold, err := thresholdParam(r)
+	if err != nil {
+		http.Error(w, "Invalid threshold parameter", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.PostgreSQL.GetScanHistory(r.Context(), urlterm, limit)
+	if err != nil {
+		log.Printf("Failed to retrieve scan history of %s: %v", urlterm, err)
+		http.Error(w, "Failed to retrieve scan history", http.StatusInternalServerError)
+		return
+	}
+	if len(entries) == 0 {
+		http.Error(w, "No scans found for this url", http.StatusNotFound)
+		return
+	}
+
+	diffs := models.DiffHistory(entries, threshold)
+	trend := make([]int, len(entries))
+	for i, entry := range entries {
+		trend[len(entries)-1-i] = entry.TrustScore
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"status":     "success",
+		"url":        urlterm,
+		"count":      len(diffs),
+		"trend":      trend,
+		"sharp_drop": len(diffs) > 0 && diffs[0].SharpDrop,
+		"data":       diffs,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetFlaggedURLs handles GET requests listing urls whose latest scan dropped sharply, for moderators to re-review
+func (h *AIHandler) GetFlaggedURLs(w http.ResponseWriter, r *http.Request) {
+	limit, err := limitParam(r, 20)
+	if err != nil {
+		http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+		return
+	}
+	threshold, err := thresholdParam(r)
+	if err != nil || threshold <= 0 {
+		http.Error(w, "Invalid threshold parameter", http.StatusBadRequest)
+		return
+	}
+
+	drops, err := h.PostgreSQL.GetSharpDrops(r.Context(), threshold, limit)
+	if err != nil {
+		log.Printf("Failed to retrieve score drops: %v", err)
+		http.Error(w, "Failed to retrieve flagged urls", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"status":    "success",
+		"threshold": threshold,
+		"count":     len(drops),
+		"data":      drops,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
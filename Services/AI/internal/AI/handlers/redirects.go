@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/safedial"
+)
+
+const (
+	// crawlerUserAgent is what cloaking sites look for to show search engines a clean page
+	crawlerUserAgent = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+	browserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+	maxRedirectHops = 10
+	// maxPageSize bounds how much of a page is read to look for html redirects
+	maxPageSize     = 2 << 20
+	redirectTimeout = 30 * time.Second
+)
+
+// redirectClient returns a client that stops at every redirect so each hop is recorded
+func redirectClient(dialer *safedial.Dialer) *http.Client {
+	return &http.Client{
+		Transport: dialer.Transport(),
+		Timeout:   redirectTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// TraceRedirects follows site the way a client with userAgent would, through http
+// redirects, meta refreshes and script redirects, and returns the chain with the
+// words of the final page
+func TraceRedirects(ctx context.Context, client *http.Client, site, userAgent string) (*scraperModels.RedirectChain, []string) {
+	current, err := scraperModels.ParseSiteURL(site)
+	if err != nil {
+		chain := scraperModels.NewRedirectChain(site)
+		chain.Error = err.Error()
+		return chain, nil
+	}
+	chain := scraperModels.NewRedirectChain(current.String())
+
+	for hops := 0; ; hops++ {
+		if hops > maxRedirectHops {
+			chain.Error = fmt.Sprintf("stopped after %d redirects", maxRedirectHops)
+			return chain, nil
+		}
+		if err := safedial.CheckScheme(current); err != nil {
+			chain.Error = err.Error()
+			return chain, nil
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, current.String(), nil)
+		if err != nil {
+			chain.Error = err.Error()
+			return chain, nil
+		}
+		req.Header.Set("User-Agent", userAgent)
+
+		res, err := client.Do(req)
+		if err != nil {
+			chain.Error = err.Error()
+			return chain, nil
+		}
+		body, err := io.ReadAll(io.LimitReader(res.Body, maxPageSize))
+		res.Body.Close()
+		if err != nil {
+			chain.Error = err.Error()
+			return chain, nil
+		}
+
+		location, kind := "", ""
+		if res.StatusCode >= 300 && res.StatusCode < 400 && res.Header.Get("Location") != "" {
+			location, kind = res.Header.Get("Location"), scraperModels.RedirectHTTP
+		} else if res.StatusCode < 300 {
+			var possible []string
+			location, kind, possible = scraperModels.DetectHTMLRedirect(body)
+			chain.AddPossible(current, possible)
+		}
+
+		next, err := current.Parse(location)
+		// a page refreshing itself is not a redirect
+		if location == "" || err != nil || next.String() == current.String() {
+			chain.AddHop(current, res.StatusCode, "", "")
+			return chain, scraperModels.PageText(body)
+		}
+
+		chain.AddHop(current, res.StatusCode, kind, location)
+		current = next
+	}
+}
+
+// CheckRedirects records the redirect chain a browser goes through and compares the
+// page it ends on with the one served to a crawler
+func CheckRedirects(ctx context.Context, client *http.Client, site string) *scraperModels.RedirectReport {
+	browser, browserText := TraceRedirects(ctx, client, site, browserUserAgent)
+	crawler, crawlerText := TraceRedirects(ctx, client, site, crawlerUserAgent)
+
+	return &scraperModels.RedirectReport{
+		Chain:    browser,
+		Cloaking: scraperModels.CompareFetches(crawler, crawlerText, browser, browserText),
+	}
+}
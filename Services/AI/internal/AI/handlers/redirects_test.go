@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/safedial"
+)
+
+func TestCheckRedirects(t *testing.T) {
+	landing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.UserAgent(), "Googlebot") {
+			fmt.Fprint(w, "<html><body>"+strings.Repeat("gardening tips and seasonal flowers ", 10)+"</body></html>")
+			return
+		}
+		fmt.Fprint(w, "<html><body>"+strings.Repeat("claim your prize now enter card number ", 10)+
+			`<script>document.forms[0].onsubmit = function() { location.href = "https://pay.example/"; }</script></body></html>`)
+	}))
+	defer landing.Close()
+
+	// localhost and 127.0.0.1 are different hosts for the chain
+	landingURL := strings.Replace(landing.URL, "127.0.0.1", "localhost", 1)
+
+	tracker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			http.Redirect(w, r, "/go", http.StatusFound)
+		case "/go":
+			fmt.Fprintf(w, `<html><head><meta http-equiv="Refresh" content="0; url=/js"></head></html>`)
+		case "/js":
+			fmt.Fprintf(w, `<html><script>window.location.href = "%s/offer";</script></html>`, landingURL)
+		}
+	}))
+	defer tracker.Close()
+
+	dialer := safedial.New(netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128"))
+	report := CheckRedirects(context.Background(), redirectClient(dialer), tracker.URL)
+
+	chain := report.Chain
+	if chain.Error != "" {
+		t.Fatalf("got chain error %s", chain.Error)
+	}
+	kinds := []string{}
+	for _, hop := range chain.Hops {
+		kinds = append(kinds, hop.Kind)
+	}
+	want := []string{scraperModels.RedirectHTTP, scraperModels.RedirectMetaRefresh, scraperModels.RedirectJavaScript, ""}
+	if strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Errorf("got hops %+v", chain.Hops)
+	}
+	if chain.Final != landingURL+"/offer" || chain.FinalStatus != http.StatusOK || chain.CrossDomainHops != 1 || chain.Redirects() != 3 {
+		t.Errorf("got chain %+v", chain)
+	}
+	// the submit handler is recorded but not followed
+	if len(chain.PossibleRedirects) != 1 || chain.PossibleRedirects[0] != "https://pay.example/" {
+		t.Errorf("got possible redirects %q", chain.PossibleRedirects)
+	}
+
+	if !report.Cloaking.Cloaked || len(report.Cloaking.Evidence) != 1 {
+		t.Errorf("got cloaking %+v", report.Cloaking)
+	}
+}
+
+func TestTraceRedirectsStopsLoops(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/again?"+r.URL.RawQuery+"x", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	dialer := safedial.New(netip.MustParsePrefix("127.0.0.0/8"))
+	chain, _ := TraceRedirects(context.Background(), redirectClient(dialer), srv.URL, browserUserAgent)
+	if chain.Error == "" || len(chain.Hops) != maxRedirectHops+1 {
+		t.Errorf("got %d hops and error %q", len(chain.Hops), chain.Error)
+	}
+}
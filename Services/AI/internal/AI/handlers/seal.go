@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+)
+
+// maxSeals bounds how many badges of a page have their certificate page fetched
+const maxSeals = 5
+
+// VerifyTrustSeals opens the certificate page of every badge found on site and checks it
+// names site. enamad is the certificate enamad.ir lists for the domain, nil when the
+// lookup failed.
+func VerifyTrustSeals(ctx context.Context, client *http.Client, site string, seals []scraperModels.TrustSeal, enamad *scraperModels.Enamad_Data) []scraperModels.SealVerification {
+	verifications := []scraperModels.SealVerification{}
+	for i, seal := range seals {
+		if i == maxSeals {
+			break
+		}
+		v := scraperModels.NewSealVerification(seal)
+		v.CheckEnamadRecord(enamad)
+		// a badge linking elsewhere is forged whatever its page says
+		if seal.Link != "" && !v.Forged {
+			if body, err := fetchCertificatePage(ctx, client, seal); err != nil {
+				v.Error = err.Error()
+			} else {
+				v.CheckCertificatePage(site, body)
+			}
+		}
+		verifications = append(verifications, *v)
+	}
+	return verifications
+}
+
+func fetchCertificatePage(ctx context.Context, client *http.Client, seal scraperModels.TrustSeal) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, seal.Link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", browserUserAgent)
+	// enamad only shows the certificate when the referrer is the site holding the badge
+	if seal.Page != "" {
+		req.Header.Set("Referer", seal.Page)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("certificate page answered %s", res.Status)
+	}
+	return io.ReadAll(io.LimitReader(res.Body, maxPageSize))
+}
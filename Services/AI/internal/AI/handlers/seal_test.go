@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+)
+
+func TestVerifyTrustSeals(t *testing.T) {
+	referrers := []string{}
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		referrers = append(referrers, r.Referer())
+		switch r.URL.Query().Get("id") {
+		case "1":
+			fmt.Fprint(w, `<html><body><table><tr><td>آدرس سایت</td><td>www.shop.ir</td></tr></table></body></html>`)
+		case "2":
+			fmt.Fprint(w, `<html><body><table><tr><td>آدرس سایت</td><td>another-shop.com</td></tr></table></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer issuer.Close()
+
+	// every host resolves to the stub issuer
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, issuer.Listener.Addr().String())
+		},
+	}}
+
+	seals := []scraperModels.TrustSeal{
+		{Kind: scraperModels.SealEnamad, Page: "https://shop.ir/", Link: "http://trustseal.enamad.ir/?id=1&Code=a", ID: "1"},
+		{Kind: scraperModels.SealSamandehi, Page: "https://shop.ir/", Link: "http://logo.samandehi.ir/Verify.aspx?id=2&p=b", ID: "2"},
+		{Kind: scraperModels.SealSamandehi, Page: "https://shop.ir/", Link: "http://logo.samandehi.ir/Verify.aspx?id=3&p=c", ID: "3"},
+		{Kind: scraperModels.SealEnamad, Page: "https://shop.ir/", Link: "http://enamad.shop.ir/?id=1", ID: "1"},
+	}
+	got := VerifyTrustSeals(context.Background(), client, "https://shop.ir", seals, &scraperModels.Enamad_Data{ID: 1})
+	if len(got) != 4 {
+		t.Fatalf("got %d verifications, wanted 4", len(got))
+	}
+	if !got[0].Verified || got[0].Forged {
+		t.Errorf("got %+v for the genuine badge", got[0])
+	}
+	if !got[1].Forged {
+		t.Errorf("got %+v for a badge of another shop", got[1])
+	}
+	if got[2].Forged || got[2].Verified || got[2].Error == "" {
+		t.Errorf("got %+v for a certificate page that failed", got[2])
+	}
+	// the lookalike issuer is never contacted
+	if !got[3].Forged || len(referrers) != 3 {
+		t.Errorf("got %+v after %d requests", got[3], len(referrers))
+	}
+	if referrers[0] != "https://shop.ir/" {
+		t.Errorf("got referrer %q, wanted the page holding the badge", referrers[0])
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/safedial"
+	"github.com/gorilla/mux"
+)
+
+// tlsTimeout bounds the connection and handshake with the scanned site
+const tlsTimeout = 15 * time.Second
+
+// CheckTLS makes a TLS handshake with site through dialer and describes its certificate.
+// roots are the trusted authorities, nil uses the system pool. A failed handshake is
+// reported in the Error field of the report.
+func CheckTLS(ctx context.Context, dialer *safedial.Dialer, roots *x509.CertPool, site string) *scraperModels.TLSReport {
+	u, err := scraperModels.ParseSiteURL(site)
+	if err != nil {
+		return &scraperModels.TLSReport{Host: site, SANs: []string{}, Validation: scraperModels.ValidationUnknown, Error: "invalid url"}
+	}
+	host := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+
+	failed := func(err error) *scraperModels.TLSReport {
+		return &scraperModels.TLSReport{Host: host, SANs: []string{}, Validation: scraperModels.ValidationUnknown, Error: err.Error()}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tlsTimeout)
+	defer cancel()
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return failed(err)
+	}
+	defer conn.Close()
+
+	// verification is done by AnalyzeTLS so that a bad certificate is still described
+	client := tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+	if err := client.HandshakeContext(ctx); err != nil {
+		return failed(err)
+	}
+
+	return scraperModels.AnalyzeTLS(host, client.ConnectionState(), roots, time.Now())
+}
+
+// GetTLSData handles GET requests describing the TLS certificate of a url
+func (h *AIHandler) GetTLSData(w http.ResponseWriter, r *http.Request) {
+	urlterm, ok := mux.Vars(r)["url"]
+	if !ok {
+		http.Error(w, "Missing url term in the request", http.StatusBadRequest)
+		return
+	}
+	urlterm, err := siteKey(urlterm)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report := CheckTLS(r.Context(), h.Dialer, nil, urlterm)
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"status": "success",
+		"url":    urlterm,
+		"issues": report.Issues(),
+		"data":   report,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/safedial"
+)
+
+func TestCheckTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	dialer := safedial.New(netip.MustParsePrefix("127.0.0.0/8"))
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	site := strings.TrimPrefix(srv.URL, "https://")
+
+	got := CheckTLS(context.Background(), dialer, roots, site)
+	if got.Error != "" {
+		t.Fatalf("CheckTLS returned an error: %s", got.Error)
+	}
+	if !got.Trusted || !got.CoversHost || got.Expired || got.Version == "" || len(got.SANs) == 0 {
+		t.Errorf("got %+v", got)
+	}
+	if issues := got.Issues(); len(issues) != 0 {
+		t.Errorf("got issues %v for a trusted certificate", issues)
+	}
+
+	// the test certificate is unknown to the system pool
+	untrusted := CheckTLS(context.Background(), dialer, nil, site)
+	if untrusted.Trusted || untrusted.VerifyError == "" || len(untrusted.Issues()) == 0 {
+		t.Errorf("got %+v for an untrusted certificate", untrusted)
+	}
+}
+
+func TestCheckTLSRefusesInternalAddresses(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	got := CheckTLS(context.Background(), safedial.New(), nil, strings.TrimPrefix(srv.URL, "https://"))
+	if got.Error == "" || got.Validation != scraperModels.ValidationUnknown {
+		t.Errorf("got %+v, wanted the connection to be refused", got)
+	}
+	if issues := got.Issues(); len(issues) != 1 || !strings.HasPrefix(issues[0], "no TLS connection") {
+		t.Errorf("got issues %v", issues)
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/llm"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+)
+
+// maxRepairAttempts is how many times the model is re-prompted after an invalid answer
+const maxRepairAttempts = 2
+
+const repairPromptFormat = `Your previous answer could not be used: %v
+
+Reply again with ONLY the JSON object in the exact format described in the instructions.
+trustScore must be an integer between 0 and 100, riskLevel one of "low", "medium" or "high",
+positivePoints and negativePoints arrays of strings, description a non-empty string and
+technicalFlags an object of integer weights between -100 and 100. Do not add any other fields.`
+
+// requestVerdict asks the model for a verdict and re-prompts it until the answer validates.
+// onToken, when not nil, receives the answers as they are streamed. onRetry, when not nil,
+// is called with the reason before every repair attempt so the streamed text can be discarded.
+func requestVerdict(ctx context.Context, provider llm.Provider, messages []llm.Message, onToken llm.TokenFunc, onRetry func(err error)) (*models.TrustVerdict, error) {
+	var lastErr error
+
+	for attempt := 0; attempt <= maxRepairAttempts; attempt++ {
+		if attempt > 0 && onRetry != nil {
+			onRetry(lastErr)
+		}
+		response, err := llm.CompleteStreaming(ctx, provider, messages, onToken)
+		if err != nil {
+			return nil, fmt.Errorf("%s completion failed: %v", provider.Name(), err)
+		}
+
+		verdict, err := models.ParseTrustVerdict(response.Content)
+		if err == nil {
+			return verdict, nil
+		}
+
+		log.Printf("Invalid verdict from %s on attempt %d: %v", provider.Name(), attempt+1, err)
+		lastErr = err
+		messages = append(messages,
+			llm.Message{Role: "assistant", Content: response.Content},
+			llm.Message{Role: "user", Content: fmt.Sprintf(repairPromptFormat, err)},
+		)
+	}
+
+	return nil, fmt.Errorf("no valid verdict after %d attempts: %v", maxRepairAttempts+1, lastErr)
+}
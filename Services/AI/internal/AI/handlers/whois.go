@@ -1,56 +1,428 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Databases"
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/config"
 	"github.com/gorilla/mux"
 	"github.com/likexian/whois"
 	whoisparser "github.com/likexian/whois-parser"
 )
 
-func Whois(url string) whoisparser.WhoisInfo {
+var (
+	// ErrInvalidDomain is returned for urls without a registrable domain
+	ErrInvalidDomain = errors.New("invalid domain")
+	// ErrDomainNotFound is returned when the registry has no such domain
+	ErrDomainNotFound = errors.New("domain is not registered")
+	// ErrWhoisUnavailable is returned when neither RDAP nor whois gave an answer
+	ErrWhoisUnavailable = errors.New("registration data is unavailable")
 
-	raw_whois, err := whois.Whois(url)
+	errNoRDAPServer = errors.New("no RDAP server for this top level domain")
+)
+
+// bootstrapRetryDelay is how long a failed load of the RDAP bootstrap registry is not retried
+const bootstrapRetryDelay = 5 * time.Minute
+
+// maxRDAPSize bounds the RDAP answers and the bootstrap registry read
+const maxRDAPSize = 1 << 20
+
+// contextDialer connects the whois client with ctx and closes the connection once ctx is done
+type contextDialer struct {
+	ctx context.Context
+}
+
+func (d contextDialer) Dial(network, address string) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(d.ctx, network, address)
 	if err != nil {
-		//	http.Error(w, "Url is not valid!", http.StatusBadRequest)
-		log.Println(err)
+		return nil, err
+	}
+	context.AfterFunc(d.ctx, func() { conn.Close() })
+	return conn, nil
+}
+
+// WhoisCache stores registration data between scans, Databases.PostgreSQL implements it
+type WhoisCache interface {
+	GetWhois(ctx context.Context, domain string) (*models.WhoisRecord, error)
+	SaveWhois(ctx context.Context, record *models.WhoisRecord) error
+}
+
+// WhoisService looks the registration data of domains up over RDAP, falls back to port 43
+// whois when RDAP is not available, and caches the answers
+type WhoisService struct {
+	HTTP *http.Client
+	// RDAPBootstrap is the IANA registry of RDAP servers by top level domain
+	RDAPBootstrap string
+	// RDAPServer is asked about every domain when set, instead of the bootstrap servers
+	RDAPServer string
+	// WhoisServer is the host[:port] asked over port 43 when set, instead of the registry's
+	WhoisServer string
+	Timeout     time.Duration
+	// Cache is optional, records younger than CacheTTL are served from it
+	Cache    WhoisCache
+	CacheTTL time.Duration
+
+	mu          sync.Mutex
+	rdapServers map[string]string
+	// bootstrapErr is the last failed load of the bootstrap registry, made at bootstrapFailedAt
+	bootstrapErr      error
+	bootstrapFailedAt time.Time
+}
+
+// NewWhoisService returns a service configured by cfg, cache may be nil
+func NewWhoisService(cfg config.WhoisConfig, cache WhoisCache) *WhoisService {
+	return &WhoisService{
+		HTTP:          &http.Client{Timeout: cfg.Timeout},
+		RDAPBootstrap: cfg.RDAPBootstrap,
+		RDAPServer:    cfg.RDAPServer,
+		WhoisServer:   cfg.Server,
+		Timeout:       cfg.Timeout,
+		Cache:         cache,
+		CacheTTL:      cfg.CacheTTL,
 	}
+}
 
-	result, err := whoisparser.Parse(raw_whois)
+// whoisDomain is the registered domain of site, the name registries know
+func whoisDomain(site string) (string, error) {
+	identity, err := scraperModels.NormalizeURL(site)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidDomain, err)
+	}
+	return identity.Domain, nil
+}
 
+// Lookup returns the registration data of the domain of site, from the cache while it is fresh
+func (s *WhoisService) Lookup(ctx context.Context, site string) (*models.WhoisRecord, error) {
+	domain, err := whoisDomain(site)
 	if err != nil {
-		//	http.Error(w, "whois didn't parse correctly", http.StatusBadRequest)
-		log.Println(err)
+		return nil, err
+	}
+
+	if s.Cache != nil && s.CacheTTL > 0 {
+		cached, err := s.Cache.GetWhois(ctx, domain)
+		switch {
+		case err == nil && time.Since(cached.FetchedAt) < s.CacheTTL:
+			cached.Cached = true
+			return cached, nil
+		case err != nil && !errors.Is(err, Databases.ErrNotFound):
+			log.Printf("Reading the cached whois of %s went wrong : %v", domain, err)
+		}
+	}
 
+	lookupCtx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	record, err := s.rdap(lookupCtx, domain)
+	// a registry answering over RDAP that it has no such domain is not asked again
+	if err != nil && !errors.Is(err, ErrDomainNotFound) {
+		if !errors.Is(err, errNoRDAPServer) {
+			log.Printf("RDAP lookup of %s failed, falling back to whois : %v", domain, err)
+		}
+		record, err = s.whois(lookupCtx, domain)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return result
+	record.Domain = domain
+	record.FetchedAt = time.Now()
+	record.ResolveDates()
 
+	if s.Cache != nil && s.CacheTTL > 0 {
+		if err := s.Cache.SaveWhois(ctx, record); err != nil {
+			log.Printf("Caching the whois of %s went wrong : %v", domain, err)
+		}
+	}
+	return record, nil
+}
+
+// whois queries the port 43 server of the registry, or WhoisServer when set
+func (s *WhoisService) whois(ctx context.Context, domain string) (*models.WhoisRecord, error) {
+	client := whois.NewClient().SetDialer(contextDialer{ctx: ctx}).SetTimeout(s.Timeout).SetDisableStats(true)
+	raw, err := client.Whois(domain, s.WhoisServer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: whois: %v", ErrWhoisUnavailable, err)
+	}
+
+	info, err := whoisparser.Parse(raw)
+	switch {
+	case errors.Is(err, whoisparser.ErrNotFoundDomain):
+		return nil, ErrDomainNotFound
+	case err != nil:
+		return nil, fmt.Errorf("%w: whois: %v", ErrWhoisUnavailable, err)
+	}
+
+	server := s.WhoisServer
+	if server == "" && info.Domain != nil {
+		server = info.Domain.WhoisServer
+	}
+	return &models.WhoisRecord{Source: models.WhoisSourceWhois, Server: server, Info: info}, nil
 }
 
-func GetWhoisData(w http.ResponseWriter, r *http.Request) {
+// rdapServer returns the RDAP base url of the top level domain of domain
+func (s *WhoisService) rdapServer(ctx context.Context, domain string) (string, error) {
+	if s.RDAPServer != "" {
+		return s.RDAPServer, nil
+	}
+	if s.RDAPBootstrap == "" {
+		return "", errNoRDAPServer
+	}
 
-	// getting target url from the request
-	vars := mux.Vars(r)
+	s.mu.Lock()
+	servers, lastErr, failedAt := s.rdapServers, s.bootstrapErr, s.bootstrapFailedAt
+	s.mu.Unlock()
 
-	url, ok := vars["url"]
+	if servers == nil {
+		// a registry that just failed is not asked again by every lookup
+		if lastErr != nil && time.Since(failedAt) < bootstrapRetryDelay {
+			return "", lastErr
+		}
+		// the registry is loaded without the lock, concurrent loads only publish the same map
+		loaded, err := s.loadBootstrap(ctx)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err != nil {
+			// a lookup running out of time says nothing about the registry
+			if ctx.Err() == nil {
+				s.bootstrapErr, s.bootstrapFailedAt = err, time.Now()
+			}
+			return "", err
+		}
+		s.rdapServers, s.bootstrapErr = loaded, nil
+		servers = loaded
+	}
+
+	server, ok := servers[domain[strings.LastIndex(domain, ".")+1:]]
+	if !ok {
+		return "", errNoRDAPServer
+	}
+	return server, nil
+}
+
+// loadBootstrap reads the IANA registry, its services are [[tlds...], [urls...]] pairs
+func (s *WhoisService) loadBootstrap(ctx context.Context) (map[string]string, error) {
+	var bootstrap struct {
+		Services [][][]string `json:"services"`
+	}
+	if err := s.getJSON(ctx, s.RDAPBootstrap, &bootstrap); err != nil {
+		return nil, fmt.Errorf("loading the RDAP bootstrap registry: %v", err)
+	}
+
+	servers := map[string]string{}
+	for _, service := range bootstrap.Services {
+		if len(service) != 2 || len(service[1]) == 0 {
+			continue
+		}
+		// https is preferred when a registry offers both
+		base := service[1][0]
+		for _, u := range service[1] {
+			if strings.HasPrefix(u, "https://") {
+				base = u
+				break
+			}
+		}
+		for _, tld := range service[0] {
+			servers[strings.ToLower(tld)] = base
+		}
+	}
+	return servers, nil
+}
+
+// errHTTPNotFound marks a 404 answer of getJSON
+var errHTTPNotFound = errors.New("not found")
+
+func (s *WhoisService) getJSON(ctx context.Context, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/rdap+json, application/json")
+
+	res, err := s.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return errHTTPNotFound
+	case res.StatusCode != http.StatusOK:
+		return fmt.Errorf("%s answered %s", rawURL, res.Status)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, maxRDAPSize)).Decode(v)
+}
+
+// rdapDomain is the part of an RDAP domain answer kept in a WhoisRecord
+type rdapDomain struct {
+	LDHName string   `json:"ldhName"`
+	Handle  string   `json:"handle"`
+	Status  []string `json:"status"`
+	Events  []struct {
+		Action string `json:"eventAction"`
+		Date   string `json:"eventDate"`
+	} `json:"events"`
+	Entities    []rdapEntity `json:"entities"`
+	Nameservers []struct {
+		LDHName string `json:"ldhName"`
+	} `json:"nameservers"`
+	SecureDNS struct {
+		DelegationSigned bool `json:"delegationSigned"`
+	} `json:"secureDNS"`
+}
+
+type rdapEntity struct {
+	Handle   string            `json:"handle"`
+	Roles    []string          `json:"roles"`
+	VCard    []json.RawMessage `json:"vcardArray"`
+	Entities []rdapEntity      `json:"entities"`
+}
+
+// vcard returns the text value of property in the jCard of the entity
+func (e rdapEntity) vcard(property string) string {
+	if len(e.VCard) < 2 {
+		return ""
+	}
+	var props [][]json.RawMessage
+	if err := json.Unmarshal(e.VCard[1], &props); err != nil {
+		return ""
+	}
+	for _, prop := range props {
+		var name, value string
+		if len(prop) < 4 || json.Unmarshal(prop[0], &name) != nil || name != property {
+			continue
+		}
+		if json.Unmarshal(prop[3], &value) == nil {
+			return value
+		}
+	}
+	return ""
+}
+
+// contact returns the first entity with role as a whois contact, nested entities included
+func contact(entities []rdapEntity, role string) *whoisparser.Contact {
+	for _, e := range entities {
+		for _, r := range e.Roles {
+			if r == role {
+				return &whoisparser.Contact{ID: e.Handle, Name: e.vcard("fn"), Organization: e.vcard("org"), Email: e.vcard("email")}
+			}
+		}
+		if c := contact(e.Entities, role); c != nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// rdap asks the RDAP server of the registry about domain
+func (s *WhoisService) rdap(ctx context.Context, domain string) (*models.WhoisRecord, error) {
+	server, err := s.rdapServer(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var answer rdapDomain
+	err = s.getJSON(ctx, strings.TrimSuffix(server, "/")+"/domain/"+url.PathEscape(domain), &answer)
+	if errors.Is(err, errHTTPNotFound) {
+		return nil, ErrDomainNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rdap: %v", err)
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(answer.LDHName, "."))
+	if name == "" {
+		name = domain
+	}
+	info := whoisparser.WhoisInfo{
+		Domain: &whoisparser.Domain{
+			ID:        answer.Handle,
+			Domain:    name,
+			Punycode:  name,
+			Name:      name[:strings.Index(name+".", ".")],
+			Extension: name[strings.Index(name+".", ".")+1:],
+			Status:    answer.Status,
+			DNSSec:    answer.SecureDNS.DelegationSigned,
+		},
+		Registrar:  contact(answer.Entities, "registrar"),
+		Registrant: contact(answer.Entities, "registrant"),
+	}
+	for _, ns := range answer.Nameservers {
+		info.Domain.NameServers = append(info.Domain.NameServers, strings.ToLower(ns.LDHName))
+	}
+	for _, event := range answer.Events {
+		switch event.Action {
+		case "registration":
+			info.Domain.CreatedDate = event.Date
+		case "last changed":
+			info.Domain.UpdatedDate = event.Date
+		case "expiration":
+			info.Domain.ExpirationDate = event.Date
+		}
+	}
+
+	return &models.WhoisRecord{Source: models.WhoisSourceRDAP, Server: server, Info: info}, nil
+}
+
+// whoisStatus is the http status answering a failed lookup
+func whoisStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidDomain):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrDomainNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// GetWhoisData handles GET requests for the registration data of a url
+func (h *AIHandler) GetWhoisData(w http.ResponseWriter, r *http.Request) {
+	urlterm, ok := mux.Vars(r)["url"]
 	if !ok {
 		http.Error(w, "Missing url term in the request", http.StatusBadRequest)
 		return
 	}
+	urlterm, err := siteKey(urlterm)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	result := Whois(url)
-
-	jsonResult, err := json.MarshalIndent(result, "", "  ")
+	record, err := h.Whois.Lookup(r.Context(), urlterm)
 	if err != nil {
-		//	http.Error(w, "Failed to marshal JSON", http.StatusInternalServerError)
+		log.Printf("Whois lookup of %s failed : %v", urlterm, err)
+		http.Error(w, err.Error(), whoisStatus(err))
 		return
 	}
 
-	// Set content type and write JSON
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(jsonResult)
+	response := map[string]interface{}{
+		"status":     "success",
+		"url":        urlterm,
+		"domain_age": record.DomainAge(time.Now()),
+		"data":       record,
+	}
 
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
 }
@@ -1,25 +1,242 @@
 package handlers
 
 import (
-	"encoding/json"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Databases"
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	"github.com/gorilla/mux"
 )
 
-func TestWhois(t *testing.T) {
-	whoisData := Whois("example.ir")
-	jsonWhoisData, _ := json.MarshalIndent(whoisData, "", "  ")
-	got := string(jsonWhoisData)
-
-	want := `{
-  "domain": {
-    "domain": "example.ir",
-    "punycode": "example.ir",
-    "name": "example",
-    "extension": "ir"
-  }
+const rdapExample = `{
+  "objectClassName": "domain",
+  "handle": "2336799_DOMAIN_COM-VRSN",
+  "ldhName": "EXAMPLE.COM",
+  "status": ["client delete prohibited"],
+  "events": [
+    {"eventAction": "registration", "eventDate": "1995-08-14T04:00:00Z"},
+    {"eventAction": "expiration", "eventDate": "2030-08-13T04:00:00Z"}
+  ],
+  "entities": [{
+    "roles": ["registrar"],
+    "handle": "376",
+    "vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "RESERVED-Internet Assigned Numbers Authority"]]]
+  }],
+  "nameservers": [{"ldhName": "A.IANA-SERVERS.NET"}]
 }`
-	if got != want {
-		t.Errorf("got %v, wanted %v", got, want)
+
+const irnicExample = `% This is the IRNIC Whois server v1.6.2.
+
+domain:		shop.ir
+ascii:		shop.ir
+holder-c:	sh100-irnic
+nserver:	ns1.shop.ir
+last-updated:	2024-01-02
+expire-date:	2026-01-02
+source:		IRNIC # Filtered
+`
+
+const comExample = `Domain Name: BROKEN.COM
+Registrar: Example Registrar, Inc.
+Creation Date: 2019-03-04T05:06:07Z
+Registry Expiry Date: 2030-03-04T05:06:07Z
+`
+
+// stubRegistry serves an RDAP bootstrap listing only .com, an RDAP server and a port 43 server
+func stubRegistry(t *testing.T) *WhoisService {
+	var rdap *httptest.Server
+	rdap = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/dns.json":
+			fmt.Fprintf(w, `{"services": [[["com", "net"], ["%s/rdap/"]]]}`, rdap.URL)
+		case "/rdap/domain/example.com":
+			w.Header().Set("Content-Type", "application/rdap+json")
+			fmt.Fprint(w, rdapExample)
+		case "/rdap/domain/broken.com":
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(rdap.Close)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			query, _ := bufio.NewReader(conn).ReadString('\n')
+			switch strings.TrimSpace(query) {
+			case "shop.ir":
+				fmt.Fprint(conn, irnicExample)
+			case "broken.com":
+				fmt.Fprint(conn, comExample)
+			default:
+				io.WriteString(conn, "%ERROR:101: no entries found\n")
+			}
+			conn.Close()
+		}
+	}()
+
+	return &WhoisService{
+		HTTP:          rdap.Client(),
+		RDAPBootstrap: rdap.URL + "/dns.json",
+		WhoisServer:   listener.Addr().String(),
+		Timeout:       5 * time.Second,
+	}
+}
+
+func TestWhoisServiceLookup(t *testing.T) {
+	service := stubRegistry(t)
+	now := time.Date(2025, 8, 14, 4, 0, 0, 0, time.UTC)
+
+	record, err := service.Lookup(context.Background(), "https://www.example.com/about")
+	if err != nil {
+		t.Fatalf("rdap lookup failed: %v", err)
+	}
+	if record.Source != models.WhoisSourceRDAP || record.Domain != "example.com" || record.Info.Registrar.Name != "RESERVED-Internet Assigned Numbers Authority" {
+		t.Errorf("got record %+v", record)
+	}
+	if age := record.DomainAge(now); age != 10958 {
+		t.Errorf("got domain age %d, wanted 10958", age)
+	}
+
+	// the registry of .com fails over RDAP, port 43 answers instead
+	record, err = service.Lookup(context.Background(), "broken.com")
+	if err != nil {
+		t.Fatalf("whois fallback failed: %v", err)
+	}
+	if record.Source != models.WhoisSourceWhois || record.CreatedAt == nil || record.CreatedAt.Year() != 2019 {
+		t.Errorf("got record %+v", record)
+	}
+
+	// .ir has no RDAP server, IRNIC publishes no creation date
+	record, err = service.Lookup(context.Background(), "shop.ir")
+	if err != nil {
+		t.Fatalf("whois lookup failed: %v", err)
+	}
+	if record.Source != models.WhoisSourceWhois || record.ExpiresAt == nil || record.DomainAge(now) != scraperModels.DomainAgeUnknown {
+		t.Errorf("got record %+v", record)
+	}
+}
+
+func TestWhoisServiceErrors(t *testing.T) {
+	service := stubRegistry(t)
+
+	tests := []struct {
+		site   string
+		status int
+	}{
+		{"missing.com", http.StatusNotFound},
+		{"unknown.ir", http.StatusNotFound},
+		{"localhost", http.StatusBadRequest},
+		{"127.0.0.1", http.StatusBadRequest},
 	}
 
+	h := &AIHandler{Whois: service}
+	for _, tt := range tests {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/whois/"+tt.site, nil), map[string]string{"url": tt.site})
+		rec := httptest.NewRecorder()
+		h.GetWhoisData(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("got status %d for %s, wanted %d: %s", rec.Code, tt.site, tt.status, rec.Body.String())
+		}
+	}
+
+	service.WhoisServer = "127.0.0.1:1"
+	if _, err := service.Lookup(context.Background(), "shop.ir"); whoisStatus(err) != http.StatusBadGateway {
+		t.Errorf("got %v when no whois server answers", err)
+	}
+}
+
+func TestRDAPBootstrapBackoff(t *testing.T) {
+	var service *WhoisService
+	loads, up := 0, false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loads++
+		// the registry is fetched without holding the lock of the service
+		if !service.mu.TryLock() {
+			t.Error("the lock is held while the bootstrap registry loads")
+		} else {
+			service.mu.Unlock()
+		}
+		if !up {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"services": [[["com"], ["https://rdap.example/"]]]}`)
+	}))
+	defer srv.Close()
+	service = &WhoisService{HTTP: srv.Client(), RDAPBootstrap: srv.URL}
+
+	for i := 0; i < 2; i++ {
+		if _, err := service.rdapServer(context.Background(), "example.com"); err == nil {
+			t.Fatal("expected an error while the bootstrap registry fails")
+		}
+	}
+	if loads != 1 {
+		t.Errorf("got %d loads, a failed registry must not be asked again right away", loads)
+	}
+
+	up = true
+	service.bootstrapFailedAt = time.Now().Add(-bootstrapRetryDelay)
+	if server, err := service.rdapServer(context.Background(), "example.com"); err != nil || server != "https://rdap.example/" {
+		t.Errorf("got %q, %v once the registry is back", server, err)
+	}
+	if loads != 2 {
+		t.Errorf("got %d loads, wanted 2", loads)
+	}
+}
+
+// memoryWhoisCache is a WhoisCache kept in memory
+type memoryWhoisCache map[string]*models.WhoisRecord
+
+func (c memoryWhoisCache) GetWhois(ctx context.Context, domain string) (*models.WhoisRecord, error) {
+	record, ok := c[domain]
+	if !ok {
+		return nil, Databases.ErrNotFound
+	}
+	copied := *record
+	return &copied, nil
+}
+
+func (c memoryWhoisCache) SaveWhois(ctx context.Context, record *models.WhoisRecord) error {
+	c[record.Domain] = record
+	return nil
+}
+
+func TestWhoisServiceCache(t *testing.T) {
+	service := stubRegistry(t)
+	cache := memoryWhoisCache{}
+	service.Cache, service.CacheTTL = cache, time.Hour
+
+	if _, err := service.Lookup(context.Background(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	record, err := service.Lookup(context.Background(), "example.com")
+	if err != nil || !record.Cached {
+		t.Fatalf("got %+v, %v, wanted the cached record", record, err)
+	}
+
+	// a stale record is fetched again
+	cache["example.com"].FetchedAt = time.Now().Add(-2 * time.Hour)
+	if record, err = service.Lookup(context.Background(), "example.com"); err != nil || record.Cached {
+		t.Errorf("got %+v, %v, wanted a fresh record", record, err)
+	}
 }
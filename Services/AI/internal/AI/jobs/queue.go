@@ -0,0 +1,327 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+)
+
+var ErrQueueFull = errors.New("scan queue is full")
+
+// Store persists jobs so they survive a restart
+type Store interface {
+	CreateScanJob(ctx context.Context, job *models.ScanJob) error
+	UpdateScanJob(ctx context.Context, job *models.ScanJob) error
+	GetScanJob(ctx context.Context, id string) (*models.ScanJob, error)
+	ListUnfinishedScanJobs(ctx context.Context) ([]*models.ScanJob, error)
+	// CreateScanBatch saves a batch and its jobs at once, none of them is saved on an error
+	CreateScanBatch(ctx context.Context, batch *models.ScanBatch, jobs []*models.ScanJob) error
+	GetScanBatch(ctx context.Context, id string) (*models.ScanBatch, error)
+	ListScanJobs(ctx context.Context, ids []string) ([]*models.ScanJob, error)
+}
+
+// ScanFunc runs the scan pipeline for a url and returns the JSON result
+type ScanFunc func(ctx context.Context, url string, events models.EventFunc) (json.RawMessage, error)
+
+// Queue runs scan jobs on a bounded pool of workers. Single scans wait in pending and are
+// refused when it is full, batch and resumed jobs are handed to the workers through
+// background only when no single scan is waiting, so they never take the room of pending.
+type Queue struct {
+	store      Store
+	scan       ScanFunc
+	workers    int
+	pending    chan string
+	background chan string
+
+	// JobTimeout bounds a single scan, the pipeline can take minutes
+	JobTimeout time.Duration
+
+	wg   sync.WaitGroup
+	stop chan struct{}
+	// done is closed with the context of the workers, nil before Start
+	done     <-chan struct{}
+	stopOnce sync.Once
+	// cancel interrupts the running scans when a shutdown runs out of time
+	cancel context.CancelFunc
+}
+
+func NewQueue(store Store, scan ScanFunc, workers, capacity int) *Queue {
+	if workers <= 0 {
+		workers = 1
+	}
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &Queue{
+		store:      store,
+		scan:       scan,
+		workers:    workers,
+		pending:    make(chan string, capacity),
+		background: make(chan string),
+		JobTimeout: 5 * time.Minute,
+		stop:       make(chan struct{}),
+	}
+}
+
+// Start launches the workers and requeues the jobs left unfinished by a previous run.
+// Workers stop when ctx is cancelled or Shutdown is called.
+func (q *Queue) Start(ctx context.Context) error {
+	unfinished, err := q.store.ListUnfinishedScanJobs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load unfinished scan jobs: %v", err)
+	}
+
+	ctx, q.cancel = context.WithCancel(ctx)
+	q.done = ctx.Done()
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+
+	if len(unfinished) > 0 {
+		log.Printf("Resuming %d unfinished scan jobs", len(unfinished))
+		// the backlog may be larger than the queue, feed it without blocking Start
+		ids := make([]string, len(unfinished))
+		for i, job := range unfinished {
+			ids[i] = job.ID
+		}
+		q.feed(ids)
+	}
+
+	return nil
+}
+
+// feed schedules ids in the background, as fast as the workers are free of single scans
+func (q *Queue) feed(ids []string) {
+	go func() {
+		for _, id := range ids {
+			select {
+			case q.background <- id:
+			case <-q.done:
+				return
+			case <-q.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Wait blocks until every worker has returned
+func (q *Queue) Wait() {
+	q.wg.Wait()
+}
+
+// Shutdown stops taking jobs from the queue and waits for the running scans to finish.
+// When ctx is done first the running scans are cancelled, they stay running in the store
+// and are resumed by the next Start. Queued jobs are left for the next Start as well.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.stopOnce.Do(func() { close(q.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		if q.cancel != nil {
+			q.cancel()
+		}
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Enqueue persists a new job for url and schedules it
+func (q *Queue) Enqueue(ctx context.Context, url string) (*models.ScanJob, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	job := models.NewScanJob(id, url)
+	if len(q.pending) == cap(q.pending) {
+		return nil, ErrQueueFull
+	}
+	if err := q.store.CreateScanJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to save scan job: %v", err)
+	}
+
+	select {
+	case q.pending <- job.ID:
+		return job, nil
+	default:
+		job.Status = models.StatusFailed
+		job.Error = ErrQueueFull.Error()
+		q.save(job)
+		return nil, ErrQueueFull
+	}
+}
+
+// EnqueueBatch persists a batch with a job for every url and schedules the jobs. The urls
+// found in cached are not scanned again, their job is done with the cached result. Jobs of
+// a batch wait for a worker free of single scans instead of failing when the queue is full.
+func (q *Queue) EnqueueBatch(ctx context.Context, urls []string, cached map[string]json.RawMessage) (*models.ScanBatch, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	batch := &models.ScanBatch{ID: id, JobIDs: []string{}, CreatedAt: time.Now()}
+
+	jobs := make([]*models.ScanJob, 0, len(urls))
+	pending := []string{}
+	for _, url := range urls {
+		id, err := newJobID()
+		if err != nil {
+			return nil, err
+		}
+
+		job := models.NewScanJob(id, url)
+		if result, ok := cached[url]; ok {
+			for _, stage := range models.ScanStages {
+				job.SetStage(stage, models.StatusSkipped, nil)
+			}
+			job.Status = models.StatusDone
+			job.Result = result
+		}
+		jobs = append(jobs, job)
+		batch.JobIDs = append(batch.JobIDs, job.ID)
+		if job.Status == models.StatusQueued {
+			pending = append(pending, job.ID)
+		}
+	}
+
+	if err := q.store.CreateScanBatch(ctx, batch, jobs); err != nil {
+		return nil, fmt.Errorf("failed to save scan batch: %v", err)
+	}
+	// the queued jobs are persisted, a restart before they run resumes them
+	q.feed(pending)
+	return batch, nil
+}
+
+// GetBatch returns a batch and the current state of its jobs
+func (q *Queue) GetBatch(ctx context.Context, id string) (*models.ScanBatch, []*models.ScanJob, error) {
+	batch, err := q.store.GetScanBatch(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	jobs, err := q.store.ListScanJobs(ctx, batch.JobIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return batch, jobs, nil
+}
+
+// Get returns the current state of a job
+func (q *Queue) Get(ctx context.Context, id string) (*models.ScanJob, error) {
+	return q.store.GetScanJob(ctx, id)
+}
+
+func (q *Queue) work(ctx context.Context) {
+	defer q.wg.Done()
+
+	for {
+		// a stop request wins over pending jobs
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+
+		// single scans go before the jobs of batches
+		select {
+		case id := <-q.pending:
+			q.run(ctx, id)
+			continue
+		default:
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.stop:
+			return
+		case id := <-q.pending:
+			q.run(ctx, id)
+		case id := <-q.background:
+			q.run(ctx, id)
+		}
+	}
+}
+
+func (q *Queue) run(ctx context.Context, id string) {
+	job, err := q.store.GetScanJob(ctx, id)
+	if err != nil {
+		log.Printf("Failed to load scan job %s: %v", id, err)
+		return
+	}
+
+	// a job interrupted by a restart starts over with fresh stages
+	if job.Status == models.StatusRunning {
+		*job = *models.NewScanJob(job.ID, job.URL)
+	}
+	job.Status = models.StatusRunning
+	q.save(job)
+
+	scanCtx, cancel := context.WithTimeout(ctx, q.JobTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	events := func(event models.ScanEvent) {
+		if event.Type != models.EventStage {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		job.Apply(event)
+		q.save(job)
+	}
+
+	result, err := q.scan(scanCtx, job.URL, events)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err != nil {
+		// leave the job running when shutting down so the next start resumes it
+		if ctx.Err() != nil {
+			return
+		}
+		job.Status = models.StatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = models.StatusDone
+		job.Result = result
+	}
+	job.UpdatedAt = time.Now()
+	q.save(job)
+}
+
+func (q *Queue) save(job *models.ScanJob) {
+	// progress must be stored even if the scan context is already cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := q.store.UpdateScanJob(ctx, job); err != nil {
+		log.Printf("Failed to update scan job %s: %v", job.ID, err)
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
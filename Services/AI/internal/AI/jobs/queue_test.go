@@ -0,0 +1,409 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+)
+
+// memoryStore is an in-memory Store copying jobs like a database would
+type memoryStore struct {
+	mu      sync.Mutex
+	jobs    map[string]models.ScanJob
+	batches map[string]models.ScanBatch
+	// batchErr fails CreateScanBatch like a rolled back transaction
+	batchErr error
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{jobs: make(map[string]models.ScanJob), batches: make(map[string]models.ScanBatch)}
+}
+
+func (s *memoryStore) CreateScanJob(ctx context.Context, job *models.ScanJob) error {
+	return s.UpdateScanJob(ctx, job)
+}
+
+func (s *memoryStore) UpdateScanJob(ctx context.Context, job *models.ScanJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *job
+	copied.Stages = append([]models.StageProgress(nil), job.Stages...)
+	s.jobs[job.ID] = copied
+	return nil
+}
+
+func (s *memoryStore) GetScanJob(ctx context.Context, id string) (*models.ScanJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return &job, nil
+}
+
+func (s *memoryStore) ListUnfinishedScanJobs(ctx context.Context) ([]*models.ScanJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var unfinished []*models.ScanJob
+	for _, job := range s.jobs {
+		if job.Status == models.StatusQueued || job.Status == models.StatusRunning {
+			job := job
+			unfinished = append(unfinished, &job)
+		}
+	}
+	return unfinished, nil
+}
+
+func (s *memoryStore) CreateScanBatch(ctx context.Context, batch *models.ScanBatch, jobs []*models.ScanJob) error {
+	s.mu.Lock()
+	if s.batchErr != nil {
+		s.mu.Unlock()
+		return s.batchErr
+	}
+	s.mu.Unlock()
+
+	for _, job := range jobs {
+		s.UpdateScanJob(ctx, job)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *batch
+	copied.JobIDs = append([]string(nil), batch.JobIDs...)
+	s.batches[batch.ID] = copied
+	return nil
+}
+
+func (s *memoryStore) GetScanBatch(ctx context.Context, id string) (*models.ScanBatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batch, ok := s.batches[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return &batch, nil
+}
+
+func (s *memoryStore) ListScanJobs(ctx context.Context, ids []string) ([]*models.ScanJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var jobs []*models.ScanJob
+	for _, id := range ids {
+		if job, ok := s.jobs[id]; ok {
+			jobs = append(jobs, &job)
+		}
+	}
+	return jobs, nil
+}
+
+func waitForStatus(t *testing.T, q *Queue, id, status string) *models.ScanJob {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := q.Get(context.Background(), id)
+		if err == nil && job.Status == status {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s never reached status %q", id, status)
+	return nil
+}
+
+func TestQueueRunsJob(t *testing.T) {
+	scan := func(ctx context.Context, url string, events models.EventFunc) (json.RawMessage, error) {
+		for _, stage := range models.ScanStages {
+			events(models.StageEvent(stage, models.StatusRunning, nil))
+			events(models.StageEvent(stage, models.StatusDone, nil))
+		}
+		return json.RawMessage(`{"trustScore": 90}`), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := NewQueue(newMemoryStore(), scan, 2, 10)
+	if err := q.Start(ctx); err != nil {
+		t.Fatalf("Start returned an error: %v", err)
+	}
+
+	job, err := q.Enqueue(ctx, "example.com")
+	if err != nil {
+		t.Fatalf("Enqueue returned an error: %v", err)
+	}
+	if job.Status != models.StatusQueued || job.Progress() != 0 {
+		t.Errorf("got a new job %+v", job)
+	}
+
+	done := waitForStatus(t, q, job.ID, models.StatusDone)
+	if string(done.Result) != `{"trustScore": 90}` {
+		t.Errorf("got result %s", done.Result)
+	}
+	if done.Progress() != 100 {
+		t.Errorf("got progress %d, wanted 100", done.Progress())
+	}
+	for _, stage := range done.Stages {
+		if stage.Status != models.StatusDone || stage.StartedAt == nil || stage.FinishedAt == nil {
+			t.Errorf("got stage %+v", stage)
+		}
+	}
+}
+
+func TestQueueRecordsFailure(t *testing.T) {
+	scan := func(ctx context.Context, url string, events models.EventFunc) (json.RawMessage, error) {
+		events(models.StageEvent(models.StageScrape, models.StatusRunning, nil))
+		events(models.StageEvent(models.StageScrape, models.StatusDone, nil))
+		return nil, errors.New("host is not up")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := NewQueue(newMemoryStore(), scan, 1, 10)
+	q.Start(ctx)
+
+	job, _ := q.Enqueue(ctx, "down.example")
+	failed := waitForStatus(t, q, job.ID, models.StatusFailed)
+	if failed.Error != "host is not up" {
+		t.Errorf("got error %q", failed.Error)
+	}
+	if failed.Progress() != 25 {
+		t.Errorf("got progress %d, wanted 25", failed.Progress())
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	block := make(chan struct{})
+	scan := func(ctx context.Context, url string, events models.EventFunc) (json.RawMessage, error) {
+		<-block
+		return json.RawMessage(`{}`), nil
+	}
+
+	// no workers are started so nothing drains the queue
+	q := NewQueue(newMemoryStore(), scan, 1, 2)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := q.Enqueue(ctx, "example.com"); err != nil {
+			t.Fatalf("Enqueue %d returned an error: %v", i, err)
+		}
+	}
+	if _, err := q.Enqueue(ctx, "example.com"); err != ErrQueueFull {
+		t.Errorf("got %v, wanted ErrQueueFull", err)
+	}
+	close(block)
+}
+
+func TestQueueBatch(t *testing.T) {
+	var mu sync.Mutex
+	scanned := []string{}
+	scan := func(ctx context.Context, url string, events models.EventFunc) (json.RawMessage, error) {
+		mu.Lock()
+		scanned = append(scanned, url)
+		mu.Unlock()
+		return json.RawMessage(`{"trustScore": 70}`), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the batch is larger than the queue, its jobs wait instead of failing
+	q := NewQueue(newMemoryStore(), scan, 2, 2)
+	if err := q.Start(ctx); err != nil {
+		t.Fatalf("Start returned an error: %v", err)
+	}
+
+	urls := []string{"a.com", "b.com", "cached.com", "c.com", "d.com", "e.com"}
+	cached := map[string]json.RawMessage{"cached.com": json.RawMessage(`{"trustScore": 10}`)}
+	batch, err := q.EnqueueBatch(ctx, urls, cached)
+	if err != nil {
+		t.Fatalf("EnqueueBatch returned an error: %v", err)
+	}
+	if len(batch.JobIDs) != len(urls) {
+		t.Fatalf("got %d jobs, wanted %d", len(batch.JobIDs), len(urls))
+	}
+
+	for _, id := range batch.JobIDs {
+		waitForStatus(t, q, id, models.StatusDone)
+	}
+
+	got, jobs, err := q.GetBatch(ctx, batch.ID)
+	if err != nil || got.ID != batch.ID || len(jobs) != len(urls) {
+		t.Fatalf("got %+v with %d jobs, %v", got, len(jobs), err)
+	}
+	if cachedJob := jobs[2]; cachedJob.URL != "cached.com" || string(cachedJob.Result) != `{"trustScore": 10}` || cachedJob.Stages[0].Status != models.StatusSkipped {
+		t.Errorf("got %+v for the cached url", cachedJob)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(scanned) != len(urls)-1 {
+		t.Errorf("scanned %v, wanted every url but the cached one", scanned)
+	}
+}
+
+func TestQueueBatchLeavesRoomForSingleScans(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	scanned := []string{}
+	scan := func(ctx context.Context, url string, events models.EventFunc) (json.RawMessage, error) {
+		if url == "a.com" {
+			<-release
+		}
+		mu.Lock()
+		scanned = append(scanned, url)
+		mu.Unlock()
+		return json.RawMessage(`{}`), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := NewQueue(newMemoryStore(), scan, 1, 1)
+	q.Start(ctx)
+
+	// the only worker is busy with the batch and more of its jobs are waiting
+	batch, err := q.EnqueueBatch(ctx, []string{"a.com", "b.com", "c.com"}, nil)
+	if err != nil {
+		t.Fatalf("EnqueueBatch returned an error: %v", err)
+	}
+	waitForStatus(t, q, batch.JobIDs[0], models.StatusRunning)
+
+	single, err := q.Enqueue(ctx, "single.com")
+	if err != nil {
+		t.Fatalf("Enqueue returned %v while a batch was waiting", err)
+	}
+
+	close(release)
+	waitForStatus(t, q, single.ID, models.StatusDone)
+	waitForStatus(t, q, batch.JobIDs[2], models.StatusDone)
+
+	// the single scan runs before the rest of the batch
+	mu.Lock()
+	defer mu.Unlock()
+	if len(scanned) != 4 || scanned[1] != "single.com" {
+		t.Errorf("scanned %v, wanted single.com right after a.com", scanned)
+	}
+}
+
+func TestQueueBatchSaveFailure(t *testing.T) {
+	store := newMemoryStore()
+	store.batchErr = errors.New("connection reset")
+	q := NewQueue(store, nil, 1, 1)
+
+	if _, err := q.EnqueueBatch(context.Background(), []string{"a.com", "b.com"}, nil); err == nil {
+		t.Fatal("expected an error when the batch cannot be saved")
+	}
+	// nothing is left for the next start to resume
+	if unfinished, _ := store.ListUnfinishedScanJobs(context.Background()); len(unfinished) != 0 {
+		t.Errorf("got %d unfinished jobs after a failed batch", len(unfinished))
+	}
+}
+
+func TestQueueResumesUnfinishedJobs(t *testing.T) {
+	store := newMemoryStore()
+
+	interrupted := models.NewScanJob("interrupted", "example.com")
+	interrupted.Status = models.StatusRunning
+	interrupted.SetStage(models.StageScrape, models.StatusRunning, nil)
+	store.CreateScanJob(context.Background(), interrupted)
+	store.CreateScanJob(context.Background(), models.NewScanJob("queued", "example.org"))
+
+	var mu sync.Mutex
+	scanned := make(map[string]bool)
+	scan := func(ctx context.Context, url string, events models.EventFunc) (json.RawMessage, error) {
+		mu.Lock()
+		scanned[url] = true
+		mu.Unlock()
+		return json.RawMessage(`{}`), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := NewQueue(store, scan, 1, 1)
+	if err := q.Start(ctx); err != nil {
+		t.Fatalf("Start returned an error: %v", err)
+	}
+
+	waitForStatus(t, q, "interrupted", models.StatusDone)
+	waitForStatus(t, q, "queued", models.StatusDone)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !scanned["example.com"] || !scanned["example.org"] {
+		t.Errorf("got scanned %v", scanned)
+	}
+}
+
+func TestQueueShutdownDrainsRunningJobs(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	scan := func(ctx context.Context, url string, events models.EventFunc) (json.RawMessage, error) {
+		close(started)
+		<-release
+		return json.RawMessage(`{}`), nil
+	}
+
+	q := NewQueue(newMemoryStore(), scan, 1, 10)
+	if err := q.Start(context.Background()); err != nil {
+		t.Fatalf("Start returned an error: %v", err)
+	}
+
+	running, _ := q.Enqueue(context.Background(), "example.com")
+	<-started
+	queued, _ := q.Enqueue(context.Background(), "example.org")
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		shutdownErr <- q.Shutdown(ctx)
+	}()
+
+	// the running scan is drained, not interrupted
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if err := <-shutdownErr; err != nil {
+		t.Fatalf("Shutdown returned an error: %v", err)
+	}
+
+	if job, _ := q.Get(context.Background(), running.ID); job.Status != models.StatusDone {
+		t.Errorf("got running job status %q, wanted done", job.Status)
+	}
+	// jobs still waiting are left for the next start
+	if job, _ := q.Get(context.Background(), queued.ID); job.Status != models.StatusQueued {
+		t.Errorf("got queued job status %q, wanted queued", job.Status)
+	}
+}
+
+func TestQueueShutdownTimeoutCancelsScans(t *testing.T) {
+	started := make(chan struct{})
+	scan := func(ctx context.Context, url string, events models.EventFunc) (json.RawMessage, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	q := NewQueue(newMemoryStore(), scan, 1, 10)
+	q.Start(context.Background())
+
+	job, _ := q.Enqueue(context.Background(), "slow.example")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got %v, wanted context.DeadlineExceeded", err)
+	}
+
+	// the interrupted job stays running so the next start resumes it
+	if got, _ := q.Get(context.Background(), job.ID); got.Status != models.StatusRunning {
+		t.Errorf("got status %q, wanted running", got.Status)
+	}
+}
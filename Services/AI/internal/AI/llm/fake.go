@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// FakeVerdict is the answer returned by a Fake created without responses
+const FakeVerdict = `{
+  "trustScore": 50,
+  "riskLevel": "medium",
+  "positivePoints": ["Deterministic response from the fake provider"],
+  "negativePoints": ["No real analysis was performed"],
+  "description": "This verdict was produced by the fake LLM provider for testing.",
+  "technicalFlags": {}
+}`
+
+// Fake is a deterministic provider for tests and local development.
+// It answers with Responses in order and keeps repeating the last one.
+type Fake struct {
+	Responses []string
+	Err       error
+
+	mu    sync.Mutex
+	calls [][]Message
+}
+
+func NewFake(responses ...string) *Fake {
+	if len(responses) == 0 {
+		responses = []string{FakeVerdict}
+	}
+	return &Fake{Responses: responses}
+}
+
+func (f *Fake) Name() string {
+	return ProviderFake
+}
+
+func (f *Fake) Complete(ctx context.Context, messages []Message) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, messages)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	i := len(f.calls) - 1
+	if i >= len(f.Responses) {
+		i = len(f.Responses) - 1
+	}
+
+	return &Response{
+		Provider: ProviderFake,
+		Model:    ProviderFake,
+		Content:  f.Responses[i],
+	}, nil
+}
+
+// Stream hands the response to onToken word by word
+func (f *Fake) Stream(ctx context.Context, messages []Message, onToken TokenFunc) (*Response, error) {
+	response, err := f.Complete(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := response.Content
+	for rest != "" {
+		end := strings.IndexByte(rest[1:], ' ') + 1
+		if end == 0 {
+			end = len(rest)
+		}
+		onToken(rest[:end])
+		rest = rest[end:]
+	}
+	return response, nil
+}
+
+// Calls returns the messages of every completion requested so far
+func (f *Fake) Calls() [][]Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]Message(nil), f.calls...)
+}
@@ -0,0 +1,149 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+)
+
+const (
+	ollamaBaseURL = "http://localhost:11434"
+	ollamaModel   = "qwen2.5:7b"
+)
+
+// Ollama talks to a local Ollama style server through POST /api/chat
+type Ollama struct {
+	BaseURL string
+	Model   string
+	Client  *http.Client
+}
+
+func NewOllama(baseURL, model string, client *http.Client) *Ollama {
+	if baseURL == "" {
+		baseURL = ollamaBaseURL
+	}
+	if model == "" {
+		model = ollamaModel
+	}
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &Ollama{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Model:   model,
+		Client:  client,
+	}
+}
+
+func (p *Ollama) Name() string {
+	return ProviderOllama
+}
+
+func (p *Ollama) Complete(ctx context.Context, messages []Message) (*Response, error) {
+	resp, err := p.post(ctx, messages, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the response body: %v", err)
+	}
+
+	var chat models.OllamaChatResponse
+	if err = json.Unmarshal(body, &chat); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal the chat response: %v", err)
+	}
+
+	return &Response{
+		Provider: ProviderOllama,
+		Model:    chat.Model,
+		Content:  chat.Message.Content,
+		Usage: Usage{
+			PromptTokens:     chat.PromptEvalCount,
+			CompletionTokens: chat.EvalCount,
+			TotalTokens:      chat.PromptEvalCount + chat.EvalCount,
+		},
+	}, nil
+}
+
+// Stream reads the newline delimited chat responses and hands every content part to onToken
+func (p *Ollama) Stream(ctx context.Context, messages []Message, onToken TokenFunc) (*Response, error) {
+	resp, err := p.post(ctx, messages, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	response := &Response{Provider: ProviderOllama, Model: p.Model}
+	var content strings.Builder
+	finished := false
+
+	decoder := json.NewDecoder(resp.Body)
+	for !finished {
+		var chat models.OllamaChatResponse
+		if err := decoder.Decode(&chat); err == io.EOF {
+			return nil, ErrStreamIncomplete
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to unmarshal the chat stream: %v", err)
+		}
+
+		if chat.Model != "" {
+			response.Model = chat.Model
+		}
+		if chat.Message.Content != "" {
+			content.WriteString(chat.Message.Content)
+			onToken(chat.Message.Content)
+		}
+		if chat.Done {
+			response.Usage = Usage{
+				PromptTokens:     chat.PromptEvalCount,
+				CompletionTokens: chat.EvalCount,
+				TotalTokens:      chat.PromptEvalCount + chat.EvalCount,
+			}
+			finished = true
+		}
+	}
+
+	response.Content = content.String()
+	return response, nil
+}
+
+func (p *Ollama) post(ctx context.Context, messages []Message, stream bool) (*http.Response, error) {
+	payload := map[string]interface{}{
+		"model":    p.Model,
+		"messages": messages,
+		"stream":   stream,
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal the payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/api/chat", bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to send the request to ollama: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,200 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+)
+
+const (
+	openRouterBaseURL = "https://openrouter.ai/api/v1"
+	openRouterModel   = "qwen/qwq-32b"
+)
+
+// OpenAICompatible talks to any endpoint implementing POST /chat/completions
+type OpenAICompatible struct {
+	name    string
+	BaseURL string
+	APIKey  string
+	Model   string
+	Client  *http.Client
+}
+
+func NewOpenAICompatible(name, baseURL, apiKey, model string, client *http.Client) *OpenAICompatible {
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &OpenAICompatible{
+		name:    name,
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  apiKey,
+		Model:   model,
+		Client:  client,
+	}
+}
+
+// NewOpenRouter returns an OpenAI compatible provider pointed at openrouter.ai
+func NewOpenRouter(apiKey, model string, client *http.Client) *OpenAICompatible {
+	if model == "" {
+		model = openRouterModel
+	}
+	return NewOpenAICompatible(ProviderOpenRouter, openRouterBaseURL, apiKey, model, client)
+}
+
+func (p *OpenAICompatible) Name() string {
+	return p.name
+}
+
+func (p *OpenAICompatible) Complete(ctx context.Context, messages []Message) (*Response, error) {
+	resp, err := p.post(ctx, messages, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the response body: %v", err)
+	}
+
+	var completion models.CompletionResponse
+	if err = json.Unmarshal(body, &completion); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal the completion: %v", err)
+	}
+
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("%s returned no choices", p.name)
+	}
+
+	model := completion.Model
+	if model == "" {
+		model = p.Model
+	}
+
+	return &Response{
+		Provider:  p.name,
+		Model:     model,
+		Content:   completion.Choices[0].Message.Content,
+		Reasoning: completion.Choices[0].Message.Reasoning,
+		Usage: Usage{
+			PromptTokens:     completion.Usage.PromptTokens,
+			CompletionTokens: completion.Usage.CompletionTokens,
+			TotalTokens:      completion.Usage.TotalTokens,
+		},
+	}, nil
+}
+
+// Stream requests a server-sent events completion and hands every content delta to onToken
+func (p *OpenAICompatible) Stream(ctx context.Context, messages []Message, onToken TokenFunc) (*Response, error) {
+	resp, err := p.post(ctx, messages, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	response := &Response{Provider: p.name, Model: p.Model}
+	var content, reasoning strings.Builder
+	finished := false
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// blank lines separate events and lines starting with ':' are keep-alive comments
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			finished = true
+			break
+		}
+
+		var chunk models.CompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal the completion chunk: %v", err)
+		}
+
+		if chunk.Model != "" {
+			response.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			response.Usage = Usage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:      chunk.Usage.TotalTokens,
+			}
+		}
+		for _, choice := range chunk.Choices {
+			reasoning.WriteString(choice.Delta.Reasoning)
+			if choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				onToken(choice.Delta.Content)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read the completion stream: %v", err)
+	}
+	// a dropped connection leaves a truncated answer that must not pass as complete
+	if !finished {
+		return nil, ErrStreamIncomplete
+	}
+
+	response.Content = content.String()
+	response.Reasoning = reasoning.String()
+	return response, nil
+}
+
+// post sends the chat completion request and turns error statuses into errors
+func (p *OpenAICompatible) post(ctx context.Context, messages []Message, stream bool) (*http.Response, error) {
+	payload := map[string]interface{}{
+		"model":    p.Model,
+		"messages": messages,
+	}
+	if stream {
+		payload["stream"] = true
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal the payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/chat/completions", bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, err
+	}
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to send the request to %s: %v", p.name, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+
+		var apiErr models.APIErrorResponse
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("%s returned status %d: %s", p.name, resp.StatusCode, apiErr.Error.Message)
+		}
+		return nil, fmt.Errorf("%s returned status %d", p.name, resp.StatusCode)
+	}
+
+	return resp, nil
+}
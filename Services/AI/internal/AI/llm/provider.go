@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Message is a single chat message sent to the model
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Usage holds the token accounting reported by the provider
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Response is the provider independent result of a completion
+type Response struct {
+	Provider  string `json:"provider"`
+	Model     string `json:"model"`
+	Content   string `json:"content"`
+	Reasoning string `json:"reasoning,omitempty"`
+	Usage     Usage  `json:"usage"`
+}
+
+// Provider is implemented by every backend able to answer a chat completion
+type Provider interface {
+	Name() string
+	Complete(ctx context.Context, messages []Message) (*Response, error)
+}
+
+const (
+	ProviderOpenRouter = "openrouter"
+	ProviderOpenAI     = "openai"
+	ProviderOllama     = "ollama"
+	ProviderFake       = "fake"
+)
+
+// Config selects and configures the provider used by the AI handler
+type Config struct {
+	Provider string        `yaml:"provider"`
+	BaseURL  string        `yaml:"base_url"`
+	APIKey   string        `yaml:"api_key"`
+	Model    string        `yaml:"model"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// NewProvider builds the provider named in the config, defaulting to OpenRouter
+func NewProvider(cfg Config) (Provider, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	switch strings.ToLower(strings.TrimSpace(cfg.Provider)) {
+	case "", ProviderOpenRouter:
+		return NewOpenRouter(cfg.APIKey, cfg.Model, client), nil
+	case ProviderOpenAI:
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("provider %q requires a base url", cfg.Provider)
+		}
+		return NewOpenAICompatible(ProviderOpenAI, cfg.BaseURL, cfg.APIKey, cfg.Model, client), nil
+	case ProviderOllama:
+		return NewOllama(cfg.BaseURL, cfg.Model, client), nil
+	case ProviderFake:
+		return NewFake(), nil
+	default:
+		return nil, fmt.Errorf("unknown llm provider %q", cfg.Provider)
+	}
+}
@@ -0,0 +1,258 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAICompatibleComplete(t *testing.T) {
+	var gotAuth, gotPath string
+	var gotBody map[string]interface{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"id": "gen-1",
+			"model": "stub-model",
+			"choices": [{"message": {"role": "assistant", "content": "{\"trustScore\": 80}"}}],
+			"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+		}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAICompatible(ProviderOpenAI, srv.URL+"/v1/", "secret", "stub-model", srv.Client())
+	got, err := p.Complete(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("Complete returned an error: %v", err)
+	}
+
+	if gotPath != "/v1/chat/completions" {
+		t.Errorf("got path %q, wanted /v1/chat/completions", gotPath)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Errorf("got Authorization %q, wanted %q", gotAuth, "Bearer secret")
+	}
+	if gotBody["model"] != "stub-model" {
+		t.Errorf("got model %v, wanted stub-model", gotBody["model"])
+	}
+	if got.Content != `{"trustScore": 80}` {
+		t.Errorf("got content %q", got.Content)
+	}
+	if got.Usage.TotalTokens != 15 {
+		t.Errorf("got total tokens %d, wanted 15", got.Usage.TotalTokens)
+	}
+}
+
+func TestOpenAICompatibleErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": {"message": "invalid api key"}}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAICompatible(ProviderOpenAI, srv.URL, "", "m", srv.Client())
+	_, err := p.Complete(context.Background(), nil)
+	if err == nil {
+		t.Fatal("expected an error for a 401 response")
+	}
+	if want := "openai returned status 401: invalid api key"; err.Error() != want {
+		t.Errorf("got %q, wanted %q", err.Error(), want)
+	}
+}
+
+func TestOllamaComplete(t *testing.T) {
+	var gotBody map[string]interface{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("got path %q, wanted /api/chat", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"model": "llama3", "message": {"role": "assistant", "content": "ok"}, "done": true, "prompt_eval_count": 3, "eval_count": 2}`))
+	}))
+	defer srv.Close()
+
+	p := NewOllama(srv.URL, "llama3", srv.Client())
+	got, err := p.Complete(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("Complete returned an error: %v", err)
+	}
+
+	if gotBody["stream"] != false {
+		t.Errorf("expected a non streaming request, got stream=%v", gotBody["stream"])
+	}
+	if got.Content != "ok" || got.Usage.TotalTokens != 5 {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestFakeRepeatsLastResponse(t *testing.T) {
+	f := NewFake("first", "second")
+	ctx := context.Background()
+
+	for _, want := range []string{"first", "second", "second"} {
+		got, err := f.Complete(ctx, []Message{{Role: "user", Content: want}})
+		if err != nil {
+			t.Fatalf("Complete returned an error: %v", err)
+		}
+		if got.Content != want {
+			t.Errorf("got %q, wanted %q", got.Content, want)
+		}
+	}
+
+	if len(f.Calls()) != 3 {
+		t.Errorf("got %d calls, wanted 3", len(f.Calls()))
+	}
+
+	f.Err = errors.New("boom")
+	if _, err := f.Complete(ctx, nil); err == nil {
+		t.Error("expected the configured error")
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		cfg     Config
+		want    string
+		wantErr bool
+	}{
+		{cfg: Config{}, want: ProviderOpenRouter},
+		{cfg: Config{Provider: "OpenRouter"}, want: ProviderOpenRouter},
+		{cfg: Config{Provider: "openai", BaseURL: "http://localhost:8000/v1"}, want: ProviderOpenAI},
+		{cfg: Config{Provider: "openai"}, wantErr: true},
+		{cfg: Config{Provider: "ollama"}, want: ProviderOllama},
+		{cfg: Config{Provider: "fake"}, want: ProviderFake},
+		{cfg: Config{Provider: "gpt-9000"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := NewProvider(tt.cfg)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("NewProvider(%+v) expected an error", tt.cfg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewProvider(%+v) returned an error: %v", tt.cfg, err)
+			continue
+		}
+		if got.Name() != tt.want {
+			t.Errorf("NewProvider(%+v) got %q, wanted %q", tt.cfg, got.Name(), tt.want)
+		}
+	}
+}
+
+func TestOpenAICompatibleStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] != true {
+			t.Errorf("expected a streaming request, got stream=%v", body["stream"])
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(": OPENROUTER PROCESSING\n\n"))
+		w.Write([]byte(`data: {"model": "stub-model", "choices": [{"delta": {"role": "assistant", "content": "{\"trust"}}]}` + "\n\n"))
+		w.Write([]byte(`data: {"choices": [{"delta": {"content": "Score\": 1}"}}], "usage": {"total_tokens": 9}}` + "\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	var tokens []string
+	p := NewOpenAICompatible(ProviderOpenAI, srv.URL, "", "m", srv.Client())
+	got, err := CompleteStreaming(context.Background(), p, nil, func(token string) {
+		tokens = append(tokens, token)
+	})
+	if err != nil {
+		t.Fatalf("CompleteStreaming returned an error: %v", err)
+	}
+
+	if len(tokens) != 2 || tokens[0] != `{"trust` {
+		t.Errorf("got tokens %q", tokens)
+	}
+	if got.Content != `{"trustScore": 1}` || got.Model != "stub-model" || got.Usage.TotalTokens != 9 {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"model": "llama3", "message": {"content": "he"}, "done": false}` + "\n"))
+		w.Write([]byte(`{"model": "llama3", "message": {"content": "llo"}, "done": false}` + "\n"))
+		w.Write([]byte(`{"model": "llama3", "message": {"content": ""}, "done": true, "prompt_eval_count": 4, "eval_count": 2}` + "\n"))
+	}))
+	defer srv.Close()
+
+	var tokens []string
+	p := NewOllama(srv.URL, "llama3", srv.Client())
+	got, err := p.Stream(context.Background(), nil, func(token string) {
+		tokens = append(tokens, token)
+	})
+	if err != nil {
+		t.Fatalf("Stream returned an error: %v", err)
+	}
+
+	if len(tokens) != 2 || got.Content != "hello" || got.Usage.TotalTokens != 6 {
+		t.Errorf("got tokens %q and response %+v", tokens, got)
+	}
+}
+
+func TestStreamEndsBeforeCompletion(t *testing.T) {
+	openai := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(`data: {"choices": [{"delta": {"content": "{\"trust"}}]}` + "\n\n"))
+	}))
+	defer openai.Close()
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"model": "llama3", "message": {"content": "he"}, "done": false}` + "\n"))
+	}))
+	defer ollama.Close()
+
+	providers := []StreamingProvider{
+		NewOpenAICompatible(ProviderOpenAI, openai.URL, "", "m", openai.Client()),
+		NewOllama(ollama.URL, "llama3", ollama.Client()),
+	}
+	for _, p := range providers {
+		got, err := p.Stream(context.Background(), nil, func(string) {})
+		if !errors.Is(err, ErrStreamIncomplete) {
+			t.Errorf("%s: got %+v and error %v for a truncated stream", p.Name(), got, err)
+		}
+	}
+}
+
+// completeOnly hides the streaming support of the wrapped provider
+type completeOnly struct {
+	Provider
+}
+
+func TestCompleteStreamingFallback(t *testing.T) {
+	var tokens []string
+	got, err := CompleteStreaming(context.Background(), completeOnly{NewFake("one two three")}, nil, func(token string) {
+		tokens = append(tokens, token)
+	})
+	if err != nil {
+		t.Fatalf("CompleteStreaming returned an error: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0] != "one two three" || got.Content != "one two three" {
+		t.Errorf("got tokens %q", tokens)
+	}
+
+	tokens = nil
+	if _, err := CompleteStreaming(context.Background(), NewFake("one two three"), nil, func(token string) {
+		tokens = append(tokens, token)
+	}); err != nil {
+		t.Fatalf("CompleteStreaming returned an error: %v", err)
+	}
+	if len(tokens) != 3 || tokens[1] != " two" {
+		t.Errorf("got tokens %q from the fake stream", tokens)
+	}
+}
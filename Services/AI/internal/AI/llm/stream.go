@@ -0,0 +1,37 @@
+package llm
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrStreamIncomplete is returned when a stream ends before the provider marked it finished
+var ErrStreamIncomplete = errors.New("stream ended before completion")
+
+// TokenFunc receives the text of the completion as it is generated
+type TokenFunc func(token string)
+
+// StreamingProvider is implemented by providers able to stream tokens as they are generated
+type StreamingProvider interface {
+	Provider
+	Stream(ctx context.Context, messages []Message, onToken TokenFunc) (*Response, error)
+}
+
+// CompleteStreaming streams the completion when the provider supports it. Otherwise the
+// whole content is handed to onToken at once when the completion finishes.
+func CompleteStreaming(ctx context.Context, provider Provider, messages []Message, onToken TokenFunc) (*Response, error) {
+	if onToken == nil {
+		return provider.Complete(ctx, messages)
+	}
+
+	if streaming, ok := provider.(StreamingProvider); ok {
+		return streaming.Stream(ctx, messages, onToken)
+	}
+
+	response, err := provider.Complete(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	onToken(response.Content)
+	return response, nil
+}
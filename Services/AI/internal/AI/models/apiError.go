@@ -0,0 +1,10 @@
+package models
+
+// APIErrorResponse is the error body returned by OpenAI style endpoints
+type APIErrorResponse struct {
+	Error struct {
+		Message string      `json:"message"`
+		Type    string      `json:"type"`
+		Code    interface{} `json:"code"`
+	} `json:"error"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// CachePolicy decides how long a stored verdict is reused, riskier sites are re-evaluated sooner
+type CachePolicy struct {
+	HighRisk   time.Duration `json:"high_risk" yaml:"high_risk"`
+	MediumRisk time.Duration `json:"medium_risk" yaml:"medium_risk"`
+	LowRisk    time.Duration `json:"low_risk" yaml:"low_risk"`
+	// Default applies to verdicts without a known risk level
+	Default time.Duration `json:"default" yaml:"default"`
+}
+
+// DefaultCachePolicy re-evaluates high risk sites daily, medium risk weekly and low risk monthly
+func DefaultCachePolicy() CachePolicy {
+	return CachePolicy{
+		HighRisk:   24 * time.Hour,
+		MediumRisk: 7 * 24 * time.Hour,
+		LowRisk:    30 * 24 * time.Hour,
+		Default:    7 * 24 * time.Hour,
+	}
+}
+
+// MaxAge is how long a verdict with the given risk level stays fresh
+func (p CachePolicy) MaxAge(riskLevel string) time.Duration {
+	switch strings.ToLower(strings.TrimSpace(riskLevel)) {
+	case RiskHigh:
+		return p.HighRisk
+	case RiskMedium:
+		return p.MediumRisk
+	case RiskLow:
+		return p.LowRisk
+	}
+	return p.Default
+}
+
+// CacheInfo tells clients whether a scan result was reused and how old it is
+type CacheInfo struct {
+	Hit        bool      `json:"hit"`
+	ScannedAt  time.Time `json:"scanned_at"`
+	AgeSeconds int64     `json:"age_seconds"`
+	MaxAge     int64     `json:"max_age_seconds"`
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ScanBatch is a list of urls submitted at once, every url is scanned by its own job
+type ScanBatch struct {
+	ID string `json:"id"`
+	// JobIDs are the jobs of the urls, in the order they were submitted
+	JobIDs    []string  `json:"job_ids"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BatchItem is the outcome of one url of a batch
+type BatchItem struct {
+	URL    string `json:"url"`
+	JobID  string `json:"job_id"`
+	Status string `json:"status"`
+	// TrustScore and RiskLevel are only set once the job is done
+	TrustScore *int   `json:"trust_score,omitempty"`
+	RiskLevel  string `json:"risk_level,omitempty"`
+	Source     string `json:"source,omitempty"`
+	// Cached is set when the verdict was reused from an earlier scan
+	Cached bool   `json:"cached"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchReport aggregates the jobs of a batch
+type BatchReport struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Total  int    `json:"total"`
+	// Queued counts the jobs still waiting or running
+	Queued int `json:"queued"`
+	Done   int `json:"done"`
+	Failed int `json:"failed"`
+	Cached int `json:"cached"`
+	// RiskLevels counts the finished urls by risk level
+	RiskLevels map[string]int `json:"risk_levels"`
+	Progress   int            `json:"progress"`
+	Items      []BatchItem    `json:"items"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// NewBatchReport combines the jobs of batch, jobs missing from the store are reported failed
+func NewBatchReport(batch *ScanBatch, jobs []*ScanJob) *BatchReport {
+	byID := make(map[string]*ScanJob, len(jobs))
+	for _, job := range jobs {
+		byID[job.ID] = job
+	}
+
+	report := &BatchReport{
+		ID:         batch.ID,
+		Total:      len(batch.JobIDs),
+		RiskLevels: make(map[string]int),
+		Items:      []BatchItem{},
+		CreatedAt:  batch.CreatedAt,
+	}
+
+	progress := 0
+	for _, id := range batch.JobIDs {
+		job, ok := byID[id]
+		if !ok {
+			report.Failed++
+			progress += 100
+			report.Items = append(report.Items, BatchItem{JobID: id, Status: StatusFailed, Error: "scan job not found"})
+			continue
+		}
+
+		item := newBatchItem(job)
+		switch item.Status {
+		case StatusDone:
+			report.Done++
+			if item.Cached {
+				report.Cached++
+			}
+			if item.RiskLevel != "" {
+				report.RiskLevels[item.RiskLevel]++
+			}
+			progress += 100
+		case StatusFailed:
+			report.Failed++
+			progress += 100
+		default:
+			report.Queued++
+			progress += job.Progress()
+		}
+		report.Items = append(report.Items, item)
+	}
+
+	switch {
+	case report.Queued > 0:
+		report.Status = StatusRunning
+	case report.Total > 0 && report.Failed == report.Total:
+		report.Status = StatusFailed
+	default:
+		report.Status = StatusDone
+	}
+	if report.Total > 0 {
+		report.Progress = progress / report.Total
+	} else {
+		report.Progress = 100
+	}
+	return report
+}
+
+// newBatchItem reads the verdict fields of the result of job
+func newBatchItem(job *ScanJob) BatchItem {
+	item := BatchItem{URL: job.URL, JobID: job.ID, Status: job.Status, Error: job.Error}
+	if job.Status != StatusDone || len(job.Result) == 0 {
+		return item
+	}
+
+	var result ScanResult
+	if err := json.Unmarshal(job.Result, &result); err != nil {
+		item.Error = "unreadable scan result"
+		return item
+	}
+	score := result.TrustScore
+	item.TrustScore = &score
+	item.RiskLevel = result.RiskLevel
+	item.Source = result.Source
+	item.Cached = result.Cache != nil && result.Cache.Hit
+	return item
+}
+
+// batchColumns is the header of the CSV export of a batch
+var batchColumns = []string{"url", "job_id", "status", "trust_score", "risk_level", "source", "cached", "error"}
+
+// WriteCSV writes one row per url of the batch
+func (r *BatchReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(batchColumns); err != nil {
+		return err
+	}
+	for _, item := range r.Items {
+		score := ""
+		if item.TrustScore != nil {
+			score = strconv.Itoa(*item.TrustScore)
+		}
+		row := []string{item.URL, item.JobID, item.Status, score, item.RiskLevel, item.Source, strconv.FormatBool(item.Cached), item.Error}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewBatchReport(t *testing.T) {
+	done := NewScanJob("done", "shop.ir")
+	done.Status = StatusDone
+	done.Result = json.RawMessage(`{"trustScore": 20, "riskLevel": "high", "source": "ai"}`)
+
+	cached := NewScanJob("cached", "bank.ir")
+	cached.Status = StatusDone
+	cached.Result = json.RawMessage(`{"trustScore": 90, "riskLevel": "low", "source": "ai", "cache": {"hit": true}}`)
+
+	failed := NewScanJob("failed", "down.ir")
+	failed.Status = StatusFailed
+	failed.Error = "host is not up"
+
+	running := NewScanJob("running", "slow.ir")
+	running.Status = StatusRunning
+	running.SetStage(StageScrape, StatusDone, nil)
+
+	batch := &ScanBatch{ID: "b", JobIDs: []string{"done", "cached", "failed", "running", "lost"}, CreatedAt: time.Now()}
+	// the store returns the jobs in any order
+	report := NewBatchReport(batch, []*ScanJob{running, failed, cached, done})
+
+	if report.Status != StatusRunning || report.Total != 5 || report.Done != 2 || report.Cached != 1 || report.Failed != 2 || report.Queued != 1 {
+		t.Errorf("got %+v", report)
+	}
+	if report.RiskLevels["high"] != 1 || report.RiskLevels["low"] != 1 {
+		t.Errorf("got risk levels %v", report.RiskLevels)
+	}
+	// four finished jobs and one with a quarter of its stages done
+	if report.Progress != (4*100+25)/5 {
+		t.Errorf("got progress %d", report.Progress)
+	}
+	if first := report.Items[0]; first.URL != "shop.ir" || first.TrustScore == nil || *first.TrustScore != 20 || first.Cached {
+		t.Errorf("got first item %+v", first)
+	}
+	if lost := report.Items[4]; lost.JobID != "lost" || lost.Status != StatusFailed {
+		t.Errorf("got %+v for a missing job", lost)
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteCSV(&out); err != nil {
+		t.Fatalf("WriteCSV returned an error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 6 || lines[0] != "url,job_id,status,trust_score,risk_level,source,cached,error" {
+		t.Fatalf("got CSV %q", out.String())
+	}
+	if lines[2] != "bank.ir,cached,done,90,low,ai,true," || lines[3] != "down.ir,failed,failed,,,,false,host is not up" {
+		t.Errorf("got rows %q and %q", lines[2], lines[3])
+	}
+
+	finished := NewBatchReport(&ScanBatch{ID: "f", JobIDs: []string{"done"}}, []*ScanJob{done})
+	if finished.Status != StatusDone || finished.Progress != 100 {
+		t.Errorf("got %+v for a finished batch", finished)
+	}
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+)
+
+// DefaultSharpDropThreshold is the trust score loss between two scans that flags a site for re-review
+const DefaultSharpDropThreshold = 20
+
+// ScanDiff is what changed between two consecutive scans of a url
+type ScanDiff struct {
+	URL           string    `json:"url"`
+	FromID        int64     `json:"from_id"`
+	ToID          int64     `json:"to_id"`
+	FromScannedAt time.Time `json:"from_scanned_at"`
+	ToScannedAt   time.Time `json:"to_scanned_at"`
+
+	FromScore  int    `json:"from_score"`
+	ToScore    int    `json:"to_score"`
+	ScoreDelta int    `json:"score_delta"`
+	FromRisk   string `json:"from_risk_level"`
+	ToRisk     string `json:"to_risk_level"`
+	FromSource string `json:"from_source"`
+	ToSource   string `json:"to_source"`
+	// SourceChanged is set when one verdict came from the model and the other from the rule
+	// engine, their scores are not comparable and no sharp drop is reported
+	SourceChanged bool `json:"source_changed"`
+
+	NewKeywords           []string `json:"new_keywords,omitempty"`
+	RemovedKeywords       []string `json:"removed_keywords,omitempty"`
+	NewHiddenElements     []string `json:"new_hidden_elements,omitempty"`
+	RemovedHiddenElements []string `json:"removed_hidden_elements,omitempty"`
+
+	LostEnamad        bool   `json:"lost_enamad"`
+	GainedEnamad      bool   `json:"gained_enamad"`
+	RegistrarChanged  bool   `json:"registrar_changed"`
+	PreviousRegistrar string `json:"previous_registrar,omitempty"`
+	Registrar         string `json:"registrar,omitempty"`
+
+	// SharpDrop is set when the trust score fell by at least the threshold
+	SharpDrop bool `json:"sharp_drop"`
+}
+
+// Changed reports whether anything besides the scan time differs
+func (d ScanDiff) Changed() bool {
+	return d.ScoreDelta != 0 || d.FromRisk != d.ToRisk ||
+		len(d.NewKeywords) > 0 || len(d.RemovedKeywords) > 0 ||
+		len(d.NewHiddenElements) > 0 || len(d.RemovedHiddenElements) > 0 ||
+		d.LostEnamad || d.GainedEnamad || d.RegistrarChanged
+}
+
+// DiffScans compares an older scan with the next one, threshold is the score
+// loss that counts as a sharp drop
+func DiffScans(older, newer ScanHistoryEntry, threshold int) ScanDiff {
+	diff := ScanDiff{
+		URL:           newer.URL,
+		FromID:        older.ID,
+		ToID:          newer.ID,
+		FromScannedAt: older.ScannedAt,
+		ToScannedAt:   newer.ScannedAt,
+		FromScore:     older.TrustScore,
+		ToScore:       newer.TrustScore,
+		ScoreDelta:    newer.TrustScore - older.TrustScore,
+		FromRisk:      older.RiskLevel,
+		ToRisk:        newer.RiskLevel,
+		FromSource:    older.Source,
+		ToSource:      newer.Source,
+	}
+	diff.SourceChanged = older.Source != newer.Source
+	diff.SharpDrop = threshold > 0 && -diff.ScoreDelta >= threshold && !diff.SourceChanged
+
+	// scans stored without inputs only have a verdict to compare
+	if older.Inputs == nil || newer.Inputs == nil {
+		return diff
+	}
+
+	diff.NewKeywords, diff.RemovedKeywords = compareLists(older.Inputs.RiskInput.Keywords, newer.Inputs.RiskInput.Keywords)
+	diff.NewHiddenElements, diff.RemovedHiddenElements = compareLists(
+		older.Inputs.hiddenElements(),
+		newer.Inputs.hiddenElements(),
+	)
+
+	// a failed lookup of either scan says nothing about the certificate
+	before, after := older.Inputs.enamadStatus(), newer.Inputs.enamadStatus()
+	diff.LostEnamad = before == scraperModels.RegistryRegistered && absentStatus(after)
+	diff.GainedEnamad = absentStatus(before) && after == scraperModels.RegistryRegistered
+
+	diff.PreviousRegistrar = older.Inputs.registrar()
+	diff.Registrar = newer.Inputs.registrar()
+	diff.RegistrarChanged = diff.PreviousRegistrar != "" && diff.Registrar != "" && diff.PreviousRegistrar != diff.Registrar
+
+	return diff
+}
+
+// DiffHistory diffs every pair of consecutive scans, entries are newest first like GetScanHistory returns them
+func DiffHistory(entries []ScanHistoryEntry, threshold int) []ScanDiff {
+	diffs := []ScanDiff{}
+	for i := 0; i+1 < len(entries); i++ {
+		diffs = append(diffs, DiffScans(entries[i+1], entries[i], threshold))
+	}
+	return diffs
+}
+
+// enamadStatus is the registry status of the Enamad certificate, empty when it is unknown.
+// Scans stored before the compliance report only kept the certificate.
+func (in *ScanInputs) enamadStatus() string {
+	if in.Compliance != nil {
+		if result := in.Compliance.Result(scraperModels.RegistryEnamad); result != nil {
+			return result.Status
+		}
+		return ""
+	}
+	if in.Enamad == nil {
+		return ""
+	}
+	return scraperModels.EnamadResult(in.Enamad, nil).Status
+}
+
+// absentStatus reports whether the registry confirmed the site has no valid certificate
+func absentStatus(status string) bool {
+	return status == scraperModels.RegistryNotRegistered || status == scraperModels.RegistryExpired
+}
+
+func (in *ScanInputs) registrar() string {
+	if in.Whois.Registrar == nil {
+		return ""
+	}
+	return in.Whois.Registrar.Name
+}
+
+func (in *ScanInputs) hiddenElements() []string {
+	if in.Scraper == nil {
+		return nil
+	}
+	return in.Scraper.Site.HiddenElements
+}
+
+// compareLists returns the values only in after and the values only in before, sorted
+func compareLists(before, after []string) (added, removed []string) {
+	inBefore := make(map[string]bool)
+	for _, v := range before {
+		inBefore[v] = true
+	}
+	inAfter := make(map[string]bool)
+	for _, v := range after {
+		inAfter[v] = true
+		if !inBefore[v] && !contains(added, v) {
+			added = append(added, v)
+		}
+	}
+	for _, v := range before {
+		if !inAfter[v] && !contains(removed, v) {
+			removed = append(removed, v)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ScoreDrop is a url whose latest scan lost trust compared to the scan before it
+type ScoreDrop struct {
+	URL           string    `json:"url"`
+	ScannedAt     time.Time `json:"scanned_at"`
+	PreviousScore int       `json:"previous_score"`
+	TrustScore    int       `json:"trust_score"`
+	ScoreDelta    int       `json:"score_delta"`
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	whoisparser "github.com/likexian/whois-parser"
+)
+
+// historyEntry is an AI verdict whose Enamad lookup answered enamad, a registry status
+func historyEntry(id int64, score int, keywords []string, hidden []string, enamad string, registrar string) ScanHistoryEntry {
+	return ScanHistoryEntry{
+		ID:         id,
+		URL:        "example.ir",
+		Source:     VerdictSourceAI,
+		TrustScore: score,
+		Inputs: &ScanInputs{
+			Scraper: &scraperModels.ScrapeReport{Site: scraperModels.SiteSummary{HiddenElements: hidden}},
+			Whois:   whoisparser.WhoisInfo{Registrar: &whoisparser.Contact{Name: registrar}},
+			Compliance: scraperModels.NewComplianceReport([]scraperModels.RegistryResult{
+				{Registry: scraperModels.RegistryEnamad, Status: enamad},
+			}),
+			RiskInput: scraperModels.RiskInput{
+				Keywords:  keywords,
+				HasEnamad: enamad == scraperModels.RegistryRegistered,
+			},
+		},
+	}
+}
+
+const (
+	registered    = scraperModels.RegistryRegistered
+	notRegistered = scraperModels.RegistryNotRegistered
+)
+
+func TestDiffScans(t *testing.T) {
+	older := historyEntry(1, 80, []string{"sale"}, []string{"div.a"}, registered, "IRNIC")
+	newer := historyEntry(2, 45, []string{"sale", "urgent", "urgent"}, []string{"div.a", "iframe.b"}, notRegistered, "Other Registrar")
+
+	diff := DiffScans(older, newer, DefaultSharpDropThreshold)
+
+	if diff.ScoreDelta != -35 || !diff.SharpDrop {
+		t.Errorf("got delta %d sharp drop %v", diff.ScoreDelta, diff.SharpDrop)
+	}
+	if !reflect.DeepEqual(diff.NewKeywords, []string{"urgent"}) || len(diff.RemovedKeywords) != 0 {
+		t.Errorf("got new keywords %v removed %v", diff.NewKeywords, diff.RemovedKeywords)
+	}
+	if !reflect.DeepEqual(diff.NewHiddenElements, []string{"iframe.b"}) {
+		t.Errorf("got new hidden elements %v", diff.NewHiddenElements)
+	}
+	if !diff.LostEnamad || diff.GainedEnamad {
+		t.Errorf("expected lost enamad, got %+v", diff)
+	}
+	if !diff.RegistrarChanged || diff.PreviousRegistrar != "IRNIC" {
+		t.Errorf("expected a registrar change, got %+v", diff)
+	}
+	if !diff.Changed() {
+		t.Error("expected Changed to be true")
+	}
+}
+
+func TestDiffScansWithoutChanges(t *testing.T) {
+	older := historyEntry(1, 70, []string{"sale"}, nil, registered, "IRNIC")
+	newer := historyEntry(2, 65, []string{"sale"}, nil, registered, "IRNIC")
+
+	diff := DiffScans(older, newer, DefaultSharpDropThreshold)
+	if diff.SharpDrop || diff.LostEnamad || diff.RegistrarChanged || len(diff.NewKeywords) != 0 {
+		t.Errorf("got %+v", diff)
+	}
+
+	older.Inputs, newer.Inputs = nil, nil
+	if diff := DiffScans(older, newer, 0); diff.ScoreDelta != -5 || diff.SharpDrop {
+		t.Errorf("got %+v for scans without inputs", diff)
+	}
+}
+
+func TestDiffScansUnknownEnamadAndSource(t *testing.T) {
+	older := historyEntry(1, 80, nil, nil, registered, "IRNIC")
+	newer := historyEntry(2, 40, nil, nil, scraperModels.RegistryUnavailable, "IRNIC")
+
+	// enamad.ir timing out is no lost certificate
+	if diff := DiffScans(older, newer, DefaultSharpDropThreshold); diff.LostEnamad || !diff.SharpDrop {
+		t.Errorf("got %+v for an unavailable lookup", diff)
+	}
+
+	// scans stored before the compliance report keep the certificate only
+	older.Inputs.Compliance, newer.Inputs.Compliance = nil, nil
+	older.Inputs.Enamad = &scraperModels.Enamad_Data{ID: 1, Domain: "example.ir"}
+	if diff := DiffScans(older, newer, DefaultSharpDropThreshold); diff.LostEnamad {
+		t.Errorf("got %+v for a scan without an enamad answer", diff)
+	}
+	newer.Inputs.Enamad = &scraperModels.Enamad_Data{}
+	if diff := DiffScans(older, newer, DefaultSharpDropThreshold); !diff.LostEnamad {
+		t.Errorf("got %+v, wanted a lost certificate", diff)
+	}
+
+	// a rule engine fallback is not compared with a model verdict
+	newer.Source = VerdictSourceRules
+	if diff := DiffScans(older, newer, DefaultSharpDropThreshold); diff.SharpDrop || !diff.SourceChanged {
+		t.Errorf("got %+v for verdicts of different sources", diff)
+	}
+}
+
+func TestDiffHistoryOrder(t *testing.T) {
+	// newest first, as stored history is returned
+	entries := []ScanHistoryEntry{
+		historyEntry(3, 30, nil, nil, notRegistered, ""),
+		historyEntry(2, 60, nil, nil, notRegistered, ""),
+		historyEntry(1, 50, nil, nil, notRegistered, ""),
+	}
+
+	diffs := DiffHistory(entries, DefaultSharpDropThreshold)
+	if len(diffs) != 2 {
+		t.Fatalf("got %d diffs, wanted 2", len(diffs))
+	}
+	if diffs[0].FromID != 2 || diffs[0].ToID != 3 || !diffs[0].SharpDrop {
+		t.Errorf("got latest diff %+v", diffs[0])
+	}
+	if diffs[1].ScoreDelta != 10 || diffs[1].SharpDrop {
+		t.Errorf("got older diff %+v", diffs[1])
+	}
+	if len(DiffHistory(entries[:1], DefaultSharpDropThreshold)) != 0 {
+		t.Error("expected no diff for a single scan")
+	}
+}
@@ -0,0 +1,56 @@
+package models
+
+// Event types emitted by the scan pipeline
+const (
+	EventHost       = "host"
+	EventCached     = "cached"
+	EventStage      = "stage"
+	EventPage       = "page"
+	EventTLS        = "tls"
+	EventRedirects  = "redirects"
+	EventDNS        = "dns"
+	EventWhois      = "whois"
+	EventEnamad     = "enamad"
+	EventSeals      = "trust_seals"
+	EventCompliance = "compliance"
+	EventToken      = "token"
+	EventResult     = "result"
+	EventError      = "error"
+)
+
+// ScanEvent is one step of the scan pipeline, streamed to clients and recorded by jobs
+type ScanEvent struct {
+	Type   string      `json:"type"`
+	Stage  string      `json:"stage,omitempty"`
+	Status string      `json:"status,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+// EventFunc receives the events of a running scan. It may be called from several goroutines.
+type EventFunc func(event ScanEvent)
+
+// StageEvent reports a status change of a pipeline stage
+func StageEvent(stage, status string, err error) ScanEvent {
+	event := ScanEvent{Type: EventStage, Stage: stage, Status: status}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	return event
+}
+
+// HostStatus is the data of an EventHost event
+type HostStatus struct {
+	URL string `json:"url"`
+	Up  bool   `json:"up"`
+}
+
+// WhoisSummary is the data of an EventWhois event
+type WhoisSummary struct {
+	Domain         string `json:"domain"`
+	Source         string `json:"source,omitempty"`
+	Registrar      string `json:"registrar,omitempty"`
+	CreatedDate    string `json:"created_date,omitempty"`
+	ExpirationDate string `json:"expiration_date,omitempty"`
+	DomainAge      int    `json:"domain_age"`
+}
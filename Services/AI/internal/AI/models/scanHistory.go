@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	whoisparser "github.com/likexian/whois-parser"
+)
+
+// ScanInputs is everything the verdict of a scan was based on
+type ScanInputs struct {
+	Scraper   *scraperModels.ScrapeReport   `json:"scraper"`
+	Whois     whoisparser.WhoisInfo         `json:"whois"`
+	Enamad    *scraperModels.Enamad_Data    `json:"enamad"`
+	TLS       *scraperModels.TLSReport      `json:"tls,omitempty"`
+	Redirects *scraperModels.RedirectReport `json:"redirects,omitempty"`
+	DNS       *scraperModels.DNSReport      `json:"dns,omitempty"`
+	// TrustSeals are the checks of the badges shown on the site
+	TrustSeals []scraperModels.SealVerification `json:"trust_seals,omitempty"`
+	// Compliance is what the Iranian business registries say about the site
+	Compliance *scraperModels.ComplianceReport `json:"compliance,omitempty"`
+	RiskInput  scraperModels.RiskInput         `json:"risk_input"`
+}
+
+// ScanHistoryEntry is one completed scan stored in scan_history
+type ScanHistoryEntry struct {
+	ID         int64       `json:"id"`
+	URL        string      `json:"url"`
+	ScannedAt  time.Time   `json:"scanned_at"`
+	Source     string      `json:"source"`
+	TrustScore int         `json:"trust_score"`
+	RiskLevel  string      `json:"risk_level"`
+	Result     ScanResult  `json:"result"`
+	Inputs     *ScanInputs `json:"inputs,omitempty"`
+}
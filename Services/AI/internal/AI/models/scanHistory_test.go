@@ -0,0 +1,60 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	whoisparser "github.com/likexian/whois-parser"
+)
+
+func TestScanHistoryEntryRoundTrip(t *testing.T) {
+	inputs := &ScanInputs{
+		Scraper: &scraperModels.ScrapeReport{Domain: "example.ir", Site: scraperModels.SiteSummary{PagesCrawled: 3}},
+		Whois:   whoisparser.WhoisInfo{Registrar: &whoisparser.Contact{Name: "IRNIC"}},
+		Enamad:  &scraperModels.Enamad_Data{Domain: "example.ir", LogoLevel: 2},
+		RiskInput: scraperModels.RiskInput{
+			Keywords:      []string{"urgent"},
+			DomainAgeDays: 12,
+		},
+	}
+	entry := ScanHistoryEntry{
+		ID:         7,
+		URL:        "example.ir",
+		ScannedAt:  time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Source:     VerdictSourceAI,
+		TrustScore: 40,
+		RiskLevel:  RiskMedium,
+		Result: ScanResult{
+			TrustVerdict: TrustVerdict{TrustScore: 40, RiskLevel: RiskMedium},
+			Source:       VerdictSourceAI,
+			Inputs:       inputs,
+		},
+		Inputs: inputs,
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatalf("Marshal returned an error: %v", err)
+	}
+	// the inputs only appear once, the embedded result never carries them
+	if strings.Count(string(data), `"risk_input"`) != 1 {
+		t.Errorf("expected the inputs once, got %s", data)
+	}
+
+	var got ScanHistoryEntry
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal returned an error: %v", err)
+	}
+	if got.Result.TrustScore != 40 || got.Inputs.Whois.Registrar.Name != "IRNIC" || got.Inputs.Enamad.LogoLevel != 2 {
+		t.Errorf("got %+v", got)
+	}
+	if got.Inputs.Scraper.Site.PagesCrawled != 3 {
+		t.Errorf("got scraper report %+v", got.Inputs.Scraper)
+	}
+	if got.Inputs.RiskInput.DomainAgeDays != 12 || len(got.Inputs.RiskInput.Keywords) != 1 {
+		t.Errorf("got risk input %+v", got.Inputs.RiskInput)
+	}
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Pipeline stages reported while a scan runs
+const (
+	StageScrape = "scrape"
+	StageWhois  = "whois"
+	StageEnamad = "enamad"
+	StageLLM    = "llm"
+)
+
+// ScanStages lists the pipeline stages in execution order
+var ScanStages = []string{StageScrape, StageWhois, StageEnamad, StageLLM}
+
+// Status values shared by jobs and their stages
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+	// StatusRetry tells streaming clients a stage starts over and its partial output is void
+	StatusRetry = "retry"
+)
+
+// StageProgress is the state of one pipeline stage of a job
+type StageProgress struct {
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// ScanJob is an asynchronous scan persisted in the scan_jobs table
+type ScanJob struct {
+	ID        string          `json:"id"`
+	URL       string          `json:"url"`
+	Status    string          `json:"status"`
+	Stages    []StageProgress `json:"stages"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// NewScanJob returns a queued job with every stage pending
+func NewScanJob(id, url string) *ScanJob {
+	now := time.Now()
+	job := &ScanJob{
+		ID:        id,
+		URL:       url,
+		Status:    StatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for _, stage := range ScanStages {
+		job.Stages = append(job.Stages, StageProgress{Name: stage, Status: StatusQueued})
+	}
+	return job
+}
+
+// SetStage records a status change of one stage
+func (j *ScanJob) SetStage(stage, status string, err error) {
+	now := time.Now()
+	for i := range j.Stages {
+		if j.Stages[i].Name != stage {
+			continue
+		}
+		j.Stages[i].Status = status
+		switch status {
+		case StatusRunning:
+			j.Stages[i].StartedAt = &now
+		case StatusDone, StatusFailed, StatusSkipped:
+			j.Stages[i].FinishedAt = &now
+		}
+		if err != nil {
+			j.Stages[i].Error = err.Error()
+		}
+	}
+	j.UpdatedAt = now
+}
+
+// Apply records the stage changes carried by a pipeline event
+func (j *ScanJob) Apply(event ScanEvent) {
+	// a retried stage keeps running, only its streamed output is dropped
+	if event.Type != EventStage || event.Status == StatusRetry {
+		return
+	}
+
+	var err error
+	if event.Error != "" {
+		err = errors.New(event.Error)
+	}
+	j.SetStage(event.Stage, event.Status, err)
+}
+
+// Progress is the percentage of finished stages
+func (j *ScanJob) Progress() int {
+	if j.Status == StatusDone {
+		return 100
+	}
+	if len(j.Stages) == 0 {
+		return 0
+	}
+
+	finished := 0
+	for _, stage := range j.Stages {
+		switch stage.Status {
+		case StatusDone, StatusFailed, StatusSkipped:
+			finished++
+		}
+	}
+	return finished * 100 / len(j.Stages)
+}
+
+// MarshalJSON adds the computed progress to the job
+func (j ScanJob) MarshalJSON() ([]byte, error) {
+	type scanJob ScanJob
+	return json.Marshal(struct {
+		scanJob
+		Progress int `json:"progress"`
+	}{scanJob(j), j.Progress()})
+}
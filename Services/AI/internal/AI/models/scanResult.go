@@ -0,0 +1,52 @@
+package models
+
+import (
+	"fmt"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+)
+
+const (
+	VerdictSourceAI    = "ai"
+	VerdictSourceRules = "rules"
+)
+
+// ScanResult is what Scan returns and stores: the verdict fields stay at the top
+// level so existing clients keep working, the rule score is returned next to them
+type ScanResult struct {
+	TrustVerdict
+	Source    string                   `json:"source"`
+	RuleScore *scraperModels.RiskScore `json:"ruleScore,omitempty"`
+	// Compliance is the regulatory compliance section, it does not come from the model
+	Compliance *scraperModels.ComplianceReport `json:"compliance,omitempty"`
+	// Cache is only set on responses, it is not stored
+	Cache *CacheInfo `json:"cache,omitempty"`
+
+	// Inputs are kept in the scan history but not sent to clients
+	Inputs *ScanInputs `json:"-"`
+}
+
+// VerdictFromRiskScore turns the rule engine result into a verdict, used when the model is unavailable
+func VerdictFromRiskScore(score *scraperModels.RiskScore) TrustVerdict {
+	verdict := TrustVerdict{
+		TrustScore:     score.TrustScore,
+		RiskLevel:      score.RiskLevel,
+		PositivePoints: []string{},
+		NegativePoints: []string{},
+		TechnicalFlags: make(map[string]int),
+		Description: fmt.Sprintf("The AI analysis was unavailable, this verdict was computed by the rule engine. "+
+			"%d of %d rules raised concerns, giving a risk score of %d out of 100.",
+			len(score.Triggered()), len(score.Contributions), score.Score),
+	}
+
+	for _, c := range score.Contributions {
+		if c.Points > 0 {
+			verdict.NegativePoints = append(verdict.NegativePoints, c.Evidence)
+			verdict.TechnicalFlags[c.Rule] = -int(c.Points + 0.5)
+		} else {
+			verdict.PositivePoints = append(verdict.PositivePoints, "No issue found for rule "+c.Rule)
+		}
+	}
+
+	return verdict
+}
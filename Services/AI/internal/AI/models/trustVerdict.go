@@ -0,0 +1,182 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
+
+// TrustVerdict is the validated answer of the model for a single website
+type TrustVerdict struct {
+	TrustScore     int            `json:"trustScore"`
+	RiskLevel      string         `json:"riskLevel"`
+	PositivePoints []string       `json:"positivePoints"`
+	NegativePoints []string       `json:"negativePoints"`
+	Description    string         `json:"description"`
+	TechnicalFlags map[string]int `json:"technicalFlags"`
+}
+
+// ValidationError lists every problem found in a model answer so it can be sent back for repair
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid verdict: " + strings.Join(e.Problems, "; ")
+}
+
+var (
+	ErrNoJSONObject = errors.New("no JSON object found in the model output")
+
+	thinkBlock = regexp.MustCompile(`(?is)<think>.*?</think>`)
+)
+
+// ExtractJSONObject returns the first complete JSON object found in the model output,
+// ignoring <think> reasoning, markdown fences and any prose around it
+func ExtractJSONObject(output string) ([]byte, error) {
+	text := thinkBlock.ReplaceAllString(output, "")
+	// an unterminated reasoning block leaves only the text after its closing tag usable
+	if i := strings.LastIndex(strings.ToLower(text), "</think>"); i >= 0 {
+		text = text[i+len("</think>"):]
+	}
+
+	for start := strings.IndexByte(text, '{'); start >= 0; {
+		if end := matchingBrace(text, start); end > 0 {
+			candidate := []byte(text[start : end+1])
+			if json.Valid(candidate) {
+				return candidate, nil
+			}
+		}
+
+		next := strings.IndexByte(text[start+1:], '{')
+		if next < 0 {
+			break
+		}
+		start += next + 1
+	}
+
+	return nil, ErrNoJSONObject
+}
+
+// matchingBrace returns the index of the brace closing the one at start, or -1
+func matchingBrace(text string, start int) int {
+	depth := 0
+	inString := false
+	escaped := false
+
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// ParseTrustVerdict extracts, decodes and validates the verdict from raw model output
+func ParseTrustVerdict(output string) (*TrustVerdict, error) {
+	raw, err := ExtractJSONObject(output)
+	if err != nil {
+		return nil, err
+	}
+
+	// every field is required, a missing trustScore must not silently become 0
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, &ValidationError{Problems: []string{err.Error()}}
+	}
+
+	var problems []string
+	for _, name := range []string{"trustScore", "riskLevel", "positivePoints", "negativePoints", "description", "technicalFlags"} {
+		if _, ok := fields[name]; !ok {
+			problems = append(problems, fmt.Sprintf("missing field %q", name))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	var verdict TrustVerdict
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&verdict); err != nil {
+		return nil, &ValidationError{Problems: []string{err.Error()}}
+	}
+
+	verdict.RiskLevel = strings.ToLower(strings.TrimSpace(verdict.RiskLevel))
+	if err := verdict.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &verdict, nil
+}
+
+// Validate checks ranges and enum values of the verdict
+func (v *TrustVerdict) Validate() error {
+	var problems []string
+
+	if v.TrustScore < 0 || v.TrustScore > 100 {
+		problems = append(problems, fmt.Sprintf("trustScore %d is outside 0-100", v.TrustScore))
+	}
+
+	switch v.RiskLevel {
+	case RiskLow, RiskMedium, RiskHigh:
+	default:
+		problems = append(problems, fmt.Sprintf("riskLevel %q must be one of low, medium, high", v.RiskLevel))
+	}
+
+	if strings.TrimSpace(v.Description) == "" {
+		problems = append(problems, "description must not be empty")
+	}
+
+	for i, point := range v.PositivePoints {
+		if strings.TrimSpace(point) == "" {
+			problems = append(problems, fmt.Sprintf("positivePoints[%d] is empty", i))
+		}
+	}
+	for i, point := range v.NegativePoints {
+		if strings.TrimSpace(point) == "" {
+			problems = append(problems, fmt.Sprintf("negativePoints[%d] is empty", i))
+		}
+	}
+
+	for flag, weight := range v.TechnicalFlags {
+		if weight < -100 || weight > 100 {
+			problems = append(problems, fmt.Sprintf("technicalFlags[%q] = %d is outside -100..100", flag, weight))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
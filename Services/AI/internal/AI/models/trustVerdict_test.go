@@ -0,0 +1,178 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+)
+
+func TestExtractJSONObject(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "JSON with backticks and json text",
+			input:    "```json{\"name\": \"test\", \"value\": 123}```",
+			expected: "{\"name\": \"test\", \"value\": 123}",
+		},
+		{
+			name:     "JSON with partial markdown",
+			input:    "```json{\"status\": \"success\"}",
+			expected: "{\"status\": \"success\"}",
+		},
+		{
+			name:     "JSON with newlines and markdown",
+			input:    "```json\n{\n  \"multiline\": \"json\"\n}\n```",
+			expected: "{\n  \"multiline\": \"json\"\n}",
+		},
+		{
+			name:     "JSON surrounded by prose",
+			input:    "Here is my analysis: {\"trustScore\": 10} Hope this helps!",
+			expected: "{\"trustScore\": 10}",
+		},
+		{
+			name:     "Reasoning block containing braces",
+			input:    "<think>maybe {score: 5}? no, {\"a\": 1}</think>\n{\"trustScore\": 90}",
+			expected: "{\"trustScore\": 90}",
+		},
+		{
+			name:     "Unterminated reasoning block",
+			input:    "{\"draft\": true} still thinking</think>{\"trustScore\": 40}",
+			expected: "{\"trustScore\": 40}",
+		},
+		{
+			name:     "Braces inside strings",
+			input:    "{\"description\": \"uses } and { in text \\\" quoted\"}",
+			expected: "{\"description\": \"uses } and { in text \\\" quoted\"}",
+		},
+		{
+			name:     "Invalid object followed by a valid one",
+			input:    "{not json} {\"ok\": true}",
+			expected: "{\"ok\": true}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractJSONObject(tt.input)
+			if err != nil {
+				t.Fatalf("ExtractJSONObject() returned an error: %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("ExtractJSONObject() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExtractJSONObjectNotFound(t *testing.T) {
+	for _, input := range []string{"", "```json```", "no json at all", "{\"unterminated\": true", "<think>{\"a\": 1}</think>"} {
+		if _, err := ExtractJSONObject(input); !errors.Is(err, ErrNoJSONObject) {
+			t.Errorf("ExtractJSONObject(%q) error = %v, want ErrNoJSONObject", input, err)
+		}
+	}
+}
+
+func TestParseTrustVerdict(t *testing.T) {
+	input := "```json\n" + `{
+  "trustScore": 82,
+  "riskLevel": "Low",
+  "positivePoints": ["Domain registered for 10 years"],
+  "negativePoints": [],
+  "description": "Established shop with Enamad certification",
+  "technicalFlags": {"HasValidSSL": 15, "DomainAgeOver2Years": 20}
+}` + "\n```"
+
+	got, err := ParseTrustVerdict(input)
+	if err != nil {
+		t.Fatalf("ParseTrustVerdict() returned an error: %v", err)
+	}
+
+	if got.TrustScore != 82 {
+		t.Errorf("got trustScore %d, want 82", got.TrustScore)
+	}
+	if got.RiskLevel != RiskLow {
+		t.Errorf("got riskLevel %q, want %q", got.RiskLevel, RiskLow)
+	}
+	if got.TechnicalFlags["DomainAgeOver2Years"] != 20 {
+		t.Errorf("got technicalFlags %v", got.TechnicalFlags)
+	}
+}
+
+func TestParseTrustVerdictRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		problem string
+	}{
+		{
+			name:    "score out of range",
+			input:   `{"trustScore": 101, "riskLevel": "low", "positivePoints": [], "negativePoints": [], "description": "d", "technicalFlags": {}}`,
+			problem: "trustScore 101 is outside 0-100",
+		},
+		{
+			name:    "unknown risk level",
+			input:   `{"trustScore": 50, "riskLevel": "critical", "positivePoints": [], "negativePoints": [], "description": "d", "technicalFlags": {}}`,
+			problem: `riskLevel "critical" must be one of low, medium, high`,
+		},
+		{
+			name:    "missing score",
+			input:   `{"riskLevel": "low", "positivePoints": [], "negativePoints": [], "description": "d", "technicalFlags": {}}`,
+			problem: `missing field "trustScore"`,
+		},
+		{
+			name:    "unknown field",
+			input:   `{"trustScore": 50, "riskLevel": "low", "positivePoints": [], "negativePoints": [], "description": "d", "technicalFlags": {}, "verdict": "safe"}`,
+			problem: `unknown field "verdict"`,
+		},
+		{
+			name:    "wrong type",
+			input:   `{"trustScore": "high", "riskLevel": "low", "positivePoints": [], "negativePoints": [], "description": "d", "technicalFlags": {}}`,
+			problem: "cannot unmarshal string",
+		},
+		{
+			name:    "empty description",
+			input:   `{"trustScore": 50, "riskLevel": "low", "positivePoints": [], "negativePoints": [], "description": " ", "technicalFlags": {}}`,
+			problem: "description must not be empty",
+		},
+		{
+			name:    "flag weight out of range",
+			input:   `{"trustScore": 50, "riskLevel": "low", "positivePoints": [], "negativePoints": [], "description": "d", "technicalFlags": {"HasValidSSL": 500}}`,
+			problem: "is outside -100..100",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTrustVerdict(tt.input)
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("ParseTrustVerdict() error = %v, want a ValidationError", err)
+			}
+			if !strings.Contains(err.Error(), tt.problem) {
+				t.Errorf("ParseTrustVerdict() error = %q, want it to mention %q", err.Error(), tt.problem)
+			}
+		})
+	}
+}
+
+func TestVerdictFromRiskScoreIsValid(t *testing.T) {
+	engine := scraperModels.NewRiskEngine(nil)
+	score := engine.Evaluate(scraperModels.RiskInput{Keywords: []string{"act now"}, DomainAgeDays: 10})
+
+	verdict := VerdictFromRiskScore(score)
+	if err := verdict.Validate(); err != nil {
+		t.Fatalf("fallback verdict does not validate: %v", err)
+	}
+	if verdict.TrustScore != score.TrustScore || verdict.RiskLevel != score.RiskLevel {
+		t.Errorf("got %+v for score %+v", verdict, score)
+	}
+	if len(verdict.NegativePoints) != len(score.Triggered()) {
+		t.Errorf("got %d negative points, wanted %d", len(verdict.NegativePoints), len(score.Triggered()))
+	}
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	whoisparser "github.com/likexian/whois-parser"
+)
+
+// Sources of a WhoisRecord
+const (
+	WhoisSourceRDAP  = "rdap"
+	WhoisSourceWhois = "whois"
+)
+
+// whoisDateLayouts are the date formats seen in RDAP events and whois records, most common first
+var whoisDateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05-07",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006.01.02 15:04:05",
+	"2006.01.02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"20060102",
+	"02-Jan-2006 15:04:05 MST",
+	"02-Jan-2006 15:04:05",
+	"02-Jan-2006",
+	"2006-Jan-02",
+	"02.01.2006 15:04:05",
+	"02.01.2006",
+	"02/01/2006",
+	"02-01-2006",
+	"January _2 2006",
+	"January _2, 2006",
+	"Mon Jan _2 2006",
+	time.UnixDate,
+	time.ANSIC,
+	time.RubyDate,
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.RFC822Z,
+	time.RFC822,
+}
+
+// ParseWhoisDate reads the many date formats of registries. Solar dates like 1402/02/11,
+// used by some Iranian registrars, are converted to the Gregorian calendar.
+func ParseWhoisDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	// trailing notes like "2020-01-02 (YYYY-MM-DD)" are dropped
+	if i := strings.Index(value, " ("); i > 0 {
+		value = value[:i]
+	}
+	value = strings.TrimSuffix(value, ".")
+	if value == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+
+	// a solar year would otherwise be read as a Gregorian year of the middle ages
+	if len(value) >= 4 {
+		if year, err := strconv.Atoi(value[:4]); err == nil && year >= 1200 && year <= 1600 {
+			return scraperModels.ParseJalaliDate(value)
+		}
+	}
+
+	for _, layout := range whoisDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	// solar dates written with Persian digits
+	if t, err := scraperModels.ParseJalaliDate(value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("could not parse %q as a date", value)
+}
+
+// WhoisRecord is the registration data of a domain and where it came from
+type WhoisRecord struct {
+	Domain string `json:"domain"`
+	Source string `json:"source"`
+	// Server is the RDAP base url or whois server that answered
+	Server    string                `json:"server,omitempty"`
+	Info      whoisparser.WhoisInfo `json:"info"`
+	CreatedAt *time.Time            `json:"created_at,omitempty"`
+	UpdatedAt *time.Time            `json:"updated_at,omitempty"`
+	ExpiresAt *time.Time            `json:"expires_at,omitempty"`
+	FetchedAt time.Time             `json:"fetched_at"`
+	// Cached is set when the record was read from the cache instead of the registry
+	Cached bool `json:"cached"`
+}
+
+// ResolveDates parses the date strings of Info into CreatedAt, UpdatedAt and ExpiresAt
+func (r *WhoisRecord) ResolveDates() {
+	if r.Info.Domain == nil {
+		return
+	}
+	parse := func(value string, parsed *time.Time) *time.Time {
+		if parsed != nil {
+			return parsed
+		}
+		if t, err := ParseWhoisDate(value); err == nil {
+			return &t
+		}
+		return nil
+	}
+	r.CreatedAt = parse(r.Info.Domain.CreatedDate, r.Info.Domain.CreatedDateInTime)
+	r.UpdatedAt = parse(r.Info.Domain.UpdatedDate, r.Info.Domain.UpdatedDateInTime)
+	r.ExpiresAt = parse(r.Info.Domain.ExpirationDate, r.Info.Domain.ExpirationDateInTime)
+}
+
+// DomainAge is the age of the registration in days at now, DomainAgeUnknown without a creation date
+func (r *WhoisRecord) DomainAge(now time.Time) int {
+	if r == nil || r.CreatedAt == nil || r.CreatedAt.After(now) {
+		return scraperModels.DomainAgeUnknown
+	}
+	return int(now.Sub(*r.CreatedAt).Hours() / 24)
+}
+
+// Summary is the data of an EventWhois event
+func (r *WhoisRecord) Summary(now time.Time) WhoisSummary {
+	summary := WhoisSummary{Domain: r.Domain, Source: r.Source, DomainAge: r.DomainAge(now)}
+	if r.Info.Domain != nil {
+		summary.CreatedDate = r.Info.Domain.CreatedDate
+		summary.ExpirationDate = r.Info.Domain.ExpirationDate
+	}
+	if r.Info.Registrar != nil {
+		summary.Registrar = r.Info.Registrar.Name
+	}
+	return summary
+}
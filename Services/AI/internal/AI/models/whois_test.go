@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	whoisparser "github.com/likexian/whois-parser"
+)
+
+func TestParseWhoisDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"1995-08-14T04:00:00Z", "1995-08-14"},
+		{"2019-03-04T05:06:07.0Z", "2019-03-04"},
+		{"2024-01-02", "2024-01-02"},
+		{"2024-01-02 (YYYY-MM-DD)", "2024-01-02"},
+		{"2020-12-22 10:30:00 +0330", "2020-12-22"},
+		{"14-Aug-1995", "1995-08-14"},
+		{"2005.06.07", "2005-06-07"},
+		{"07.06.2005", "2005-06-07"},
+		{"1402/02/11", "2023-05-01"},
+		{"۱۴۰۳/۰۱/۰۱", "2024-03-20"},
+		{"not a date", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseWhoisDate(tt.value)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ParseWhoisDate(%q) got %v, wanted an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got.Format("2006-01-02") != tt.want {
+			t.Errorf("ParseWhoisDate(%q) got %v %v, wanted %s", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestWhoisRecordDomainAge(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	record := &WhoisRecord{Info: whoisparser.WhoisInfo{Domain: &whoisparser.Domain{CreatedDate: "2024-01-01"}}}
+	record.ResolveDates()
+	if got := record.DomainAge(now); got != 366 {
+		t.Errorf("got %d days, wanted 366", got)
+	}
+
+	// a record without a creation date, or no record at all, has an unknown age
+	missing := &WhoisRecord{Info: whoisparser.WhoisInfo{Domain: &whoisparser.Domain{CreatedDate: "soon"}}}
+	missing.ResolveDates()
+	var none *WhoisRecord
+	if missing.DomainAge(now) != scraperModels.DomainAgeUnknown || none.DomainAge(now) != scraperModels.DomainAgeUnknown {
+		t.Errorf("got %d and %d, wanted unknown ages", missing.DomainAge(now), none.DomainAge(now))
+	}
+}
@@ -11,11 +11,21 @@ func NewRouter(aiHandler *handlers.AIHandler) *mux.Router {
 
 	// All the endpoints are handled here
 	r.HandleFunc("/scan/{url}", aiHandler.Scan).Methods("GET")
-	r.HandleFunc("/whois/{url}", handlers.GetWhoisData).Methods("GET")
-	r.HandleFunc("/urls/recent", aiHandler.GetRecentURLs)          // GET - Get recent URLs
-	r.HandleFunc("/urls/date-range", aiHandler.GetURLsByDateRange) // GET - Get URLs by date range
-	r.HandleFunc("/urls/search", aiHandler.SearchURLs)             // GET - Search URLs
+	r.HandleFunc("/scan/{url}/events", aiHandler.ScanEvents).Methods("GET")  // GET - Scan streaming progress as server-sent events
+	r.HandleFunc("/scans", aiHandler.CreateScanJob).Methods("POST")          // POST - Enqueue an asynchronous scan
+	r.HandleFunc("/scans/batch", aiHandler.CreateScanBatch).Methods("POST")  // POST - Enqueue the scans of a list of URLs
+	r.HandleFunc("/scans/batch/{id}", aiHandler.GetScanBatch).Methods("GET") // GET - Aggregated results of a batch, as JSON or CSV
+	r.HandleFunc("/scans/{id}", aiHandler.GetScanJob).Methods("GET")         // GET - Status and result of a scan job
+	r.HandleFunc("/whois/{url}", aiHandler.GetWhoisData).Methods("GET")      // GET - Registration data of a URL
+	r.HandleFunc("/tls/{url}", aiHandler.GetTLSData).Methods("GET")          // GET - TLS certificate of a URL
+	r.HandleFunc("/dns/{url}", aiHandler.GetDNSData).Methods("GET")          // GET - DNS records and hosting of a URL
+	r.HandleFunc("/urls/recent", aiHandler.GetRecentURLs)                    // GET - Get recent URLs
+	r.HandleFunc("/urls/date-range", aiHandler.GetURLsByDateRange)           // GET - Get URLs by date range
+	r.HandleFunc("/urls/search", aiHandler.SearchURLs)                       // GET - Search URLs
 	r.HandleFunc("/urls/stats", aiHandler.GetURLStats)
+	r.HandleFunc("/urls/flagged", aiHandler.GetFlaggedURLs).Methods("GET")       // GET - URLs whose trust score dropped sharply
+	r.HandleFunc("/urls/{url}/history", aiHandler.GetScanHistory).Methods("GET") // GET - Every stored scan of a URL
+	r.HandleFunc("/urls/{url}/diff", aiHandler.GetScanDiff).Methods("GET")       // GET - Changes between consecutive scans of a URL
 
 	return r
 }
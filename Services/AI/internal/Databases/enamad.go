@@ -0,0 +1,47 @@
+package Databases
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+)
+
+// GetEnamad returns the cached Enamad certificate of domain, ErrNotFound when there is none
+func (db *PostgreSQL) GetEnamad(ctx context.Context, domain string) (*scraperModels.Enamad_Data, error) {
+	query := `SELECT data FROM enamad_cache WHERE domain = $1`
+
+	var raw []byte
+	err := db.DB.QueryRowContext(ctx, query, domain).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying enamad cache: %v", err)
+	}
+
+	var data scraperModels.Enamad_Data
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("error decoding cached enamad of %s: %v", domain, err)
+	}
+	return &data, nil
+}
+
+// SaveEnamad stores the Enamad certificate of domain, replacing the previous one. Domains
+// without a certificate are stored too, data then has no ID.
+func (db *PostgreSQL) SaveEnamad(ctx context.Context, domain string, data *scraperModels.Enamad_Data) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error marshaling enamad data: %v", err)
+	}
+
+	query := `INSERT INTO enamad_cache (domain, data, fetched_at) VALUES ($1, $2, $3)
+			  ON CONFLICT (domain) DO UPDATE SET data = EXCLUDED.data, fetched_at = EXCLUDED.fetched_at`
+
+	if _, err := db.DB.ExecContext(ctx, query, domain, raw, data.FetchedAt); err != nil {
+		return fmt.Errorf("error saving enamad data: %v", err)
+	}
+	return nil
+}
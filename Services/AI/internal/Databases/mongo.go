@@ -120,7 +120,7 @@ func (db *MongoDB) GetLatestScreenshotByDomain(collectionName string, domain str
 	defer cancel()
 
 	// Find the most recent screenshot for the domain
-	opts := options.FindOne().SetSort(bson.D{{"createdAt", -1}})
+	opts := options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}})
 
 	var result ScreenshotDocument
 	err := collection.FindOne(ctx, bson.M{"domain": domain}, opts).Decode(&result)
@@ -143,8 +143,8 @@ func (db *MongoDB) ListScreenshots(collectionName string) ([]ScreenshotInfo, err
 
 	// Only retrieve metadata, exclude the large screenshot data
 	opts := options.Find().
-		SetProjection(bson.M{"screenshot": 0}). // Exclude screenshot data
-		SetSort(bson.D{{"createdAt", -1}})      // Sort by creation time, newest first
+		SetProjection(bson.M{"screenshot": 0}).        // Exclude screenshot data
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}) // Sort by creation time, newest first
 
 	cursor, err := collection.Find(ctx, bson.M{}, opts)
 	if err != nil {
@@ -183,8 +183,8 @@ func (db *MongoDB) GetScreenshotsByDomain(collectionName string, domain string)
 
 	// Only retrieve metadata, exclude the large screenshot data
 	opts := options.Find().
-		SetProjection(bson.M{"screenshot": 0}). // Exclude screenshot data
-		SetSort(bson.D{{"createdAt", -1}})      // Sort by creation time, newest first
+		SetProjection(bson.M{"screenshot": 0}).        // Exclude screenshot data
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}) // Sort by creation time, newest first
 
 	cursor, err := collection.Find(ctx, bson.M{"domain": domain}, opts)
 	if err != nil {
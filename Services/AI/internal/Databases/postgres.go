@@ -127,7 +127,7 @@ func (db *PostgreSQL) CheckIfurlExistsInDB(site string, tableName string) bool {
 }
 func (db *PostgreSQL) IsRecent(site string, tableName string) bool {
 
-	query := `select search_date from ` + tableName + ` where url = $1 LIMIT 1`
+	query := `select search_date from ` + tableName + ` where url = $1 ORDER BY search_date DESC LIMIT 1`
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	var searchDate time.Time
@@ -149,8 +149,25 @@ func (db *PostgreSQL) IsRecent(site string, tableName string) bool {
 	// Check if the search_date is after one week ago
 	return searchDate.After(oneWeekAgo)
 }
+
+// LatestSavedData returns the newest stored response of site and when it was saved
+func (db *PostgreSQL) LatestSavedData(ctx context.Context, site string, tableName string) (string, time.Time, error) {
+	query := `select description, search_date from ` + tableName + ` where url = $1 ORDER BY search_date DESC LIMIT 1`
+
+	var description string
+	var searchDate time.Time
+	err := db.DB.QueryRowContext(ctx, query, site).Scan(&description, &searchDate)
+	if err == sql.ErrNoRows {
+		return "", time.Time{}, ErrNotFound
+	}
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error retrieving saved data: %v", err)
+	}
+	return description, searchDate, nil
+}
+
 func (db *PostgreSQL) RetreiveSavedData(site string, tableName string) string {
-	query := `select description from ` + tableName + ` where url = $1 LIMIT 1`
+	query := `select description from ` + tableName + ` where url = $1 ORDER BY search_date DESC LIMIT 1`
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
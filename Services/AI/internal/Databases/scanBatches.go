@@ -0,0 +1,89 @@
+package Databases
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+	"github.com/lib/pq"
+)
+
+// CreateScanBatch inserts a new batch into scan_batches and its jobs into scan_jobs in one
+// transaction, a failure leaves no job behind for the next start to resume
+func (db *PostgreSQL) CreateScanBatch(ctx context.Context, batch *models.ScanBatch, jobs []*models.ScanJob) error {
+	jobIDs, err := json.Marshal(batch.JobIDs)
+	if err != nil {
+		return fmt.Errorf("error marshaling batch jobs: %v", err)
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	// a no-op once committed
+	defer tx.Rollback()
+
+	for _, job := range jobs {
+		if err := insertScanJob(ctx, tx, job); err != nil {
+			return err
+		}
+	}
+
+	query := `INSERT INTO scan_batches (id, job_ids, created_at) VALUES ($1, $2, $3)`
+
+	if _, err := tx.ExecContext(ctx, query, batch.ID, jobIDs, batch.CreatedAt); err != nil {
+		return fmt.Errorf("error inserting scan batch: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing scan batch: %v", err)
+	}
+	return nil
+}
+
+// GetScanBatch retrieves a batch by its id
+func (db *PostgreSQL) GetScanBatch(ctx context.Context, id string) (*models.ScanBatch, error) {
+	query := `SELECT id, job_ids, created_at FROM scan_batches WHERE id = $1`
+
+	var batch models.ScanBatch
+	var jobIDs []byte
+	err := db.DB.QueryRowContext(ctx, query, id).Scan(&batch.ID, &jobIDs, &batch.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving scan batch: %v", err)
+	}
+
+	if err := json.Unmarshal(jobIDs, &batch.JobIDs); err != nil {
+		return nil, fmt.Errorf("error decoding batch jobs: %v", err)
+	}
+	return &batch, nil
+}
+
+// ListScanJobs returns the jobs with the given ids, in no particular order
+func (db *PostgreSQL) ListScanJobs(ctx context.Context, ids []string) ([]*models.ScanJob, error) {
+	query := `SELECT id, url, status, stages, result, error, created_at, updated_at FROM scan_jobs WHERE id = ANY($1)`
+
+	rows, err := db.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("error querying scan jobs: %v", err)
+	}
+	defer rows.Close()
+
+	var jobs []*models.ScanJob
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning scan job: %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %v", err)
+	}
+	return jobs, nil
+}
@@ -0,0 +1,165 @@
+package Databases
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+	"github.com/lib/pq"
+)
+
+// SaveScanHistory stores a completed scan with its inputs and returns its id
+func (db *PostgreSQL) SaveScanHistory(ctx context.Context, entry *models.ScanHistoryEntry) (int64, error) {
+	result, err := json.Marshal(entry.Result)
+	if err != nil {
+		return 0, fmt.Errorf("error marshaling scan result: %v", err)
+	}
+
+	var inputs []byte
+	if entry.Inputs != nil {
+		if inputs, err = json.Marshal(entry.Inputs); err != nil {
+			return 0, fmt.Errorf("error marshaling scan inputs: %v", err)
+		}
+	}
+
+	query := `INSERT INTO scan_history (url, scanned_at, source, trust_score, risk_level, result, inputs)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+
+	var id int64
+	err = db.DB.QueryRowContext(ctx, query, entry.URL, entry.ScannedAt, entry.Source, entry.TrustScore, entry.RiskLevel, result, nullableJSON(inputs)).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting scan history: %v", err)
+	}
+	return id, nil
+}
+
+// GetScanHistory returns the most recent scans of a url, newest first
+func (db *PostgreSQL) GetScanHistory(ctx context.Context, url string, limit int) ([]models.ScanHistoryEntry, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	query := `SELECT id, url, scanned_at, source, trust_score, risk_level, result, inputs FROM scan_history
+			  WHERE url = $1
+			  ORDER BY scanned_at DESC, id DESC
+			  LIMIT $2`
+
+	rows, err := db.DB.QueryContext(ctx, query, url, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying scan history: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []models.ScanHistoryEntry
+	for rows.Next() {
+		var entry models.ScanHistoryEntry
+		var result, inputs []byte
+
+		err := rows.Scan(&entry.ID, &entry.URL, &entry.ScannedAt, &entry.Source, &entry.TrustScore, &entry.RiskLevel, &result, &inputs)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning scan history: %v", err)
+		}
+
+		if err := json.Unmarshal(result, &entry.Result); err != nil {
+			return nil, fmt.Errorf("error decoding scan result %d: %v", entry.ID, err)
+		}
+		if len(inputs) > 0 {
+			entry.Inputs = &models.ScanInputs{}
+			if err := json.Unmarshal(inputs, entry.Inputs); err != nil {
+				return nil, fmt.Errorf("error decoding scan inputs %d: %v", entry.ID, err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %v", err)
+	}
+	return entries, nil
+}
+
+// GetSharpDrops returns the urls whose latest scan lost at least threshold trust points
+// compared to the previous scan, most recent first. Scans whose verdicts came from different
+// sources, the model and the rule engine, are not compared.
+func (db *PostgreSQL) GetSharpDrops(ctx context.Context, threshold, limit int) ([]models.ScoreDrop, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	query := `WITH ranked AS (
+				SELECT url, scanned_at, trust_score, source,
+					LAG(trust_score) OVER (PARTITION BY url ORDER BY scanned_at, id) AS previous_score,
+					LAG(source) OVER (PARTITION BY url ORDER BY scanned_at, id) AS previous_source,
+					ROW_NUMBER() OVER (PARTITION BY url ORDER BY scanned_at DESC, id DESC) AS position
+				FROM scan_history
+			  )
+			  SELECT url, scanned_at, previous_score, trust_score FROM ranked
+			  WHERE position = 1 AND previous_source = source AND previous_score - trust_score >= $1
+			  ORDER BY scanned_at DESC
+			  LIMIT $2`
+
+	rows, err := db.DB.QueryContext(ctx, query, threshold, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying score drops: %v", err)
+	}
+	defer rows.Close()
+
+	drops := []models.ScoreDrop{}
+	for rows.Next() {
+		var drop models.ScoreDrop
+		if err := rows.Scan(&drop.URL, &drop.ScannedAt, &drop.PreviousScore, &drop.TrustScore); err != nil {
+			return nil, fmt.Errorf("error scanning score drop: %v", err)
+		}
+		drop.ScoreDelta = drop.TrustScore - drop.PreviousScore
+		drops = append(drops, drop)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %v", err)
+	}
+	return drops, nil
+}
+
+// FlaggedSharingAddresses returns the other urls whose latest stored verdict is high risk and
+// whose last recorded DNS answers share one of addresses
+func (db *PostgreSQL) FlaggedSharingAddresses(ctx context.Context, url string, addresses []string) ([]string, error) {
+	// url_storage keeps every verdict of a url, an old high risk one must not flag a site
+	// that was cleared since. Its rows are written by json.Marshal, the compact form is
+	// matched as text since rows of the other services are not guaranteed to be valid JSON.
+	query := `WITH verdicts AS (
+				SELECT DISTINCT ON (url) url, description FROM url_storage
+				ORDER BY url, search_date DESC
+			  ), answers AS (
+				SELECT DISTINCT ON (url) url, inputs->'dns'->'addresses' AS addresses FROM scan_history
+				WHERE jsonb_typeof(inputs->'dns'->'addresses') = 'array'
+				ORDER BY url, scanned_at DESC, id DESC
+			  )
+			  SELECT a.url FROM answers a
+			  JOIN verdicts v ON v.url = a.url
+			  WHERE a.url <> $1
+			  AND v.description LIKE '%"riskLevel":"high"%'
+			  AND a.addresses ?| $2
+			  ORDER BY a.url
+			  LIMIT 50`
+
+	rows, err := db.DB.QueryContext(ctx, query, url, pq.Array(addresses))
+	if err != nil {
+		return nil, fmt.Errorf("error querying shared addresses: %v", err)
+	}
+	defer rows.Close()
+
+	urls := []string{}
+	for rows.Next() {
+		var shared string
+		if err := rows.Scan(&shared); err != nil {
+			return nil, fmt.Errorf("error scanning shared address: %v", err)
+		}
+		urls = append(urls, shared)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %v", err)
+	}
+	return urls, nil
+}
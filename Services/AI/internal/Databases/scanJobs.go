@@ -0,0 +1,127 @@
+package Databases
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+)
+
+// CreateScanJob inserts a new job into scan_jobs
+func (db *PostgreSQL) CreateScanJob(ctx context.Context, job *models.ScanJob) error {
+	return insertScanJob(ctx, db.DB, job)
+}
+
+// execer is satisfied by *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertScanJob(ctx context.Context, db execer, job *models.ScanJob) error {
+	stages, err := json.Marshal(job.Stages)
+	if err != nil {
+		return fmt.Errorf("error marshaling job stages: %v", err)
+	}
+
+	query := `INSERT INTO scan_jobs (id, url, status, stages, result, error, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err = db.ExecContext(ctx, query, job.ID, job.URL, job.Status, stages, nullableJSON(job.Result), job.Error, job.CreatedAt, job.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("error inserting scan job: %v", err)
+	}
+	return nil
+}
+
+// UpdateScanJob stores the status, stages and result of a job
+func (db *PostgreSQL) UpdateScanJob(ctx context.Context, job *models.ScanJob) error {
+	stages, err := json.Marshal(job.Stages)
+	if err != nil {
+		return fmt.Errorf("error marshaling job stages: %v", err)
+	}
+
+	query := `UPDATE scan_jobs SET status = $2, stages = $3, result = $4, error = $5, updated_at = $6 WHERE id = $1`
+
+	result, err := db.DB.ExecContext(ctx, query, job.ID, job.Status, stages, nullableJSON(job.Result), job.Error, job.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("error updating scan job: %v", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetScanJob retrieves a job by its id
+func (db *PostgreSQL) GetScanJob(ctx context.Context, id string) (*models.ScanJob, error) {
+	query := `SELECT id, url, status, stages, result, error, created_at, updated_at FROM scan_jobs WHERE id = $1`
+
+	job, err := scanJob(db.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving scan job: %v", err)
+	}
+	return job, nil
+}
+
+// ListUnfinishedScanJobs returns the queued and running jobs, oldest first
+func (db *PostgreSQL) ListUnfinishedScanJobs(ctx context.Context) ([]*models.ScanJob, error) {
+	query := `SELECT id, url, status, stages, result, error, created_at, updated_at FROM scan_jobs
+			  WHERE status IN ($1, $2)
+			  ORDER BY created_at`
+
+	rows, err := db.DB.QueryContext(ctx, query, models.StatusQueued, models.StatusRunning)
+	if err != nil {
+		return nil, fmt.Errorf("error querying unfinished scan jobs: %v", err)
+	}
+	defer rows.Close()
+
+	var jobs []*models.ScanJob
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning scan job: %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %v", err)
+	}
+	return jobs, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row rowScanner) (*models.ScanJob, error) {
+	var job models.ScanJob
+	var stages []byte
+	var result []byte
+
+	err := row.Scan(&job.ID, &job.URL, &job.Status, &stages, &result, &job.Error, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(stages, &job.Stages); err != nil {
+		return nil, fmt.Errorf("error decoding job stages: %v", err)
+	}
+	if len(result) > 0 {
+		job.Result = json.RawMessage(result)
+	}
+	return &job, nil
+}
+
+// nullableJSON stores an empty document as NULL instead of invalid JSON
+func nullableJSON(data json.RawMessage) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return []byte(data)
+}
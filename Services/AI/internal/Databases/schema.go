@@ -0,0 +1,65 @@
+package Databases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned when a looked up record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// schema holds the tables owned by the AI service, url_storage is shared with the other services
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS scan_jobs (
+		id         TEXT PRIMARY KEY,
+		url        TEXT NOT NULL,
+		status     TEXT NOT NULL,
+		stages     JSONB NOT NULL DEFAULT '[]',
+		result     JSONB,
+		error      TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS scan_jobs_status_idx ON scan_jobs (status)`,
+	`CREATE TABLE IF NOT EXISTS scan_batches (
+		id         TEXT PRIMARY KEY,
+		job_ids    JSONB NOT NULL DEFAULT '[]',
+		created_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS scan_history (
+		id          BIGSERIAL PRIMARY KEY,
+		url         TEXT NOT NULL,
+		scanned_at  TIMESTAMPTZ NOT NULL,
+		source      TEXT NOT NULL,
+		trust_score INTEGER NOT NULL,
+		risk_level  TEXT NOT NULL,
+		result      JSONB NOT NULL,
+		inputs      JSONB
+	)`,
+	`CREATE INDEX IF NOT EXISTS scan_history_url_idx ON scan_history (url, scanned_at DESC)`,
+	`CREATE TABLE IF NOT EXISTS whois_cache (
+		domain     TEXT PRIMARY KEY,
+		record     JSONB NOT NULL,
+		fetched_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS enamad_cache (
+		domain     TEXT PRIMARY KEY,
+		data       JSONB NOT NULL,
+		fetched_at TIMESTAMPTZ NOT NULL
+	)`,
+}
+
+// EnsureSchema creates the tables of the AI service if they do not exist yet
+func (db *PostgreSQL) EnsureSchema() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, statement := range schema {
+		if _, err := db.DB.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("error creating schema: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package Databases
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+)
+
+// GetWhois returns the cached registration data of domain, ErrNotFound when there is none
+func (db *PostgreSQL) GetWhois(ctx context.Context, domain string) (*models.WhoisRecord, error) {
+	query := `SELECT record FROM whois_cache WHERE domain = $1`
+
+	var raw []byte
+	err := db.DB.QueryRowContext(ctx, query, domain).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying whois cache: %v", err)
+	}
+
+	var record models.WhoisRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, fmt.Errorf("error decoding cached whois of %s: %v", domain, err)
+	}
+	return &record, nil
+}
+
+// SaveWhois stores the registration data of a domain, replacing the previous one
+func (db *PostgreSQL) SaveWhois(ctx context.Context, record *models.WhoisRecord) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error marshaling whois record: %v", err)
+	}
+
+	query := `INSERT INTO whois_cache (domain, record, fetched_at) VALUES ($1, $2, $3)
+			  ON CONFLICT (domain) DO UPDATE SET record = EXCLUDED.record, fetched_at = EXCLUDED.fetched_at`
+
+	if _, err := db.DB.ExecContext(ctx, query, record.Domain, raw, record.FetchedAt); err != nil {
+		return fmt.Errorf("error saving whois record: %v", err)
+	}
+	return nil
+}
@@ -1,54 +1,150 @@
 package handlers
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Databases"
 	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
 )
 
-func Enamad_GetData(domain string) (*models.Enamad_Data, error) {
-	var enamad_data models.Enamad_Data
-	enamad_url := "https://enamad.ir/Home/GetData"
-	reqBody := []byte(fmt.Sprintf("domain=%s", domain))
+// maxRegistrySize bounds the answers of enamad.ir and samandehi.ir
+const maxRegistrySize = 1 << 20
 
-	req, err := http.NewRequest(http.MethodPost, enamad_url, bytes.NewBuffer(reqBody))
+// ErrInvalidDomain is returned for sites without a domain name
+var ErrInvalidDomain = errors.New("invalid domain")
+
+// EnamadCache stores Enamad certificates between scans, Databases.PostgreSQL implements it
+type EnamadCache interface {
+	GetEnamad(ctx context.Context, domain string) (*models.Enamad_Data, error)
+	SaveEnamad(ctx context.Context, domain string, data *models.Enamad_Data) error
+}
+
+// EnamadClient looks the Enamad certificate of domains up on enamad.ir, retrying failed
+// requests and caching the answers
+type EnamadClient struct {
+	HTTP *http.Client
+	// BaseURL is the enamad.ir site, tests point it to a stub
+	BaseURL    string
+	Retries    int
+	RetryDelay time.Duration
+	// Cache is optional, certificates younger than CacheTTL are served from it
+	Cache    EnamadCache
+	CacheTTL time.Duration
+}
+
+// NewEnamadClient returns a client configured by cfg, cache may be nil
+func NewEnamadClient(cfg models.EnamadConfig, cache EnamadCache) *EnamadClient {
+	return &EnamadClient{
+		HTTP:       &http.Client{Timeout: cfg.Timeout},
+		BaseURL:    cfg.BaseURL,
+		Retries:    cfg.Retries,
+		RetryDelay: cfg.RetryDelay,
+		Cache:      cache,
+		CacheTTL:   cfg.CacheTTL,
+	}
+}
+
+// enamadDomain is the host of site without www, the name certificates are issued for
+func enamadDomain(site string) (string, error) {
+	identity, err := models.NormalizeURL(site)
 	if err != nil {
-		log.Println(err)
-		return &models.Enamad_Data{}, err
+		return "", fmt.Errorf("%w: %v", ErrInvalidDomain, err)
 	}
+	return identity.SiteHost, nil
+}
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// Lookup returns the Enamad certificate of the domain of site, from the cache while it is
+// fresh. A domain without a certificate is no error, its data has no ID.
+func (c *EnamadClient) Lookup(ctx context.Context, site string) (*models.Enamad_Data, error) {
+	domain, err := enamadDomain(site)
 	if err != nil {
-		log.Println(err)
-		return &models.Enamad_Data{}, err
+		return nil, err
 	}
 
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	if c.Cache != nil && c.CacheTTL > 0 {
+		cached, err := c.Cache.GetEnamad(ctx, domain)
+		switch {
+		case err == nil && time.Since(cached.FetchedAt) < c.CacheTTL:
+			cached.Cached = true
+			cached.Resolve(time.Now())
+			return cached, nil
+		case err != nil && !errors.Is(err, Databases.ErrNotFound):
+			log.Printf("Reading the cached enamad of %s went wrong : %v", domain, err)
+		}
+	}
+
+	var data *models.Enamad_Data
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		data, retry, err = c.getData(ctx, domain)
+		if err == nil || !retry || attempt >= c.Retries {
+			break
+		}
+		log.Printf("Enamad lookup of %s failed, retrying : %v", domain, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.RetryDelay * time.Duration(attempt+1)):
+		}
+	}
 	if err != nil {
-		log.Println(err)
-		return &models.Enamad_Data{}, err
+		return nil, err
 	}
 
-	if err = json.Unmarshal(body, &enamad_data); err != nil {
-		log.Println(err)
-		return &models.Enamad_Data{}, err
+	data.FetchedAt = time.Now()
+	data.Resolve(data.FetchedAt)
+
+	if c.Cache != nil && c.CacheTTL > 0 {
+		if err := c.Cache.SaveEnamad(ctx, domain, data); err != nil {
+			log.Printf("Caching the enamad of %s went wrong : %v", domain, err)
+		}
+	}
+	return data, nil
+}
+
+// getData posts domain to GetData, retry tells whether the failure may pass on another try
+func (c *EnamadClient) getData(ctx context.Context, domain string) (*models.Enamad_Data, bool, error) {
+	form := url.Values{"domain": {domain}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.BaseURL, "/")+"/Home/GetData", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		retry := resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, fmt.Errorf("enamad answered %s", resp.Status)
 	}
 
-	fmt.Println("this is the enamad data:")
-	fmt.Println(enamad_data)
-	fmt.Println("the end")
-	return &enamad_data, nil
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRegistrySize))
+	if err != nil {
+		return nil, true, err
+	}
+	var data models.Enamad_Data
+	// enamad.ir answers null for domains without a certificate
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, false, fmt.Errorf("decoding the enamad answer: %v", err)
+	}
+	return &data, false, nil
 }
 
-func EnamadHandler(w http.ResponseWriter, r *http.Request) {
+// EnamadHandler returns the Enamad certificate of the posted domain
+func (c *EnamadClient) EnamadHandler(w http.ResponseWriter, r *http.Request) {
 	// Set content type to JSON
 	w.Header().Set("Content-Type", "application/json")
 
@@ -66,7 +162,12 @@ func EnamadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	enamad_data, err := Enamad_GetData(requestBody.Domain)
+	enamad_data, err := c.Lookup(r.Context(), requestBody.Domain)
+	if errors.Is(err, ErrInvalidDomain) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		log.Println(err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -1,46 +1,136 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Databases"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
 )
 
+// stubEnamad serves GetData like enamad.ir, digikala.com is certified and busy.ir fails
+// its first request
+func stubEnamad(t *testing.T) (*EnamadClient, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if r.Method != http.MethodPost || r.URL.Path != "/Home/GetData" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.FormValue("domain") {
+		case "digikala.com":
+			fmt.Fprint(w, `{"id": 1234, "domain": "digikala.com", "nameper": "دیجی کالا", "approvedate": "1395/03/10", "expdate": "۱۴۲۰/۰۳/۱۰", "logolevel": 2, "Code": "abc"}`)
+		case "busy.ir":
+			if n == 1 {
+				http.Error(w, "busy", http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, `{"id": 1, "domain": "busy.ir", "expdate": "1400/01/01", "logolevel": 1}`)
+		case "xn--mgbanl1i60athb0929c.ir":
+			fmt.Fprint(w, `{"id": 7}`)
+		default:
+			fmt.Fprint(w, `null`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return &EnamadClient{HTTP: server.Client(), BaseURL: server.URL, Retries: 2, RetryDelay: time.Millisecond}, &requests
+}
+
 func TestEnamad(t *testing.T) {
-	got, err := Enamad_GetData("digikala.com")
+	client, _ := stubEnamad(t)
 
-	// Check if there was an error
+	got, err := client.Lookup(context.Background(), "https://www.digikala.com/product/1")
 	if err != nil {
-		t.Errorf("Enamad_GetData returned an error: %v", err)
-		return
+		t.Fatalf("Lookup returned an error: %v", err)
+	}
+	if got.Domain != "digikala.com" || got.ID != 1234 || got.NamePer == "" || !got.Registered() {
+		t.Errorf("got %+v", got)
+	}
+	// 1420/03/10 is 30 May 2041
+	if got.ExpiresAt == nil || !got.ExpiresAt.Equal(time.Date(2041, 5, 30, 0, 0, 0, 0, time.UTC)) || got.ApprovedAt == nil || got.IsExpired {
+		t.Errorf("got dates %v %v", got.ApprovedAt, got.ExpiresAt)
+	}
+	if got.StarLevel == "" {
+		t.Errorf("got no star level meaning for level %d", got.LogoLevel)
 	}
 
-	// Check if got is nil
-	if got == nil {
-		t.Errorf("Enamad_GetData returned nil data")
-		return
+	got, err = client.Lookup(context.Background(), "unknown.ir")
+	if err != nil || got.Registered() {
+		t.Errorf("got %+v, %v for a domain without a certificate", got, err)
 	}
 
-	if got.Domain == "" {
-		t.Errorf("Expected Domain to be non-empty, got empty string")
+	// unicode domains are looked up in their punycode form
+	if got, err := client.Lookup(context.Background(), "https://www.دیجی‌کالا.ir/"); err != nil || got.ID != 7 {
+		t.Errorf("got %+v, %v for a unicode domain", got, err)
 	}
 
-	if got.Domain != "digikala.com" {
-		t.Errorf("Expected Domain to be 'digikala.com', got %s", got.Domain)
+	// a domain that would need escaping in the form is no domain name
+	for _, site := range []string{"localhost", "a&b=c.ir"} {
+		if _, err := client.Lookup(context.Background(), site); !errors.Is(err, ErrInvalidDomain) {
+			t.Errorf("got %v for %q, wanted ErrInvalidDomain", err, site)
+		}
 	}
+}
 
-	if got.ID == 0 {
-		t.Errorf("Expected ID to be non-zero, got %d", got.ID)
+func TestEnamadRetries(t *testing.T) {
+	client, requests := stubEnamad(t)
+
+	got, err := client.Lookup(context.Background(), "busy.ir")
+	if err != nil || got.ID != 1 || atomic.LoadInt32(requests) != 2 {
+		t.Fatalf("got %+v, %v after %d requests", got, err, atomic.LoadInt32(requests))
+	}
+	if !got.IsExpired || got.DaysToExpiry >= 0 {
+		t.Errorf("got %+v for a certificate expired in 2021", got)
 	}
 
-	if got.NamePer == "" {
-		t.Errorf("Expected NamePer to be non-empty, got empty string")
+	client.BaseURL = "http://127.0.0.1:1"
+	client.Retries = 1
+	if _, err := client.Lookup(context.Background(), "busy.ir"); err == nil {
+		t.Errorf("got no error when enamad is unreachable")
 	}
+}
+
+// memoryEnamadCache is an EnamadCache kept in memory
+type memoryEnamadCache map[string]*models.Enamad_Data
+
+func (c memoryEnamadCache) GetEnamad(ctx context.Context, domain string) (*models.Enamad_Data, error) {
+	data, ok := c[domain]
+	if !ok {
+		return nil, Databases.ErrNotFound
+	}
+	copied := *data
+	return &copied, nil
+}
 
-	// Optionally test other important fields
-	if got.ApproveDate == "" {
-		t.Errorf("Expected ApproveDate to be non-empty, got empty string")
+func (c memoryEnamadCache) SaveEnamad(ctx context.Context, domain string, data *models.Enamad_Data) error {
+	c[domain] = data
+	return nil
+}
+
+func TestEnamadCache(t *testing.T) {
+	client, requests := stubEnamad(t)
+	cache := memoryEnamadCache{}
+	client.Cache, client.CacheTTL = cache, time.Hour
+
+	if _, err := client.Lookup(context.Background(), "digikala.com"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.Lookup(context.Background(), "www.digikala.com")
+	if err != nil || !got.Cached || got.ExpiresAt == nil || atomic.LoadInt32(requests) != 1 {
+		t.Fatalf("got %+v, %v after %d requests, wanted the cached certificate", got, err, atomic.LoadInt32(requests))
 	}
 
-	if got.ExpDate == "" {
-		t.Errorf("Expected ExpDate to be non-empty, got empty string")
+	// a stale certificate is fetched again
+	cache["digikala.com"].FetchedAt = time.Now().Add(-2 * time.Hour)
+	if got, err = client.Lookup(context.Background(), "digikala.com"); err != nil || got.Cached {
+		t.Errorf("got %+v, %v, wanted a fresh certificate", got, err)
 	}
 }
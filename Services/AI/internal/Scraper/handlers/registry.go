@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+)
+
+// maxSamandehiBadges bounds how many Samandehi badges of a site are looked up
+const maxSamandehiBadges = 3
+
+// RegistryChecker looks the scanned site up in one Iranian business registry. Failures are
+// reported in the result, with the RegistryUnavailable status.
+type RegistryChecker interface {
+	Check(ctx context.Context, query models.RegistryQuery) models.RegistryResult
+}
+
+// CheckRegistries asks every checker about the site at once and combines their answers
+func CheckRegistries(ctx context.Context, checkers []RegistryChecker, query models.RegistryQuery) *models.ComplianceReport {
+	results := make([]models.RegistryResult, len(checkers))
+	var wg sync.WaitGroup
+	for i, checker := range checkers {
+		wg.Add(1)
+		go func(i int, checker RegistryChecker) {
+			defer wg.Done()
+			results[i] = checker.Check(ctx, query)
+		}(i, checker)
+	}
+	wg.Wait()
+	return models.NewComplianceReport(results)
+}
+
+// Check looks the Enamad certificate of the site up
+func (c *EnamadClient) Check(ctx context.Context, query models.RegistryQuery) models.RegistryResult {
+	data, err := c.Lookup(ctx, query.Site)
+	return models.EnamadResult(data, err)
+}
+
+// SamandehiClient reads the Samandehi registrations of the badges shown on the site.
+// Samandehi has no lookup by domain, a site without a badge is not registered.
+type SamandehiClient struct {
+	HTTP *http.Client
+	// BaseURL serves Verify.aspx, tests point it to a stub
+	BaseURL string
+}
+
+// NewSamandehiClient returns a client configured by cfg
+func NewSamandehiClient(cfg models.SamandehiConfig) *SamandehiClient {
+	return &SamandehiClient{HTTP: &http.Client{Timeout: cfg.Timeout}, BaseURL: cfg.BaseURL}
+}
+
+// Check reads the verify page of every Samandehi badge of the site
+func (c *SamandehiClient) Check(ctx context.Context, query models.RegistryQuery) models.RegistryResult {
+	records := []models.Samandehi_Data{}
+	errs := []string{}
+	looked := 0
+	for _, seal := range query.Seals {
+		if seal.Kind != models.SealSamandehi || seal.ID == "" {
+			continue
+		}
+		if looked == maxSamandehiBadges {
+			break
+		}
+		looked++
+
+		record, err := c.verify(ctx, seal)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		records = append(records, *record)
+	}
+	return models.SamandehiResult(query.Site, records, errs, time.Now())
+}
+
+func (c *SamandehiClient) verify(ctx context.Context, seal models.TrustSeal) (*models.Samandehi_Data, error) {
+	verifyURL := fmt.Sprintf("%s/Verify.aspx?%s", strings.TrimSuffix(c.BaseURL, "/"), url.Values{"id": {seal.ID}, "p": {seal.Code}}.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, verifyURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if seal.Page != "" {
+		req.Header.Set("Referer", seal.Page)
+	}
+
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("samandehi answered %s for badge %s", res.Status, seal.ID)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxRegistrySize))
+	if err != nil {
+		return nil, err
+	}
+	return models.ParseSamandehiPage(seal.ID, body)
+}
+
+// BusinessIDChecker checks the national business ids written on the site. No public
+// registry answers for them, so only their control digit is verified.
+type BusinessIDChecker struct{}
+
+// Check rates the national ids of the query
+func (BusinessIDChecker) Check(ctx context.Context, query models.RegistryQuery) models.RegistryResult {
+	return models.BusinessIDResult(query.BusinessIDs)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+)
+
+func TestCheckRegistries(t *testing.T) {
+	enamad, _ := stubEnamad(t)
+
+	samandehi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/Verify.aspx" || r.URL.Query().Get("p") != "xyz" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<html><body><table><tr><td>نام سایت</td><td>دیجی کالا</td></tr><tr><td>آدرس</td><td>digikala.com</td></tr></table></body></html>`)
+	}))
+	defer samandehi.Close()
+
+	checkers := []RegistryChecker{enamad, &SamandehiClient{HTTP: samandehi.Client(), BaseURL: samandehi.URL}, BusinessIDChecker{}}
+	query := models.RegistryQuery{
+		Site: "https://www.digikala.com",
+		// the badge links to a lookalike host, its id is still looked up at samandehi
+		Seals:       []models.TrustSeal{{Kind: models.SealSamandehi, Link: "https://samandehi-verify.top/?id=678", ID: "678", Code: "xyz"}},
+		BusinessIDs: []string{"10320860530"},
+	}
+
+	report := CheckRegistries(context.Background(), checkers, query)
+	want := map[string]string{
+		models.RegistryEnamad:     models.RegistryRegistered,
+		models.RegistrySamandehi:  models.RegistryRegistered,
+		models.RegistryBusinessID: models.RegistryDeclared,
+	}
+	for registry, status := range want {
+		if r := report.Result(registry); r == nil || r.Status != status {
+			t.Errorf("got %+v for %s, wanted %s", r, registry, status)
+		}
+	}
+	if !report.Compliant || len(report.Issues) != 0 {
+		t.Errorf("got %+v", report)
+	}
+
+	// a wrong badge code and an unreachable enamad are reported, not fatal
+	enamad.BaseURL, enamad.Retries = "http://127.0.0.1:1", 0
+	query.Seals[0].Code = "wrong"
+	report = CheckRegistries(context.Background(), checkers, query)
+	if r := report.Result(models.RegistryEnamad); r.Status != models.RegistryUnavailable || r.Error == "" {
+		t.Errorf("got %+v when enamad is unreachable", r)
+	}
+	if r := report.Result(models.RegistrySamandehi); r.Status != models.RegistryUnavailable {
+		t.Errorf("got %+v for a badge samandehi does not know", r)
+	}
+	if report.Compliant {
+		t.Errorf("got a compliant report without any confirmed registration")
+	}
+}
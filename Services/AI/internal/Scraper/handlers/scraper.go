@@ -3,7 +3,6 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
 	"net/url"
@@ -12,118 +11,73 @@ import (
 	"time"
 
 	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/safedial"
 	"github.com/gocolly/colly/v2"
-	"golang.org/x/net/html"
 )
 
-func RemoveScheme(inputURL string) (string, error) {
-	// Add scheme if missing to make url.Parse work correctly
-	if !strings.HasPrefix(inputURL, "http://") && !strings.HasPrefix(inputURL, "https://") {
-		inputURL = "https://" + inputURL
-	}
-
-	parsed, err := url.Parse(inputURL)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse URL: %v", err)
-	}
-
-	// Rebuild URL without scheme
-	result := parsed.Host
-	if parsed.Path != "" {
-		result += parsed.Path
-	}
-	if parsed.RawQuery != "" {
-		result += "?" + parsed.RawQuery
-	}
-	if parsed.Fragment != "" {
-		result += "#" + parsed.Fragment
-	}
-
-	return result, nil
-}
-
-func printAnalysisResults(fi *models.FraudIndicators) {
-	fmt.Println("\nFinal Fraud Analysis Results:")
-	fmt.Println("============================")
-
-	printSection := func(title string, items interface{}) {
-		switch v := items.(type) {
-		case []string:
-			if len(v) > 0 {
-				fmt.Printf("\n%s Found (%d):\n", title, len(v))
-				for _, item := range v {
-					fmt.Printf("- %s\n", item)
-				}
-			}
-		case bool:
-			if !v {
-				fmt.Printf("\nWarning: %s\n", title)
-			}
-		case int:
-			if v < 365 {
-				fmt.Printf("\nDomain Age Warning: Only %d days old\n", v)
-			}
-		}
-	}
-
-	printSection("Suspicious Keywords", fi.Findings["FoundKeywords"])
-	printSection("Hidden Elements", fi.Findings["HiddenElements"])
-	printSection("No Contact Info", fi.Findings["NoContactInfo"])
-	printSection("Domain Age", fi.DomainAge)
-
-	if fi.UnsecureConnection {
-		fmt.Println("\nSecurity Warning: Non-HTTPS Connection Detected")
-	}
-
-	fmt.Println("\nAnalysis complete!")
-}
-
-func Do_scrape(domain string) map[string]interface{} {
+// Do_scrape crawls the site within the limits of cfg and returns the report of every page, the
+// crawl stops early when ctx is cancelled. Every connection goes through dialer, nil uses a dialer
+// blocking private addresses. onPage, when not nil, is called with the findings of every
+// crawled page as soon as it is analyzed.
+func Do_scrape(ctx context.Context, domain string, cfg models.CrawlerConfig, dialer *safedial.Dialer, onPage func(models.PageResult)) *models.ScrapeReport {
 	var fi = models.NewDefaultFraudIndicators()
+	report := models.NewScrapeReport(domain)
+	// mu guards the report, finished stops late callbacks from changing it once returned
 	var mu sync.Mutex
+	finished := false
 	visited := make(map[string]bool)
 	visitedMu := &sync.Mutex{}
 
 	// Set timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, cfg.MaxDuration)
 	defer cancel()
 
-	// Ensure domain has scheme
-	if !strings.HasPrefix(domain, "http://") && !strings.HasPrefix(domain, "https://") {
-		domain = "https://" + domain
+	site, err := models.NormalizeURL(domain)
+	if err != nil {
+		log.Printf("Error normalizing %s: %v", domain, err)
+		report.AddError(models.PageError{URL: domain, Error: err.Error()})
+		report.Finish()
+		return report
 	}
+	domain = site.URL
 
-	domain1, err := RemoveScheme(domain)
-	if err != nil {
-		log.Printf("Error removing scheme: %v", err)
-		return fi.Findings
+	// sites answer on their name with and without www, one usually redirects to the other
+	hosts := []string{site.Host, "www." + site.Host}
+	if bare, ok := strings.CutPrefix(site.Host, "www."); ok {
+		hosts = []string{site.Host, bare}
 	}
 
 	// Create collector with proper configuration
 	c := colly.NewCollector(
 		colly.Async(true),
-		colly.AllowedDomains(domain1),
-		colly.CacheDir("./scrape_cache"),
+		colly.AllowedDomains(hosts...),
+		colly.CacheDir(cfg.CacheDir),
 		colly.IgnoreRobotsTxt(),
 	)
 
 	// Configure limits
 	c.Limit(&colly.LimitRule{
 		DomainGlob:  "*",
-		Parallelism: 4,
-		RandomDelay: 1 * time.Second,
+		Parallelism: cfg.Parallelism,
+		RandomDelay: cfg.RandomDelay,
 	})
 
-	c.SetRequestTimeout(30 * time.Second)
+	c.SetRequestTimeout(cfg.RequestTimeout)
+
+	// redirects use the same transport, so they are checked too
+	if dialer == nil {
+		dialer = safedial.New()
+	}
+	c.WithTransport(dialer.Transport())
 
 	// Channel to track active requests (buffered to prevent deadlocks)
-	activeRequests := make(chan struct{}, 100)
+	activeRequests := make(chan struct{}, cfg.MaxActiveRequests)
 	defer close(activeRequests)
 
 	// Timeout monitor
 	go func() {
 		<-ctx.Done()
-		log.Println("Timeout reached, aborting pending requests")
+		log.Printf("Crawl stopped (%v), aborting pending requests", ctx.Err())
 
 		// Abort all pending requests
 		c.OnRequest(func(r *colly.Request) {
@@ -186,20 +140,17 @@ func Do_scrape(domain string) map[string]interface{} {
 		case <-ctx.Done():
 			return
 		default:
+			page := fi.AnalyzePage(r)
+
 			mu.Lock()
 			defer mu.Unlock()
-
-			if !strings.HasPrefix(r.Request.URL.String(), "https://") {
-				fi.Findings["SecureConnection"] = false
-			}
-
-			doc, err := html.Parse(strings.NewReader(string(r.Body)))
-			if err != nil {
+			if finished {
 				return
 			}
-
-			fi.AnalyzeContent(doc, r)
-			fi.AnalyzeResponse(r)
+			report.AddPage(page)
+			if onPage != nil {
+				onPage(page)
+			}
 		}
 	})
 
@@ -211,13 +162,25 @@ func Do_scrape(domain string) map[string]interface{} {
 			}
 		}()
 		log.Printf("Request URL: %s failed with response: %v\nError: %v", r.Request.URL, r, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if finished {
+			return
+		}
+		report.AddError(models.PageError{URL: r.Request.URL.String(), StatusCode: r.StatusCode, Error: err.Error()})
 	})
 
 	// Start scraping
 	err = c.Visit(domain)
 	if err != nil {
 		log.Printf("Initial visit error: %v", err)
-		return fi.Findings
+		mu.Lock()
+		defer mu.Unlock()
+		finished = true
+		report.AddError(models.PageError{URL: domain, Error: err.Error()})
+		report.Finish()
+		return report
 	}
 
 	// Wait for completion with timeout
@@ -242,18 +205,26 @@ func Do_scrape(domain string) map[string]interface{} {
 		log.Printf("Waiting for %d active requests to complete", len(activeRequests))
 	}
 
-	// Marshal and print results
-	jsonFinding, err := json.MarshalIndent(fi.Findings, "", "  ")
-	if err != nil {
-		log.Printf("Error marshaling findings: %v", err)
-	} else {
-		log.Println(string(jsonFinding))
-	}
+	mu.Lock()
+	defer mu.Unlock()
+	finished = true
+	report.Finish()
+	log.Printf("Crawled %d pages of %s, %d failed", len(report.Pages), domain, len(report.Errors))
 
-	return fi.Findings
+	return report
 }
 
-func Scrape(w http.ResponseWriter, r *http.Request) {
+// ScraperHandler serves the crawler over http
+type ScraperHandler struct {
+	Crawler models.CrawlerConfig
+	Dialer  *safedial.Dialer
+}
+
+func NewScraperHandler(crawler models.CrawlerConfig, dialer *safedial.Dialer) *ScraperHandler {
+	return &ScraperHandler{Crawler: crawler, Dialer: dialer}
+}
+
+func (h *ScraperHandler) Scrape(w http.ResponseWriter, r *http.Request) {
 
 	var requestBody models.Req_body
 	err := json.NewDecoder(r.Body).Decode(&requestBody)
@@ -268,7 +239,12 @@ func Scrape(w http.ResponseWriter, r *http.Request) {
 
 	//html := Do_scrape(requestBody.Domain)
 	//fmt.Fprintf(w, "%s", html)
-	findings := Do_scrape(requestBody.Domain)
+	report := Do_scrape(r.Context(), requestBody.Domain, h.Crawler, h.Dialer, nil)
 
-	fmt.Fprintf(w, "%s", findings)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
 }
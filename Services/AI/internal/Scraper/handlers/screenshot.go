@@ -6,56 +6,54 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"net/url"
-	"strings"
 	"time"
 
 	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Databases"
 	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/safedial"
 	"github.com/chromedp/chromedp"
 )
 
 type ScreenshotHandler struct {
 	MongoDB *Databases.MongoDB
+	Config  models.ScreenshotConfig
+	Dialer  *safedial.Dialer
 }
 
-func NewScreenShotHandler(mongoDB *Databases.MongoDB) *ScreenshotHandler {
-	return &ScreenshotHandler{MongoDB: mongoDB}
+func NewScreenShotHandler(mongoDB *Databases.MongoDB, cfg models.ScreenshotConfig, dialer *safedial.Dialer) *ScreenshotHandler {
+	return &ScreenshotHandler{MongoDB: mongoDB, Config: cfg, Dialer: dialer}
 }
 
-func (h *ScreenshotHandler) validateURL(rawURL string) (string, error) {
-	// Add protocol if missing
-	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
-		rawURL = "https://" + rawURL
-	}
-
-	parsedURL, err := url.Parse(rawURL)
+// validateURL rejects urls whose host resolves to an internal address. It only gives an early
+// error, the browser itself is confined by the proxy of TakeScreenShot.
+func (h *ScreenshotHandler) validateURL(ctx context.Context, rawURL string) (string, error) {
+	site, err := models.NormalizeURL(rawURL)
 	if err != nil {
-		return "", fmt.Errorf("invalid URL format: %v", err)
-	}
-
-	// Basic security checks
-	if parsedURL.Host == "" {
-		return "", fmt.Errorf("invalid URL: missing host")
+		return "", err
 	}
 
-	// Prevent localhost/internal network access (basic SSRF protection)
-	if strings.Contains(parsedURL.Host, "localhost") ||
-		strings.Contains(parsedURL.Host, "127.0.0.1") ||
-		strings.Contains(parsedURL.Host, "192.168.") ||
-		strings.Contains(parsedURL.Host, "10.") {
-		return "", fmt.Errorf("access to internal networks not allowed")
+	if err := h.Dialer.CheckURL(ctx, site.URL); err != nil {
+		return "", err
 	}
 
-	return rawURL, nil
+	return site.URL, nil
 }
 
-func (h *ScreenshotHandler) TakeScreenShot(site string) ([]byte, error) {
-	validatedURL, err := h.validateURL(site)
+// TakeScreenShot renders site in a headless browser, closing it when ctx is cancelled
+func (h *ScreenshotHandler) TakeScreenShot(ctx context.Context, site string) ([]byte, error) {
+	validatedURL, err := h.validateURL(ctx, site)
 	if err != nil {
 		return nil, err
 	}
 
+	// the browser connects through a local proxy using the safe dialer, so redirects,
+	// subresources and DNS rebinding cannot reach internal addresses
+	proxy, err := h.Dialer.StartProxy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the screenshot proxy: %v", err)
+	}
+	defer proxy.Close()
+
 	// Create context with Chrome options
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
 		chromedp.Flag("headless", true),
@@ -66,18 +64,23 @@ func (h *ScreenshotHandler) TakeScreenShot(site string) ([]byte, error) {
 		chromedp.Flag("disable-background-timer-throttling", true),
 		chromedp.Flag("disable-backgrounding-occluded-windows", true),
 		chromedp.Flag("disable-renderer-backgrounding", true),
-		chromedp.WindowSize(1920, 1080),
+		chromedp.ProxyServer(proxy.URL()),
+		// loopback bypasses the proxy by default
+		chromedp.Flag("proxy-bypass-list", "<-loopback>"),
+		chromedp.Flag("disable-quic", true),
+		chromedp.Flag("force-webrtc-ip-handling-policy", "disable_non_proxied_udp"),
+		chromedp.WindowSize(h.Config.Width, h.Config.Height),
 		chromedp.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"),
 	)
 
-	allocCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	allocCtx, cancel := chromedp.NewExecAllocator(ctx, opts...)
 	defer cancel()
 
-	ctx, cancel := chromedp.NewContext(allocCtx)
+	ctx, cancel = chromedp.NewContext(allocCtx)
 	defer cancel()
 
 	// Set timeout
-	ctx, cancel = context.WithTimeout(ctx, 30*time.Second)
+	ctx, cancel = context.WithTimeout(ctx, h.Config.Timeout)
 	defer cancel()
 
 	var buf []byte
@@ -144,9 +147,16 @@ func (h *ScreenshotHandler) ScreenShotHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	log.Printf("Taking screenshot for domain: %s", requestBody.Domain)
+	// screenshots are stored under the key of the site, like its scans
+	site, err := models.NormalizeURL(requestBody.Domain)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Taking screenshot for domain: %s", site.Key)
 
-	buf, err := h.TakeScreenShot(requestBody.Domain)
+	buf, err := h.TakeScreenShot(r.Context(), site.URL)
 	if err != nil {
 		log.Printf("Failed to take screenshot: %v", err)
 		http.Error(w, fmt.Sprintf("Failed to take screenshot: %v", err), http.StatusInternalServerError)
@@ -161,7 +171,7 @@ func (h *ScreenshotHandler) ScreenShotHandler(w http.ResponseWriter, r *http.Req
 
 	log.Printf("Screenshot taken, saving to database. Size: %d bytes", len(buf))
 
-	oid, err := h.MongoDB.SaveScreenshot("screenshots", requestBody.Domain, buf)
+	oid, err := h.MongoDB.SaveScreenshot("screenshots", site.Key, buf)
 	if err != nil {
 		log.Printf("Failed to save screenshot: %v", err)
 		http.Error(w, "Failed to save screenshot", http.StatusInternalServerError)
@@ -175,7 +185,7 @@ func (h *ScreenshotHandler) ScreenShotHandler(w http.ResponseWriter, r *http.Req
 		"status":  "success",
 		"message": "Screenshot saved successfully",
 		"id":      oid.Hex(),
-		"domain":  requestBody.Domain,
+		"domain":  site.Key,
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
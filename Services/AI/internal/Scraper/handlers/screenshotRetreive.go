@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -80,6 +81,12 @@ func (h *ScreenshotHandler) GetScreenshotByDomain(w http.ResponseWriter, r *http
 		http.Error(w, "Domain parameter is required", http.StatusBadRequest)
 		return
 	}
+	site, err := models.NormalizeURL(domain)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	domain = site.Key
 
 	log.Printf("Retrieving latest screenshot for domain: %s", domain)
 
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"context"
 	"log"
 	"testing"
 
 	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Databases"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/safedial"
 )
 
 func TestScreenShot(t *testing.T) {
@@ -12,6 +15,6 @@ func TestScreenShot(t *testing.T) {
 	if err != nil {
 		log.Fatalf("Failed to connect to MongoDB: %v", err)
 	}
-	sh := NewScreenShotHandler(mongoDB)
-	sh.TakeScreenShot("digikala.com")
+	sh := NewScreenShotHandler(mongoDB, models.DefaultScreenshotConfig(), safedial.New())
+	sh.TakeScreenShot(context.Background(), "digikala.com")
 }
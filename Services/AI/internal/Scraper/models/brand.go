@@ -0,0 +1,217 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Impersonation techniques
+const (
+	ImpersonationTyposquat       = "typosquat"
+	ImpersonationHomoglyph       = "homoglyph"
+	ImpersonationBrandInDomain   = "brand_in_domain"
+	ImpersonationContentMismatch = "content_mismatch"
+)
+
+// impersonationSeverity is how strongly each technique points to a fake site, a page
+// only mentioning a brand in its title can be a review or a reseller
+var impersonationSeverity = map[string]float64{
+	ImpersonationHomoglyph:       1,
+	ImpersonationTyposquat:       0.9,
+	ImpersonationBrandInDomain:   0.7,
+	ImpersonationContentMismatch: 0.4,
+}
+
+// Brand is a protected brand and the only domains it is served from
+type Brand struct {
+	Name    string   `json:"name" yaml:"name"`
+	Domains []string `json:"domains" yaml:"domains"`
+	// Keywords are the names the brand is written as in titles and logos, latin and persian
+	Keywords []string `json:"keywords" yaml:"keywords"`
+}
+
+// DefaultBrands are the Iranian banks, gateways and shops most often imitated
+func DefaultBrands() []Brand {
+	return []Brand{
+		{Name: "Digikala", Domains: []string{"digikala.com"}, Keywords: []string{"digikala", "دیجی کالا"}},
+		{Name: "Shaparak", Domains: []string{"shaparak.ir"}, Keywords: []string{"shaparak", "شاپرک"}},
+		{Name: "Bank Melli", Domains: []string{"bmi.ir"}, Keywords: []string{"bank melli", "بانک ملی"}},
+		{Name: "Bank Mellat", Domains: []string{"bankmellat.ir"}, Keywords: []string{"bank mellat", "بانک ملت"}},
+		{Name: "Bank Saderat", Domains: []string{"bsi.ir"}, Keywords: []string{"bank saderat", "بانک صادرات"}},
+		{Name: "Bank Tejarat", Domains: []string{"tejaratbank.ir"}, Keywords: []string{"tejarat bank", "بانک تجارت"}},
+		{Name: "Bank Sepah", Domains: []string{"banksepah.ir"}, Keywords: []string{"bank sepah", "بانک سپه"}},
+		{Name: "Parsian Bank", Domains: []string{"parsian-bank.ir"}, Keywords: []string{"parsian bank", "بانک پارسیان"}},
+		{Name: "Bank Pasargad", Domains: []string{"bpi.ir"}, Keywords: []string{"bank pasargad", "بانک پاسارگاد"}},
+		{Name: "Saman Bank", Domains: []string{"sb24.ir"}, Keywords: []string{"saman bank", "بانک سامان"}},
+		{Name: "Snapp", Domains: []string{"snapp.ir", "snappfood.ir"}, Keywords: []string{"snapp", "اسنپ"}},
+		{Name: "Divar", Domains: []string{"divar.ir"}, Keywords: []string{"divar"}},
+		{Name: "Adliran", Domains: []string{"adliran.ir"}, Keywords: []string{"adliran", "عدل ایران"}},
+	}
+}
+
+// ImpersonationFinding is a sign the scanned domain poses as a protected brand
+type ImpersonationFinding struct {
+	Brand     string `json:"brand"`
+	Technique string `json:"technique"`
+	// Domain is the real domain of the brand
+	Domain   string `json:"domain"`
+	Evidence string `json:"evidence"`
+}
+
+// Severity is how strongly the finding points to a fake site, from 0 to 1
+func (f ImpersonationFinding) Severity() float64 {
+	return impersonationSeverity[f.Technique]
+}
+
+// DetectImpersonation compares the domain of site against the brands, by edit distance,
+// confusable characters and brand names in subdomains, and looks for the brands in the
+// page titles and logo texts of a site that is not theirs
+func DetectImpersonation(site string, brands []Brand, titles, logoTexts []string) []ImpersonationFinding {
+	findings := []ImpersonationFinding{}
+
+	host := hostOf(site)
+	if host == "" {
+		return findings
+	}
+	unicodeHost, err := idna.ToUnicode(host)
+	if err != nil {
+		unicodeHost = host
+	}
+	subdomains, name := splitHost(host)
+	_, unicodeName := splitHost(unicodeHost)
+
+	content := normalizeBrandText(strings.Join(append(append([]string{}, titles...), logoTexts...), " "))
+
+	for _, brand := range brands {
+		if brand.owns(host) {
+			continue
+		}
+
+		found := make(map[string]bool)
+		add := func(technique, domain, evidence string) {
+			if found[technique] {
+				return
+			}
+			found[technique] = true
+			findings = append(findings, ImpersonationFinding{Brand: brand.Name, Technique: technique, Domain: domain, Evidence: evidence})
+		}
+
+		for _, domain := range brand.Domains {
+			_, token := splitHost(strings.ToLower(domain))
+			if token == "" {
+				continue
+			}
+
+			switch {
+			case unicodeName != name && confusableSkeleton(unicodeName) == confusableSkeleton(token):
+				add(ImpersonationHomoglyph, domain, fmt.Sprintf("%s (%s) uses characters that look like %s", host, unicodeHost, domain))
+			case name != token && confusableSkeleton(name) == confusableSkeleton(token):
+				add(ImpersonationHomoglyph, domain, fmt.Sprintf("%s swaps letters of %s for lookalike characters", host, domain))
+			case name == token:
+				add(ImpersonationTyposquat, domain, fmt.Sprintf("%s uses the name of %s under another suffix", host, domain))
+			case strings.ReplaceAll(name, "-", "") == token || levenshtein(name, token) <= typoDistance(token):
+				add(ImpersonationTyposquat, domain, fmt.Sprintf("%s is a misspelling of %s", host, domain))
+			}
+
+			// short names like bmi would match too many unrelated domains
+			if len(token) < 5 {
+				continue
+			}
+			if name != token && strings.Contains(name, token) {
+				add(ImpersonationBrandInDomain, domain, fmt.Sprintf("%s contains the brand name %s", host, token))
+			}
+			for _, sub := range subdomains {
+				if strings.Contains(sub, token) {
+					add(ImpersonationBrandInDomain, domain, fmt.Sprintf("%s puts the brand name %s in a subdomain", host, token))
+				}
+			}
+		}
+
+		for _, keyword := range brand.Keywords {
+			if keyword = normalizeBrandText(keyword); keyword != "" && strings.Contains(content, keyword) {
+				add(ImpersonationContentMismatch, strings.Join(brand.Domains, ", "),
+					fmt.Sprintf("the page title or logo presents the site as %s but it is not served from %s", brand.Name, strings.Join(brand.Domains, ", ")))
+			}
+		}
+	}
+
+	return findings
+}
+
+// owns reports whether host is one of the brand domains or their subdomains
+func (b Brand) owns(host string) bool {
+	for _, domain := range b.Domains {
+		if underDomain(host, strings.ToLower(domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostOf returns the punycode host of a url or a bare domain without www, like the SiteHost of
+// NormalizeURL but for addresses and single labels too, which links may point to
+func hostOf(site string) string {
+	u, err := ParseSiteURL(site)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
+
+// splitHost returns the subdomain labels of host and the label registered under its public
+// suffix, host may be punycode or unicode
+func splitHost(host string) ([]string, string) {
+	labels := strings.Split(host, ".")
+	ascii, err := idna.ToASCII(host)
+	if err != nil {
+		ascii = host
+	}
+	suffix := strings.Count(icannSuffix(ascii), ".") + 1
+	if len(labels) <= suffix {
+		return nil, host
+	}
+	nameIndex := len(labels) - suffix - 1
+	return labels[:nameIndex], labels[nameIndex]
+}
+
+// typoDistance is the largest edit distance still considered a misspelling of token
+func typoDistance(token string) int {
+	switch {
+	case len(token) < 6:
+		return 0
+	case len(token) <= 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// confusables maps characters and digits to the latin letter they look like
+var confusables = map[rune]string{
+	'а': "a", 'е': "e", 'о': "o", 'р': "p", 'с': "c", 'х': "x", 'у': "y", 'і': "i", 'ј': "j", 'ѕ': "s", 'ԁ': "d", 'ɡ': "g",
+	'ο': "o", 'α': "a", 'ν': "v", 'κ': "k", 'ι': "i", 'ρ': "p",
+	'á': "a", 'à': "a", 'ä': "a", 'é': "e", 'è': "e", 'í': "i", 'ï': "i", 'ó': "o", 'ö': "o", 'ú': "u", 'ü': "u", 'ı': "i",
+	'0': "o", '1': "l", '3': "e", '5': "s", '$': "s",
+}
+
+// confusableSkeleton replaces the lookalike characters of label by the letters they imitate,
+// two labels with the same skeleton read the same
+func confusableSkeleton(label string) string {
+	var b strings.Builder
+	for _, r := range label {
+		if s, ok := confusables[r]; ok {
+			b.WriteString(s)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	// i and l are told apart by neither 1 nor most fonts, both become l
+	return strings.NewReplacer("rn", "m", "vv", "w", "i", "l").Replace(b.String())
+}
+
+// normalizeBrandText lower cases s and drops the zero width non-joiner of persian names
+func normalizeBrandText(s string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), "\u200c", " "))
+}
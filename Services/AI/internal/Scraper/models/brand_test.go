@@ -0,0 +1,64 @@
+package models
+
+import "testing"
+
+func TestDetectImpersonation(t *testing.T) {
+	brands := DefaultBrands()
+
+	tests := []struct {
+		site   string
+		titles []string
+		logos  []string
+		want   map[string]string // technique to brand
+	}{
+		{site: "https://www.digikala.com/product/1", titles: []string{"دیجی‌کالا"}, want: map[string]string{}},
+		{site: "sep.shaparak.ir", want: map[string]string{}},
+		{site: "digikaala.com", want: map[string]string{ImpersonationTyposquat: "Digikala"}},
+		{site: "digi-kala.ir", want: map[string]string{ImpersonationTyposquat: "Digikala"}},
+		{site: "digikala.ir", want: map[string]string{ImpersonationTyposquat: "Digikala"}},
+		{site: "d1gikala.com", want: map[string]string{ImpersonationHomoglyph: "Digikala"}},
+		// digikаla with a cyrillic a
+		{site: "xn--digikla-6fg.com", want: map[string]string{ImpersonationHomoglyph: "Digikala"}},
+		{site: "shaparak.payment-verify.ir", want: map[string]string{ImpersonationBrandInDomain: "Shaparak"}},
+		{site: "mellat-bankmellat-login.com", want: map[string]string{ImpersonationBrandInDomain: "Bank Mellat"}},
+		{site: "cheap-shop.ir", titles: []string{"فروشگاه دیجی‌کالا"}, want: map[string]string{ImpersonationContentMismatch: "Digikala"}},
+		{site: "cheap-shop.ir", logos: []string{"Bank Mellat"}, want: map[string]string{ImpersonationContentMismatch: "Bank Mellat"}},
+		{site: "bmw.com", want: map[string]string{}},
+		{site: "example.com", titles: []string{"Example"}, want: map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		got := DetectImpersonation(tt.site, brands, tt.titles, tt.logos)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %+v, wanted %v", tt.site, got, tt.want)
+			continue
+		}
+		for _, f := range got {
+			if tt.want[f.Technique] != f.Brand || f.Evidence == "" || f.Severity() == 0 {
+				t.Errorf("%s: got %+v, wanted %v", tt.site, f, tt.want)
+			}
+		}
+	}
+}
+
+func TestSplitHost(t *testing.T) {
+	tests := []struct {
+		host string
+		subs int
+		name string
+	}{
+		{"digikala.com", 0, "digikala"},
+		{"shop.digikala.co.ir", 1, "digikala"},
+		{"a.b.example.ir", 2, "example"},
+		{"example.ac.ir", 0, "example"},
+		{"shop.example.co.uk", 1, "example"},
+		{"دیجی‌کالا.ایران", 0, "دیجی‌کالا"},
+		{"localhost", 0, "localhost"},
+	}
+	for _, tt := range tests {
+		subs, name := splitHost(tt.host)
+		if len(subs) != tt.subs || name != tt.name {
+			t.Errorf("splitHost(%q) got %v %q", tt.host, subs, name)
+		}
+	}
+}
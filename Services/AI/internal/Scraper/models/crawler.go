@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// CrawlerConfig limits how long and how wide Do_scrape crawls a site
+type CrawlerConfig struct {
+	// MaxDuration bounds the whole crawl
+	MaxDuration    time.Duration `yaml:"max_duration"`
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	Parallelism    int           `yaml:"parallelism"`
+	RandomDelay    time.Duration `yaml:"random_delay"`
+	// MaxActiveRequests is how many requests may be in flight, extra links are dropped
+	MaxActiveRequests int    `yaml:"max_active_requests"`
+	CacheDir          string `yaml:"cache_dir"`
+}
+
+// DefaultCrawlerConfig returns the limits the crawler always used
+func DefaultCrawlerConfig() CrawlerConfig {
+	return CrawlerConfig{
+		MaxDuration:       1 * time.Minute,
+		RequestTimeout:    30 * time.Second,
+		Parallelism:       4,
+		RandomDelay:       1 * time.Second,
+		MaxActiveRequests: 100,
+		CacheDir:          "./scrape_cache",
+	}
+}
+
+// ScreenshotConfig configures the headless browser taking screenshots
+type ScreenshotConfig struct {
+	Timeout time.Duration `yaml:"timeout"`
+	Width   int           `yaml:"width"`
+	Height  int           `yaml:"height"`
+}
+
+// DefaultScreenshotConfig returns the settings screenshots always used
+func DefaultScreenshotConfig() ScreenshotConfig {
+	return ScreenshotConfig{
+		Timeout: 30 * time.Second,
+		Width:   1920,
+		Height:  1080,
+	}
+}
+
+// EnamadConfig chooses where Enamad certificates are looked up and how long they are cached
+type EnamadConfig struct {
+	// BaseURL is the enamad.ir site, GetData is posted to BaseURL/Home/GetData
+	BaseURL string        `yaml:"base_url"`
+	Timeout time.Duration `yaml:"timeout"`
+	// Retries is how many times a failed lookup is tried again, RetryDelay apart
+	Retries    int           `yaml:"retries"`
+	RetryDelay time.Duration `yaml:"retry_delay"`
+	// CacheTTL is how long a certificate is reused, zero disables the cache
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// DefaultEnamadConfig returns the settings used when nothing else is configured
+func DefaultEnamadConfig() EnamadConfig {
+	return EnamadConfig{
+		BaseURL:    "https://enamad.ir",
+		Timeout:    15 * time.Second,
+		Retries:    2,
+		RetryDelay: 1 * time.Second,
+		CacheTTL:   24 * time.Hour,
+	}
+}
+
+// SamandehiConfig chooses where the verify pages of Samandehi badges are read
+type SamandehiConfig struct {
+	// BaseURL serves Verify.aspx, the badge ids are looked up there and not at the link of the badge
+	BaseURL string        `yaml:"base_url"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// DefaultSamandehiConfig returns the settings used when nothing else is configured
+func DefaultSamandehiConfig() SamandehiConfig {
+	return SamandehiConfig{
+		BaseURL: "https://logo.samandehi.ir",
+		Timeout: 15 * time.Second,
+	}
+}
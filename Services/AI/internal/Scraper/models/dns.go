@@ -0,0 +1,197 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fast flux thresholds, the addresses of a flux domain rotate quickly over many networks
+const (
+	fluxMinAddresses = 5
+	// fluxMaxTTL is the lowest TTL a stable host usually publishes, in seconds
+	fluxMaxTTL     = 300
+	fluxMinNetwork = 3
+)
+
+// DNSRecord is one answer of the resolver
+type DNSRecord struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	TTL   uint32 `json:"ttl"`
+}
+
+// HostingInfo is the network an address of the domain is announced from
+type HostingInfo struct {
+	IP       string `json:"ip"`
+	ASN      string `json:"asn,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+	Country  string `json:"country,omitempty"`
+	Provider string `json:"provider,omitempty"`
+}
+
+// FastFlux tells whether the address records look like a fast flux network
+type FastFlux struct {
+	Suspected bool     `json:"suspected"`
+	Addresses int      `json:"addresses"`
+	MinTTL    uint32   `json:"min_ttl"`
+	Networks  int      `json:"networks"`
+	Evidence  []string `json:"evidence"`
+}
+
+// DNSReport is what the resolver tells about a domain
+type DNSReport struct {
+	Domain   string      `json:"domain"`
+	Resolver string      `json:"resolver"`
+	A        []DNSRecord `json:"a"`
+	AAAA     []DNSRecord `json:"aaaa"`
+	NS       []DNSRecord `json:"ns"`
+	MX       []DNSRecord `json:"mx"`
+	TXT      []DNSRecord `json:"txt"`
+	// Addresses are the A and AAAA values, they are what other scans are matched on
+	Addresses []string `json:"addresses"`
+
+	SPF      string `json:"spf,omitempty"`
+	HasSPF   bool   `json:"has_spf"`
+	DMARC    string `json:"dmarc,omitempty"`
+	HasDMARC bool   `json:"has_dmarc"`
+
+	Hosting  []HostingInfo `json:"hosting"`
+	FastFlux FastFlux      `json:"fast_flux"`
+	// SharedWith are previously flagged domains resolving to one of Addresses
+	SharedWith []string `json:"shared_with"`
+
+	// Errors are the lookups that failed, a missing record is not an error
+	Errors []string `json:"errors"`
+}
+
+// NewDNSReport returns an empty report of domain
+func NewDNSReport(domain, resolver string) *DNSReport {
+	return &DNSReport{
+		Domain:     domain,
+		Resolver:   resolver,
+		A:          []DNSRecord{},
+		AAAA:       []DNSRecord{},
+		NS:         []DNSRecord{},
+		MX:         []DNSRecord{},
+		TXT:        []DNSRecord{},
+		Addresses:  []string{},
+		Hosting:    []HostingInfo{},
+		FastFlux:   FastFlux{Evidence: []string{}},
+		SharedWith: []string{},
+		Errors:     []string{},
+	}
+}
+
+// SetTXT stores the TXT records of the domain and picks its SPF policy
+func (r *DNSReport) SetTXT(records []DNSRecord) {
+	r.TXT = records
+	for _, rec := range records {
+		if isSPF(rec.Value) {
+			r.SPF, r.HasSPF = rec.Value, true
+			return
+		}
+	}
+}
+
+// SetDMARC picks the DMARC policy among the TXT records of _dmarc.domain
+func (r *DNSReport) SetDMARC(records []DNSRecord) {
+	for _, rec := range records {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(rec.Value)), "v=dmarc1") {
+			r.DMARC, r.HasDMARC = rec.Value, true
+			return
+		}
+	}
+}
+
+func isSPF(txt string) bool {
+	txt = strings.ToLower(strings.TrimSpace(txt))
+	return txt == "v=spf1" || strings.HasPrefix(txt, "v=spf1 ")
+}
+
+// DetectFastFlux flags domains with many short lived addresses spread over several networks,
+// two of the three signs are needed since large CDNs show one of them on their own
+func (r *DNSReport) DetectFastFlux() {
+	addresses := append(append([]DNSRecord{}, r.A...), r.AAAA...)
+	flux := FastFlux{Addresses: len(addresses), Evidence: []string{}}
+
+	for i, rec := range addresses {
+		if i == 0 || rec.TTL < flux.MinTTL {
+			flux.MinTTL = rec.TTL
+		}
+	}
+	networks := map[string]bool{}
+	for _, h := range r.Hosting {
+		if h.ASN != "" {
+			networks[h.ASN] = true
+		}
+	}
+	flux.Networks = len(networks)
+
+	if flux.Addresses >= fluxMinAddresses {
+		flux.Evidence = append(flux.Evidence, fmt.Sprintf("%d address records", flux.Addresses))
+	}
+	if flux.Addresses > 0 && flux.MinTTL < fluxMaxTTL {
+		flux.Evidence = append(flux.Evidence, fmt.Sprintf("address records expire after %d seconds", flux.MinTTL))
+	}
+	if flux.Networks >= fluxMinNetwork {
+		flux.Evidence = append(flux.Evidence, fmt.Sprintf("addresses announced by %d different networks", flux.Networks))
+	}
+
+	flux.Suspected = len(flux.Evidence) >= 2
+	r.FastFlux = flux
+}
+
+// Issues lists the DNS findings worth reporting
+func (r *DNSReport) Issues() []string {
+	issues := []string{}
+	if len(r.Addresses) == 0 {
+		issues = append(issues, "the domain has no address records")
+	}
+	if len(r.NS) == 0 {
+		issues = append(issues, "no name servers were found")
+	}
+	if len(r.MX) > 0 && !r.HasSPF {
+		issues = append(issues, "the domain receives mail but publishes no SPF policy")
+	}
+	if len(r.MX) > 0 && !r.HasDMARC {
+		issues = append(issues, "the domain receives mail but publishes no DMARC policy")
+	}
+	if r.FastFlux.Suspected {
+		issues = append(issues, "fast flux hosting: "+strings.Join(r.FastFlux.Evidence, ", "))
+	}
+	if len(r.SharedWith) > 0 {
+		issues = append(issues, fmt.Sprintf("shares addresses with %d flagged domains: %s", len(r.SharedWith), strings.Join(r.SharedWith, ", ")))
+	}
+	return issues
+}
+
+// ParseCymruOrigin reads a Team Cymru origin record like
+// "13335 | 104.16.0.0/13 | US | arin | 2014-03-28", the first of several origins is kept
+func ParseCymruOrigin(txt string) (asn, prefix, country string) {
+	fields := strings.Split(txt, "|")
+	if len(fields) < 3 {
+		return "", "", ""
+	}
+	// a prefix announced by several networks lists all of them
+	if asns := strings.Fields(fields[0]); len(asns) > 0 {
+		asn = asns[0]
+	}
+	return asn, strings.TrimSpace(fields[1]), strings.TrimSpace(fields[2])
+}
+
+// ParseCymruASName reads the name out of a Team Cymru AS record like
+// "13335 | US | arin | 2010-07-14 | CLOUDFLARENET, US"
+func ParseCymruASName(txt string) string {
+	fields := strings.Split(txt, "|")
+	if len(fields) < 5 {
+		return ""
+	}
+	return strings.TrimSpace(fields[4])
+}
+
+// RegisteredDomain strips the subdomains of host, a.shop.co.ir becomes shop.co.ir
+func RegisteredDomain(host string) string {
+	sub, _ := splitHost(host)
+	labels := strings.Split(host, ".")
+	return strings.Join(labels[len(sub):], ".")
+}
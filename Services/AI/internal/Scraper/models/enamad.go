@@ -1,5 +1,10 @@
 package models
 
+import (
+	"fmt"
+	"time"
+)
+
 
 
 
@@ -15,4 +20,58 @@ type Enamad_Data struct {
 	SrvText      string `json:"srvText"`
 	Code         string `json:"Code"`
 	IsNewProfile bool   `json:"isNewProfile"`
+
+	// the fields below are derived by Resolve, the dates are read from the solar calendar
+	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	IsExpired  bool       `json:"is_expired"`
+	// DaysToExpiry is negative once the certificate expired, 0 when ExpDate is unknown
+	DaysToExpiry int       `json:"days_to_expiry"`
+	StarLevel    string    `json:"star_level,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	// Cached is set when the certificate was read from the cache instead of enamad.ir
+	Cached bool `json:"cached"`
+}
+
+// Registered reports whether enamad.ir lists a certificate for the domain
+func (e *Enamad_Data) Registered() bool {
+	return e != nil && e.ID != 0
+}
+
+// Resolve parses ApproveDate and ExpDate and derives the expiry and star level at now
+func (e *Enamad_Data) Resolve(now time.Time) {
+	e.ApprovedAt = parseEnamadDate(e.ApproveDate)
+	e.ExpiresAt = parseEnamadDate(e.ExpDate)
+	e.IsExpired, e.DaysToExpiry = false, 0
+	if e.ExpiresAt != nil {
+		// the certificate is valid through its last day
+		end := e.ExpiresAt.AddDate(0, 0, 1)
+		e.IsExpired = !now.Before(end)
+		e.DaysToExpiry = int(e.ExpiresAt.Sub(now.UTC().Truncate(24*time.Hour)).Hours() / 24)
+	}
+	e.StarLevel = EnamadStarMeaning(e.LogoLevel)
+}
+
+func parseEnamadDate(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	if t, err := ParseJalaliDate(value); err == nil {
+		return &t
+	}
+	return nil
+}
+
+// EnamadStarMeaning explains what enamad.ir verified to grant a certificate of level stars
+func EnamadStarMeaning(level int) string {
+	switch {
+	case level <= 0:
+		return ""
+	case level == 1:
+		return "one star: the identity, address and phone of the owner are verified"
+	case level == 2:
+		return "two stars: the business documents are verified too and the shop has a clean record"
+	default:
+		return fmt.Sprintf("%d stars: granted after a long clean record and further verified business documents", level)
+	}
 }
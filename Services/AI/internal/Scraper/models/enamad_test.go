@@ -0,0 +1,35 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnamadResolve(t *testing.T) {
+	data := &Enamad_Data{ID: 1, ApproveDate: "1401/01/01", ExpDate: "1402/01/01", LogoLevel: 1}
+
+	// 1402/01/01 is 21 March 2023, the certificate is valid through that day
+	data.Resolve(time.Date(2023, 3, 11, 15, 0, 0, 0, time.UTC))
+	if data.IsExpired || data.DaysToExpiry != 10 || data.StarLevel == "" {
+		t.Errorf("got %+v ten days before expiry", data)
+	}
+	data.Resolve(time.Date(2023, 3, 21, 23, 0, 0, 0, time.UTC))
+	if data.IsExpired || data.DaysToExpiry != 0 {
+		t.Errorf("got %+v on the last day", data)
+	}
+	data.Resolve(time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC))
+	if !data.IsExpired || data.DaysToExpiry != -11 {
+		t.Errorf("got %+v after expiry", data)
+	}
+
+	unknown := &Enamad_Data{ID: 1, ExpDate: "soon"}
+	unknown.Resolve(time.Now())
+	if unknown.ExpiresAt != nil || unknown.IsExpired || unknown.DaysToExpiry != 0 {
+		t.Errorf("got %+v for an unreadable expiry date", unknown)
+	}
+
+	input := RiskInputFromReport(nil, 400, nil, nil, nil, NewComplianceReport([]RegistryResult{EnamadResult(data, nil)}))
+	if input.HasEnamad || !input.EnamadExpired {
+		t.Errorf("got %+v for an expired certificate", input)
+	}
+}
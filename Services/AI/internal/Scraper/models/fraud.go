@@ -3,27 +3,21 @@ package models
 import (
 	"bytes"
 	"fmt"
-	"log"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly/v2"
-	"golang.org/x/net/html"
 )
 
 type FraudIndicators struct {
 	// Content-based indicators
 	SuspiciousKeywords []string `json:"suspicious_keywords"`
 	HiddenElements     []string `json:"hidden_elements"` // CSS classes/IDs that often hide content
-
-	// Technical indicators
-	UnsecureConnection bool     `json:"unsecure_connection"`
-	SuspiciousHeaders  []string `json:"suspicious_headers"` // Missing or fake headers
-
-	// Structural indicators
-	NoContactInfo bool                   `json:"no_contact_info"`
-	DomainAge     int                    `json:"domain_age"` // Days since domain registration
-	Findings      map[string]interface{} `json:"findings"`
+	// Form indicators, places phishing kits send the harvested fields to
+	SuspiciousFormEndpoints []string `json:"suspicious_form_endpoints"`
 }
 
 func NewDefaultFraudIndicators() *FraudIndicators {
@@ -38,225 +32,200 @@ func NewDefaultFraudIndicators() *FraudIndicators {
 			"height:0", "width:0", "position:absolute",
 			"clip:rect(0,0,0,0)", "hidden", "aria-hidden",
 		},
-		SuspiciousHeaders: []string{"User-Agent", "Accept-Language", "Accept-Encoding",
-			"Referer", "DNT", "X-Requested-With",
+		SuspiciousFormEndpoints: []string{
+			"api.telegram.org", "discord.com/api/webhooks", "webhook.site",
+			"formspree.io", "formsubmit.co", "000webhostapp.com",
+			"send.php", "mail.php", "post.php", "next.php", "result.php",
 		},
-		DomainAge: 120, // Minimum age in days to be considered trustworthy
-		Findings:  make(map[string]interface{}),
 	}
 }
 
 var (
+	// fraudKeywords are looked for in the visible text of a page
 	fraudKeywords = []string{
 		"urgent", "guarantee", "risk-free", "act now", "limited time",
 		"congratulations", "prize", "winner", "free", "instant", "100% safe", "guaranteed profit", "limited offer",
 		"won't believe", "click here", "instant money",
 		"risk-free", "double your", "earn cash", "github",
 	}
-)
 
-func nodeToString(n *html.Node) (string, error) {
-	if n == nil {
-		return "", fmt.Errorf("node is nil")
-	}
+	contactPatterns = []string{"contact", "about", "support", "help", "email", "phone", "address", "تماس با", "ارتباط"}
+)
 
-	var buf bytes.Buffer
-	err := html.Render(&buf, n)
-	if err != nil {
-		return "", err
+// AnalyzePage returns the findings of a single crawled page
+func (fi *FraudIndicators) AnalyzePage(resp *colly.Response) PageResult {
+	page := PageResult{
+		URL:            resp.Request.URL.String(),
+		StatusCode:     resp.StatusCode,
+		Secure:         resp.Request.URL.Scheme == "https",
+		Keywords:       []string{},
+		LogoTexts:      []string{},
+		HiddenElements: []string{},
+		Forms:          []FormResult{},
+		ContactLinks:   []string{},
+		FormFindings:   []FormFinding{},
+		TrustSeals:     []TrustSeal{},
+		BusinessIDs:    []string{},
 	}
-	return buf.String(), nil
-}
 
-func isHidden(n *html.Node) bool {
-	for _, attr := range n.Attr {
-		if attr.Key == "style" && strings.Contains(strings.ToLower(attr.Val), "display:none") {
-			return true
-		}
-		if attr.Key == "type" && attr.Val == "hidden" {
-			return true
-		}
+	if resp.Headers != nil {
+		page.Headers = AuditHeaders(page.URL, page.Secure, *resp.Headers)
 	}
-	return false
-}
-
-// Example implementation of AnalyzeContent (should be in models package)
-func (fi *FraudIndicators) AnalyzeContent(doc *html.Node, r *colly.Response) {
-	var f func(*html.Node)
-	foundKeywords := make(map[string]bool)
-	var hiddenElements []string
-
-	f = func(n *html.Node) {
-		if n.Type == html.ElementNode {
-			// Check for hidden elements
-			string_node, err := nodeToString(n)
-			if err != nil {
-				log.Printf("converting html node to string went wrong in fraud.go file : %s \n", err)
-			}
-			if ok, _ := fi.detectHiddenElements(string_node); ok {
-				hiddenElements = append(hiddenElements, n.Data)
-			}
-		}
 
-		if n.Type == html.TextNode {
-			// Check for fraud keywords
-			text := strings.ToLower(n.Data)
-			for _, kw := range fraudKeywords {
-				if strings.Contains(text, kw) {
-					foundKeywords[kw] = true
-				}
-			}
-
-			// Check for contact info
-			if strings.Contains(text, "@") || strings.Contains(text, "phone") {
-				fi.Findings["NoContactInfo"] = false
-			} else {
-				fi.Findings["NoContactInfo"] = true
-			}
-		}
-
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			f(c)
-		}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+	if err != nil {
+		return page
 	}
 
-	f(doc)
-
-	// Update findings
-	// for kw := range foundKeywords {
-	// 	fi.SuspiciousKeywords = append(fi.SuspiciousKeywords, kw)
-	// }
-	fi.Findings["FoundKeywords"] = foundKeywords
-	fi.Findings["HiddenElements"] = hiddenElements
+	page.Title = strings.TrimSpace(doc.Find("title").First().Text())
+	page.LogoTexts = detectLogos(doc)
+	page.HiddenElements = fi.detectHiddenElements(doc)
+	page.Forms = detectForms(doc, resp.Request)
+	page.ContactLinks = checkContactInfo(doc)
+	page.TrustSeals = detectTrustSeals(doc, resp.Request.URL)
+	page.BusinessIDs = detectBusinessIDs(doc.Text())
+	// keywords last, the visible text is read by removing scripts from the document
+	page.Keywords = fi.detectKeyWords(doc, string(resp.Body))
+	page.FormFindings = fi.DetectPhishingForms(resp.Request.URL, strings.ToLower(doc.Text()), page.Forms)
+
+	return page
 }
 
-func (fi *FraudIndicators) AnalyzeResponse(resp *colly.Response) {
-	html := string(resp.Body)
-	req := resp.Request
-	// urlObj, err := extractMainDomain(req.URL.String())
-	// if err != nil {
-	// 	log.Println(err)
-	// }
-	// fmt.Println(urlObj)
-
-	//  Check for suspicious keywords
-	fi.Findings["keywords"] = fi.detectKeyWords(html)
-
-	//  Check for hidden elements
-	hasHidden, hiddenElements := fi.detectHiddenElements(html)
-	fi.Findings["has_hidden_elements"] = hasHidden
-	fi.Findings["hidden_elements"] = hiddenElements
-
-	//  Check security
-	fi.checkSecurity(req)
-
-	//  Check contact info
-	contactInfo := fi.checkContactInfo(html)
-	fi.Findings["has_contact_info"] = len(contactInfo) > 0
-	fi.Findings["contact_info"] = contactInfo
-	//  Check domain age (mock)
-	//fi.Findings["new_domain"] = fi.checkDomainAge(handlers.Whois(urlObj))
-	//return results
-}
-
-// this function will return values decpite of storing in object
-func (fi *FraudIndicators) detectHiddenElements(html string) (bool, []string) {
-
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader([]byte(html)))
-	if err != nil {
-		return false, nil
-	}
-
-	var foundElements []string
-	found := false
+// detectHiddenElements lists the styles and classes used to hide elements
+func (fi *FraudIndicators) detectHiddenElements(doc *goquery.Document) []string {
+	found := []string{}
 
 	for _, selector := range fi.HiddenElements {
 		doc.Find("*").Each(func(i int, s *goquery.Selection) {
 			if style, exists := s.Attr("style"); exists {
 				if strings.Contains(strings.ToLower(style), strings.ToLower(selector)) {
-					foundElements = append(foundElements, fmt.Sprintf("Hidden by style: %s", selector))
-					found = true
+					found = append(found, fmt.Sprintf("Hidden by style: %s", selector))
 				}
 			}
 
 			if class, exists := s.Attr("class"); exists {
 				if strings.Contains(strings.ToLower(class), strings.ToLower(selector)) {
-					foundElements = append(foundElements, fmt.Sprintf("Hidden by class: %s", selector))
-					found = true
+					found = append(found, fmt.Sprintf("Hidden by class: %s", selector))
 				}
 			}
 		})
 	}
 
-	return found, foundElements
-
+	return found
 }
 
-/*
-func (fi *FraudIndicators) checkDomainAge(whois whoisparser.WhoisInfo) int {
+// detectLogos lists the alt text and file name of the images that look like a logo
+func detectLogos(doc *goquery.Document) []string {
+	logos := []string{}
+	doc.Find("img").Each(func(i int, s *goquery.Selection) {
+		alt := strings.TrimSpace(s.AttrOr("alt", ""))
+		src := strings.TrimSpace(s.AttrOr("src", ""))
+		hint := strings.ToLower(strings.Join([]string{alt, src, s.AttrOr("class", ""), s.AttrOr("id", "")}, " "))
+		if !strings.Contains(hint, "logo") && !strings.Contains(hint, "لوگو") {
+			return
+		}
+		if alt != "" {
+			logos = append(logos, alt)
+		}
+		if src != "" && !strings.HasPrefix(src, "data:") {
+			logos = append(logos, path.Base(src))
+		}
+	})
+	return logos
+}
 
-	creationDate, err := time.Parse(time.RFC3339, whois.Domain.CreatedDate)
-	if err != nil {
-		log.Println(err)
-	}
+// detectForms lists the forms of the page with their absolute action and input types
+func detectForms(doc *goquery.Document, req *colly.Request) []FormResult {
+	forms := []FormResult{}
 
-	ageInDays := int(time.Since(creationDate).Hours() / 24)
+	doc.Find("form").Each(func(i int, s *goquery.Selection) {
+		action, _ := s.Attr("action")
+		method, _ := s.Attr("method")
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method == "" {
+			method = "GET"
+		}
 
-	return ageInDays
-}
-*/
+		form := FormResult{
+			Action: req.AbsoluteURL(strings.TrimSpace(action)),
+			Method: method,
+			Inputs: []FormInput{},
+		}
 
-func (fi *FraudIndicators) checkSecurity(req *colly.Request) {
+		s.Find("input, textarea, select").Each(func(i int, field *goquery.Selection) {
+			input := FormInput{
+				Name:         field.AttrOr("name", ""),
+				ID:           field.AttrOr("id", ""),
+				Type:         goquery.NodeName(field),
+				Placeholder:  field.AttrOr("placeholder", ""),
+				Autocomplete: strings.ToLower(field.AttrOr("autocomplete", "")),
+			}
+			if input.Type == "input" {
+				input.Type = strings.ToLower(strings.TrimSpace(field.AttrOr("type", "text")))
+			}
+			if maxLength, err := strconv.Atoi(strings.TrimSpace(field.AttrOr("maxlength", ""))); err == nil {
+				input.MaxLength = maxLength
+			}
+			form.Inputs = append(form.Inputs, input)
+		})
 
-	if !strings.HasPrefix(req.URL.String(), "https://") {
-		fi.UnsecureConnection = true
-		fi.Findings["UnsecureConnection"] = true
-	}
-	// check headers
+		forms = append(forms, form)
+	})
 
-	for _, header := range fi.SuspiciousHeaders {
+	return forms
+}
 
-		headers := *req.Headers
-		if _, exists := headers[header]; !exists {
-			fi.Findings["missing_header_"+header] = true
+// checkContactInfo lists the links pointing to contact, about or support information
+func checkContactInfo(doc *goquery.Document) []string {
+	seen := make(map[string]bool)
+	founds := []string{}
+	add := func(value string) {
+		value = strings.TrimSpace(value)
+		if value != "" && !seen[value] {
+			seen[value] = true
+			founds = append(founds, value)
 		}
 	}
 
-}
-
-// this object will return valuse outisde if fraud indicator function
-func (fi *FraudIndicators) checkContactInfo(html string) []string {
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader([]byte(html)))
-	if err != nil {
-		log.Println(err)
-	}
-	contactPatterns := []string{"contact", "about", "support", "help", "email", "phone", "address", "تماس با", "ارتباط"}
-	var founds []string
 	doc.Find("a").Each(func(i int, s *goquery.Selection) {
 		href, _ := s.Attr("href")
 		text := strings.ToLower(s.Text())
 		for _, patterns := range contactPatterns {
 			if strings.Contains(href, patterns) {
-				founds = append(founds, href)
+				add(href)
 			}
 			if strings.Contains(text, patterns) {
-				founds = append(founds, text)
+				add(text)
 			}
 		}
 	})
 	return founds
 }
 
-func (fi *FraudIndicators) detectKeyWords(content string) []string {
+// detectKeyWords looks for the suspicious keywords in the raw page and the fraud
+// keywords in its visible text
+func (fi *FraudIndicators) detectKeyWords(doc *goquery.Document, content string) []string {
+	detected := make(map[string]bool)
 
-	var detected []string
 	content = strings.ToLower(content)
-
 	for _, kw := range fi.SuspiciousKeywords {
 		if strings.Contains(content, strings.ToLower(kw)) {
-			detected = append(detected, kw)
+			detected[kw] = true
+		}
+	}
 
+	doc.Find("script, style, noscript").Remove()
+	text := strings.ToLower(doc.Text())
+	for _, kw := range fraudKeywords {
+		if strings.Contains(text, kw) {
+			detected[kw] = true
 		}
 	}
-	return detected
+
+	keywords := make([]string, 0, len(detected))
+	for kw := range detected {
+		keywords = append(keywords, kw)
+	}
+	sort.Strings(keywords)
+	return keywords
 }
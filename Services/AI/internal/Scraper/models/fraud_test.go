@@ -0,0 +1,100 @@
+package models
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+)
+
+func testResponse(t *testing.T, rawURL, body string) *colly.Response {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("url.Parse returned an error: %v", err)
+	}
+	return &colly.Response{
+		StatusCode: 200,
+		Body:       []byte(body),
+		Request:    &colly.Request{URL: u},
+	}
+}
+
+func TestAnalyzePage(t *testing.T) {
+	body := `<html><body>
+		<p>Act now, this is a limited time deal!</p>
+		<script>var x = "click here";</script>
+		<div style="display:none">secret</div>
+		<a href="/contact">Contact us</a>
+		<a href="/contact">Contact us</a>
+		<form action="/login" method="post">
+			<input name="user">
+			<input name="pass" type="password">
+		</form>
+	</body></html>`
+
+	page := NewDefaultFraudIndicators().AnalyzePage(testResponse(t, "https://example.com/shop", body))
+
+	if !page.Secure || page.URL != "https://example.com/shop" {
+		t.Errorf("got page %+v", page)
+	}
+	// click here only appears in a script, it is caught in the raw html
+	if !reflect.DeepEqual(page.Keywords, []string{"act now", "click here", "limited time"}) {
+		t.Errorf("got keywords %v", page.Keywords)
+	}
+	if !reflect.DeepEqual(page.ContactLinks, []string{"/contact", "contact us"}) {
+		t.Errorf("got contact links %v", page.ContactLinks)
+	}
+	if len(page.Forms) != 1 {
+		t.Fatalf("got forms %+v", page.Forms)
+	}
+	form := page.Forms[0]
+	if form.Action != "https://example.com/login" || form.Method != "POST" || len(form.Inputs) != 2 || form.Inputs[1].Type != "password" {
+		t.Errorf("got form %+v", form)
+	}
+	if len(page.HiddenElements) == 0 {
+		t.Error("expected the hidden div to be found")
+	}
+}
+
+func TestScrapeReportFinish(t *testing.T) {
+	report := NewScrapeReport("example.com")
+	report.AddPage(PageResult{URL: "https://example.com", Secure: true, Keywords: []string{"urgent"}, ContactLinks: []string{"/contact"}})
+	report.AddPage(PageResult{URL: "http://example.com/a", Keywords: []string{"prize", "urgent"}, Forms: []FormResult{{Method: "POST"}}})
+	report.AddError(PageError{URL: "https://example.com/b", StatusCode: 404})
+	report.Finish()
+
+	site := report.Site
+	if site.PagesCrawled != 2 || site.PagesFailed != 1 || site.Secure || site.FormCount != 1 || !site.HasContactInfo {
+		t.Errorf("got summary %+v", site)
+	}
+	if !reflect.DeepEqual(site.Keywords, []string{"prize", "urgent"}) {
+		t.Errorf("got keywords %v", site.Keywords)
+	}
+	if !reflect.DeepEqual(site.InsecurePages, []string{"http://example.com/a"}) {
+		t.Errorf("got insecure pages %v", site.InsecurePages)
+	}
+
+	// urgent was seen on both pages so it comes first among the keywords
+	want := Finding{Indicator: IndicatorKeyword, Value: "urgent", Count: 2, Pages: []string{"https://example.com", "http://example.com/a"}}
+	var got *Finding
+	for i, f := range site.Findings {
+		if f.Indicator == IndicatorKeyword {
+			got = &site.Findings[i]
+			break
+		}
+	}
+	if got == nil || !reflect.DeepEqual(*got, want) {
+		t.Errorf("got first keyword finding %+v, wanted %+v", got, want)
+	}
+	if len(site.Findings) != 3 {
+		t.Errorf("got findings %+v", site.Findings)
+	}
+
+	empty := NewScrapeReport("example.com")
+	empty.Finish()
+	if empty.Site.Secure || empty.Site.HasContactInfo {
+		t.Errorf("got summary %+v for a site without pages", empty.Site)
+	}
+}
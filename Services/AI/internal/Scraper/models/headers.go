@@ -0,0 +1,221 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Grades of a HeaderCheck
+const (
+	GradePass = "pass"
+	GradeWarn = "warn"
+	GradeFail = "fail"
+)
+
+// minHSTSMaxAge is six months, the shortest max-age the HSTS preload list accepts is a year
+const minHSTSMaxAge = 15768000
+
+// versionPattern matches a version number leaked in a banner, like Apache/2.4.41
+var versionPattern = regexp.MustCompile(`\d+\.\d+`)
+
+// HeaderCheck is the grade of one security header of a response
+type HeaderCheck struct {
+	Header string `json:"header"`
+	Value  string `json:"value,omitempty"`
+	Grade  string `json:"grade"`
+	Detail string `json:"detail"`
+}
+
+// HeaderAudit grades the security headers a site sends back
+type HeaderAudit struct {
+	URL string `json:"url"`
+	// Score is the share of passed checks out of 100, a warning counts half
+	Score  int           `json:"score"`
+	Grade  string        `json:"grade"`
+	Checks []HeaderCheck `json:"checks"`
+}
+
+// Failed returns the checks that did not pass
+func (a *HeaderAudit) Failed() []HeaderCheck {
+	failed := []HeaderCheck{}
+	if a == nil {
+		return failed
+	}
+	for _, c := range a.Checks {
+		if c.Grade != GradePass {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// AuditHeaders grades the response headers of pageURL, secure tells whether it was served over https
+func AuditHeaders(pageURL string, secure bool, header http.Header) *HeaderAudit {
+	audit := &HeaderAudit{URL: pageURL}
+	add := func(name, value, grade, detail string) {
+		audit.Checks = append(audit.Checks, HeaderCheck{Header: name, Value: value, Grade: grade, Detail: detail})
+	}
+
+	csp := header.Get("Content-Security-Policy")
+
+	hsts := header.Get("Strict-Transport-Security")
+	switch {
+	case !secure:
+		add("Strict-Transport-Security", hsts, GradeFail, "the page is served over http, browsers are never told to require https")
+	case hsts == "":
+		add("Strict-Transport-Security", "", GradeFail, "missing, browsers may still reach the site over http")
+	case hstsMaxAge(hsts) < minHSTSMaxAge:
+		add("Strict-Transport-Security", hsts, GradeWarn, "max-age is shorter than six months")
+	default:
+		add("Strict-Transport-Security", hsts, GradePass, "https is enforced")
+	}
+
+	switch lower := strings.ToLower(csp); {
+	case csp == "" && header.Get("Content-Security-Policy-Report-Only") != "":
+		add("Content-Security-Policy", header.Get("Content-Security-Policy-Report-Only"), GradeWarn, "only reported, nothing is enforced")
+	case csp == "":
+		add("Content-Security-Policy", "", GradeFail, "missing, any injected script runs")
+	case strings.Contains(lower, "'unsafe-inline'") || strings.Contains(lower, "'unsafe-eval'"):
+		add("Content-Security-Policy", csp, GradeWarn, "allows inline scripts or eval")
+	case cspWildcard(lower):
+		add("Content-Security-Policy", csp, GradeWarn, "allows scripts from any origin")
+	default:
+		add("Content-Security-Policy", csp, GradePass, "scripts are restricted")
+	}
+
+	xfo := header.Get("X-Frame-Options")
+	switch upper := strings.ToUpper(strings.TrimSpace(xfo)); {
+	case upper == "DENY" || upper == "SAMEORIGIN":
+		add("X-Frame-Options", xfo, GradePass, "the page cannot be framed by other sites")
+	case strings.Contains(strings.ToLower(csp), "frame-ancestors"):
+		add("X-Frame-Options", xfo, GradePass, "framing is restricted by the frame-ancestors policy")
+	case xfo == "":
+		add("X-Frame-Options", "", GradeFail, "missing, the page can be framed for clickjacking")
+	default:
+		add("X-Frame-Options", xfo, GradeWarn, "value is not honoured by current browsers")
+	}
+
+	if nosniff := header.Get("X-Content-Type-Options"); strings.EqualFold(strings.TrimSpace(nosniff), "nosniff") {
+		add("X-Content-Type-Options", nosniff, GradePass, "content types are not sniffed")
+	} else {
+		add("X-Content-Type-Options", nosniff, GradeFail, "missing nosniff, responses can be run as another content type")
+	}
+
+	switch referrer := header.Get("Referrer-Policy"); strings.ToLower(strings.TrimSpace(referrer)) {
+	case "":
+		add("Referrer-Policy", "", GradeWarn, "missing, the browser default applies")
+	case "unsafe-url", "no-referrer-when-downgrade":
+		add("Referrer-Policy", referrer, GradeWarn, "full urls are sent to other sites")
+	default:
+		add("Referrer-Policy", referrer, GradePass, "urls sent to other sites are trimmed")
+	}
+
+	auditCookies(header, secure, add)
+
+	var leaks []string
+	if server := header.Get("Server"); versionPattern.MatchString(server) {
+		leaks = append(leaks, "Server: "+server)
+	}
+	for _, name := range []string{"X-Powered-By", "X-AspNet-Version", "X-AspNetMvc-Version", "X-Generator"} {
+		if value := header.Get(name); value != "" {
+			leaks = append(leaks, name+": "+value)
+		}
+	}
+	if len(leaks) > 0 {
+		add("Server", strings.Join(leaks, ", "), GradeWarn, "the software and its version are disclosed")
+	} else {
+		add("Server", header.Get("Server"), GradePass, "no software version is disclosed")
+	}
+
+	var points float64
+	for _, c := range audit.Checks {
+		switch c.Grade {
+		case GradePass:
+			points += 1
+		case GradeWarn:
+			points += 0.5
+		}
+	}
+	audit.Score = int(points/float64(len(audit.Checks))*100 + 0.5)
+	audit.Grade = letterGrade(audit.Score)
+	return audit
+}
+
+// auditCookies checks the Secure, HttpOnly and SameSite flags of every cookie set by the response
+func auditCookies(header http.Header, secure bool, add func(name, value, grade, detail string)) {
+	cookies := (&http.Response{Header: header}).Cookies()
+	if len(cookies) == 0 {
+		add("Set-Cookie", "", GradePass, "no cookies are set")
+		return
+	}
+
+	var names, problems []string
+	for _, cookie := range cookies {
+		names = append(names, cookie.Name)
+		var missing []string
+		if secure && !cookie.Secure {
+			missing = append(missing, "Secure")
+		}
+		if !cookie.HttpOnly {
+			missing = append(missing, "HttpOnly")
+		}
+		if cookie.SameSite == http.SameSiteDefaultMode {
+			missing = append(missing, "SameSite")
+		}
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("%s without %s", cookie.Name, strings.Join(missing, ", ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		add("Set-Cookie", strings.Join(names, ", "), GradeWarn, strings.Join(problems, "; "))
+	} else {
+		add("Set-Cookie", strings.Join(names, ", "), GradePass, "every cookie is Secure, HttpOnly and SameSite")
+	}
+}
+
+func hstsMaxAge(value string) int {
+	for _, directive := range strings.Split(value, ";") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if strings.EqualFold(name, "max-age") {
+			seconds, err := strconv.Atoi(strings.Trim(arg, `" `))
+			if err == nil {
+				return seconds
+			}
+		}
+	}
+	return 0
+}
+
+// cspWildcard reports whether scripts may load from any origin
+func cspWildcard(csp string) bool {
+	for _, directive := range strings.Split(csp, ";") {
+		fields := strings.Fields(directive)
+		if len(fields) == 0 || (fields[0] != "script-src" && fields[0] != "default-src") {
+			continue
+		}
+		for _, source := range fields[1:] {
+			if source == "*" || source == "http:" || source == "https:" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func letterGrade(score int) string {
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 75:
+		return "B"
+	case score >= 60:
+		return "C"
+	case score >= 40:
+		return "D"
+	}
+	return "F"
+}
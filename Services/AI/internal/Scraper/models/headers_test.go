@@ -0,0 +1,62 @@
+package models
+
+import (
+	"net/http"
+	"testing"
+)
+
+func gradeOf(audit *HeaderAudit, header string) string {
+	for _, c := range audit.Checks {
+		if c.Header == header {
+			return c.Grade
+		}
+	}
+	return ""
+}
+
+func TestAuditHeaders(t *testing.T) {
+	hardened := http.Header{}
+	hardened.Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+	hardened.Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+	hardened.Set("X-Content-Type-Options", "nosniff")
+	hardened.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+	hardened.Set("Server", "nginx")
+	hardened.Add("Set-Cookie", "session=1; Secure; HttpOnly; SameSite=Lax")
+
+	got := AuditHeaders("https://example.ir", true, hardened)
+	if got.Score != 100 || got.Grade != "A" || len(got.Failed()) != 0 {
+		t.Errorf("got %+v for a hardened site", got)
+	}
+
+	bare := http.Header{}
+	bare.Set("Server", "Apache/2.4.41 (Ubuntu)")
+	bare.Set("X-Powered-By", "PHP/7.2")
+	bare.Set("Strict-Transport-Security", "max-age=300")
+	bare.Set("Content-Security-Policy", "script-src 'self' 'unsafe-inline'")
+	bare.Add("Set-Cookie", "PHPSESSID=abc; path=/")
+
+	got = AuditHeaders("https://example.ir", true, bare)
+	want := map[string]string{
+		"Strict-Transport-Security": GradeWarn,
+		"Content-Security-Policy":   GradeWarn,
+		"X-Frame-Options":           GradeFail,
+		"X-Content-Type-Options":    GradeFail,
+		"Referrer-Policy":           GradeWarn,
+		"Set-Cookie":                GradeWarn,
+		"Server":                    GradeWarn,
+	}
+	for header, grade := range want {
+		if g := gradeOf(got, header); g != grade {
+			t.Errorf("got %s for %s, wanted %s", g, header, grade)
+		}
+	}
+	// five warnings out of seven checks
+	if got.Score != 36 || got.Grade != "F" {
+		t.Errorf("got grade %s score %d, wanted F and 36", got.Grade, got.Score)
+	}
+
+	// hsts cannot apply to a page served over http
+	if g := gradeOf(AuditHeaders("http://example.ir", false, hardened), "Strict-Transport-Security"); g != GradeFail {
+		t.Errorf("got %s for hsts over http", g)
+	}
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// persianDigits maps the Persian and Arabic-Indic digits to ASCII
+var persianDigits = strings.NewReplacer(
+	"۰", "0", "۱", "1", "۲", "2", "۳", "3", "۴", "4", "۵", "5", "۶", "6", "۷", "7", "۸", "8", "۹", "9",
+	"٠", "0", "١", "1", "٢", "2", "٣", "3", "٤", "4", "٥", "5", "٦", "6", "٧", "7", "٨", "8", "٩", "9",
+)
+
+// JalaliToGregorian converts a date of the Iranian solar calendar to the Gregorian one
+func JalaliToGregorian(jy, jm, jd int) (int, int, int) {
+	jy += 1595
+	days := -355668 + 365*jy + (jy/33)*8 + ((jy%33)+3)/4 + jd
+	if jm < 7 {
+		days += (jm - 1) * 31
+	} else {
+		days += (jm-7)*30 + 186
+	}
+
+	gy := 400 * (days / 146097)
+	days %= 146097
+	if days > 36524 {
+		days--
+		gy += 100 * (days / 36524)
+		days %= 36524
+		if days >= 365 {
+			days++
+		}
+	}
+	gy += 4 * (days / 1461)
+	days %= 1461
+	if days > 365 {
+		gy += (days - 1) / 365
+		days = (days - 1) % 365
+	}
+
+	gd := days + 1
+	monthDays := []int{31, 28, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+	if (gy%4 == 0 && gy%100 != 0) || gy%400 == 0 {
+		monthDays[1] = 29
+	}
+	gm := 0
+	for gm < 12 && gd > monthDays[gm] {
+		gd -= monthDays[gm]
+		gm++
+	}
+	return gy, gm + 1, gd
+}
+
+// ParseJalaliDate reads a solar date like 1402/02/11 or ۱۴۰۲-۰۲-۱۱, a time after a space is ignored
+func ParseJalaliDate(value string) (time.Time, error) {
+	value = persianDigits.Replace(strings.TrimSpace(value))
+	if date, _, ok := strings.Cut(value, " "); ok {
+		value = date
+	}
+
+	parts := strings.FieldsFunc(value, func(r rune) bool { return r == '/' || r == '-' || r == '.' })
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("invalid jalali date %q", value)
+	}
+	var ymd [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid jalali date %q", value)
+		}
+		ymd[i] = n
+	}
+	if ymd[0] < 1200 || ymd[0] > 1600 || ymd[1] < 1 || ymd[1] > 12 || ymd[2] < 1 || ymd[2] > 31 || (ymd[1] > 6 && ymd[2] > 30) {
+		return time.Time{}, fmt.Errorf("invalid jalali date %q", value)
+	}
+
+	gy, gm, gd := JalaliToGregorian(ymd[0], ymd[1], ymd[2])
+	return time.Date(gy, time.Month(gm), gd, 0, 0, 0, 0, time.UTC), nil
+}
@@ -0,0 +1,27 @@
+package models
+
+import "testing"
+
+func TestJalaliToGregorian(t *testing.T) {
+	tests := []struct {
+		jy, jm, jd int
+		gy, gm, gd int
+	}{
+		{1402, 1, 1, 2023, 3, 21},
+		{1403, 1, 1, 2024, 3, 20},
+		{1403, 12, 30, 2025, 3, 20},
+		{1399, 10, 11, 2020, 12, 31},
+		{1379, 11, 12, 2001, 1, 31},
+	}
+
+	for _, tt := range tests {
+		gy, gm, gd := JalaliToGregorian(tt.jy, tt.jm, tt.jd)
+		if gy != tt.gy || gm != tt.gm || gd != tt.gd {
+			t.Errorf("JalaliToGregorian(%d, %d, %d) got %d-%d-%d, wanted %d-%d-%d", tt.jy, tt.jm, tt.jd, gy, gm, gd, tt.gy, tt.gm, tt.gd)
+		}
+	}
+
+	if _, err := ParseJalaliDate("1402/13/01"); err == nil {
+		t.Error("expected an error for month 13")
+	}
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// ErrInvalidURL is returned for urls that do not name a site under a public domain
+var ErrInvalidURL = errors.New("invalid url")
+
+// SiteIdentity is the canonical form of a scanned url. Scans, screenshots, whois and Enamad
+// lookups all name the site by it, so the spellings of one url share their results.
+type SiteIdentity struct {
+	// URL has a lower case punycode host and no credentials, default port or fragment
+	URL string `json:"url"`
+	// Key names the site in the caches, the history, the screenshots and url_storage, which
+	// the other services read by exact match. It is SiteHost: the scheme, port, path and query
+	// of one site all share its verdict, URL is only used to fetch the page asked about.
+	Key string `json:"key"`
+	// Host is the punycode host, UnicodeHost the same host as users read it
+	Host        string `json:"host"`
+	UnicodeHost string `json:"unicode_host"`
+	// SiteHost is Host without www, the name certificates and registrations are issued for
+	SiteHost string `json:"site_host"`
+	// Domain is the host registered under the ICANN suffixes of the Public Suffix List, the name
+	// registries know. A site on a hosting suffix like github.io reports the domain of the host.
+	Domain        string `json:"domain"`
+	UnicodeDomain string `json:"unicode_domain"`
+	// Suffix is the public suffix of Domain, like co.ir
+	Suffix string `json:"suffix"`
+}
+
+// NormalizeURL returns the identity of a url or a bare domain, https is assumed without a scheme
+func NormalizeURL(raw string) (*SiteIdentity, error) {
+	u, err := ParseSiteURL(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	host := u.Hostname()
+	if net.ParseIP(host) != nil || !strings.Contains(host, ".") {
+		return nil, fmt.Errorf("%w: %q has no domain name", ErrInvalidURL, host)
+	}
+	suffix := icannSuffix(host)
+	if host == suffix {
+		return nil, fmt.Errorf("%w: %q is a public suffix", ErrInvalidURL, host)
+	}
+	labels := strings.Split(strings.TrimSuffix(host, "."+suffix), ".")
+	domain := labels[len(labels)-1] + "." + suffix
+
+	siteHost := strings.TrimPrefix(host, "www.")
+
+	return &SiteIdentity{
+		URL:           u.String(),
+		Key:           siteHost,
+		Host:          host,
+		UnicodeHost:   toUnicode(host),
+		SiteHost:      siteHost,
+		Domain:        domain,
+		UnicodeDomain: toUnicode(domain),
+		Suffix:        suffix,
+	}, nil
+}
+
+// ParseSiteURL parses a url or a bare host like NormalizeURL, https is assumed without a scheme
+// and the host is lower case punycode, but addresses and single labels are kept. Fetchers use it
+// for the urls they are handed, which NormalizeURL already checked or which come from the chain.
+func ParseSiteURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, fmt.Errorf("%w: empty url", ErrInvalidURL)
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return nil, fmt.Errorf("%w: unsupported scheme %q", ErrInvalidURL, u.Scheme)
+	}
+
+	host := strings.TrimSuffix(u.Hostname(), ".")
+	if net.ParseIP(host) == nil {
+		host, err = idna.Lookup.ToASCII(host)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+		}
+	}
+	if host == "" {
+		return nil, fmt.Errorf("%w: no host", ErrInvalidURL)
+	}
+
+	// the default port of the scheme names the same site as no port
+	hostPort := host
+	if strings.Contains(host, ":") {
+		hostPort = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" && !(scheme == "https" && port == "443") && !(scheme == "http" && port == "80") {
+		hostPort = net.JoinHostPort(host, port)
+	}
+
+	canonical := &url.URL{Scheme: scheme, Host: hostPort, Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery}
+	if canonical.Path == "" {
+		canonical.Path = "/"
+	}
+	return canonical, nil
+}
+
+// icannSuffix is the public suffix of the punycode host under the ICANN section of the list,
+// the private suffixes of hosting companies are skipped
+func icannSuffix(host string) string {
+	suffix, icann := publicsuffix.PublicSuffix(host)
+	for !icann {
+		dot := strings.IndexByte(suffix, '.')
+		if dot < 0 {
+			break
+		}
+		suffix, icann = publicsuffix.PublicSuffix(suffix[dot+1:])
+	}
+	return suffix
+}
+
+func toUnicode(host string) string {
+	unicodeHost, err := idna.ToUnicode(host)
+	if err != nil {
+		return host
+	}
+	return unicodeHost
+}
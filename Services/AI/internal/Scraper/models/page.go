@@ -0,0 +1,48 @@
+package models
+
+// FormInput is one input field of a form
+type FormInput struct {
+	Name         string `json:"name,omitempty"`
+	ID           string `json:"id,omitempty"`
+	Type         string `json:"type"`
+	Placeholder  string `json:"placeholder,omitempty"`
+	Autocomplete string `json:"autocomplete,omitempty"`
+	MaxLength    int    `json:"max_length,omitempty"`
+}
+
+// FormResult is a form found on a page
+type FormResult struct {
+	// Action is the absolute url the form posts to
+	Action string      `json:"action"`
+	Method string      `json:"method"`
+	Inputs []FormInput `json:"inputs"`
+}
+
+// PageResult is what was found on a single crawled page
+type PageResult struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	Secure     bool   `json:"secure"`
+	Title      string `json:"title"`
+	// LogoTexts are the alt texts and file names of the logo images
+	LogoTexts      []string     `json:"logo_texts"`
+	Keywords       []string     `json:"keywords"`
+	HiddenElements []string     `json:"hidden_elements"`
+	Forms          []FormResult `json:"forms"`
+	ContactLinks   []string     `json:"contact_links"`
+	// FormFindings are the forms harvesting credentials or card details
+	FormFindings []FormFinding `json:"form_findings"`
+	// Headers grades the security headers of the response
+	Headers *HeaderAudit `json:"headers,omitempty"`
+	// TrustSeals are the Enamad and Samandehi badges shown on the page
+	TrustSeals []TrustSeal `json:"trust_seals"`
+	// BusinessIDs are the national ids of legal entities written on the page
+	BusinessIDs []string `json:"business_ids"`
+}
+
+// PageError is a page the crawler failed to fetch
+type PageError struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error"`
+}
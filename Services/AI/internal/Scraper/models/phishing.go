@@ -0,0 +1,228 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Kinds of FormFinding
+const (
+	FormPasswordInput      = "password_input"
+	FormCardInput          = "card_input"
+	FormOTPInput           = "otp_input"
+	FormCrossDomain        = "cross_domain_action"
+	FormInsecureAction     = "insecure_action"
+	FormMailtoAction       = "mailto_action"
+	FormSuspiciousEndpoint = "suspicious_endpoint"
+	FormBankCard           = "bank_card_form"
+	FormShaparakLookalike  = "shaparak_lookalike"
+)
+
+// shaparakDomain is the only domain the Iranian payment gateways are served from
+const shaparakDomain = "shaparak.ir"
+
+// FormFinding is a form that looks like it harvests credentials or card details
+type FormFinding struct {
+	Page     string `json:"page"`
+	Action   string `json:"action"`
+	Kind     string `json:"kind"`
+	Evidence string `json:"evidence"`
+}
+
+var (
+	// the hints are matched against the name, id, placeholder and autocomplete of an input
+	cardHints   = []string{"card", "cc-number", "کارت"}
+	cvvHints    = []string{"cvv", "cvc", "cc-csc", "کد امنیتی"}
+	expiryHints = []string{"expir", "exp_", "exp-", "cc-exp", "انقضا"}
+	pin2Hints   = []string{"pin2", "رمز دوم", "رمز اینترنتی"}
+	otpHints    = []string{"otp", "one-time-code", "totp", "verification", "رمز پویا", "کد تایید", "کد یکبار"}
+)
+
+// DetectPhishingForms reports the forms of pageURL asking for credentials or card details
+// and the places they are sent to. text is the lower case visible text of the page.
+func (fi *FraudIndicators) DetectPhishingForms(pageURL *url.URL, text string, forms []FormResult) []FormFinding {
+	findings := []FormFinding{}
+
+	for _, form := range forms {
+		add := func(kind, evidence string) {
+			findings = append(findings, FormFinding{Page: pageURL.String(), Action: form.Action, Kind: kind, Evidence: evidence})
+		}
+
+		fields := classifyInputs(form.Inputs)
+		if len(fields.password) > 0 {
+			add(FormPasswordInput, "asks for a password in "+strings.Join(fields.password, ", "))
+		}
+		if len(fields.card) > 0 {
+			add(FormCardInput, "asks for a card number in "+strings.Join(fields.card, ", "))
+		}
+		if len(fields.otp) > 0 {
+			add(FormOTPInput, "asks for a one time code in "+strings.Join(fields.otp, ", "))
+		}
+
+		bankCard := len(fields.card) > 0 && (len(fields.cvv) > 0 || len(fields.expiry) > 0 || len(fields.pin2) > 0)
+		if bankCard {
+			add(FormBankCard, fmt.Sprintf("asks for the card number with %d cvv2, %d expiry and %d second password fields",
+				len(fields.cvv), len(fields.expiry), len(fields.pin2)))
+		}
+
+		action := pageURL
+		if form.Action != "" {
+			parsed, err := url.Parse(form.Action)
+			if err == nil {
+				action = parsed
+			}
+		}
+		sensitive := len(fields.password) > 0 || len(fields.card) > 0 || len(fields.otp) > 0
+
+		switch strings.ToLower(action.Scheme) {
+		case "mailto":
+			add(FormMailtoAction, "form is sent by email to "+action.Opaque)
+			continue
+		case "http":
+			if sensitive {
+				add(FormInsecureAction, "sensitive fields are sent without https to "+action.String())
+			}
+		}
+
+		actionHost := strings.ToLower(action.Hostname())
+		if sensitive && actionHost != "" && !sameSite(actionHost, pageURL.Hostname()) && !underDomain(actionHost, shaparakDomain) {
+			add(FormCrossDomain, fmt.Sprintf("sensitive fields are sent from %s to %s", pageURL.Hostname(), actionHost))
+		}
+
+		if net.ParseIP(actionHost) != nil {
+			add(FormSuspiciousEndpoint, "form is sent to the ip address "+actionHost)
+		}
+		lowerAction := strings.ToLower(action.String())
+		for _, endpoint := range fi.SuspiciousFormEndpoints {
+			if strings.Contains(lowerAction, strings.ToLower(endpoint)) {
+				add(FormSuspiciousEndpoint, "form is sent to "+endpoint)
+			}
+		}
+
+		if bankCard {
+			if evidence := shaparakLookalike(pageURL, action, text); evidence != "" {
+				add(FormShaparakLookalike, evidence)
+			}
+		}
+	}
+
+	return findings
+}
+
+// formFields are the names of the sensitive inputs of a form
+type formFields struct {
+	password, card, cvv, expiry, pin2, otp []string
+}
+
+func classifyInputs(inputs []FormInput) formFields {
+	var fields formFields
+	var splitCard []string
+
+	for _, input := range inputs {
+		if input.Type == "hidden" || input.Type == "submit" || input.Type == "button" {
+			continue
+		}
+		label := input.label()
+		// the length of a text input says nothing, a username or a postal code can be 16 or 4 long
+		numeric := input.Type == "tel" || input.Type == "number"
+		hint := strings.ToLower(strings.Join([]string{input.Name, input.ID, input.Placeholder, input.Autocomplete}, " "))
+
+		switch {
+		case matchesAny(hint, otpHints):
+			fields.otp = append(fields.otp, label)
+		case matchesAny(hint, cvvHints):
+			fields.cvv = append(fields.cvv, label)
+		case matchesAny(hint, expiryHints):
+			fields.expiry = append(fields.expiry, label)
+		case matchesAny(hint, pin2Hints):
+			fields.pin2 = append(fields.pin2, label)
+		case matchesAny(hint, cardHints), numeric && (input.MaxLength == 16 || input.MaxLength == 19):
+			fields.card = append(fields.card, label)
+		case input.Type == "password":
+			fields.password = append(fields.password, label)
+		case numeric && input.MaxLength == 4:
+			// gateways often split the 16 digits of the card over four inputs
+			splitCard = append(splitCard, label)
+		}
+	}
+
+	if len(splitCard) >= 4 {
+		fields.card = append(fields.card, splitCard...)
+	}
+	return fields
+}
+
+func (in FormInput) label() string {
+	for _, label := range []string{in.Name, in.ID, in.Placeholder} {
+		if label != "" {
+			return label
+		}
+	}
+	return in.Type
+}
+
+// shaparakLookalike explains why a payment form outside shaparak.ir pretends to be a gateway
+func shaparakLookalike(pageURL, action *url.URL, text string) string {
+	for _, u := range []*url.URL{pageURL, action} {
+		host := strings.ToLower(u.Hostname())
+		if host == "" || underDomain(host, shaparakDomain) {
+			continue
+		}
+		for _, label := range strings.Split(host, ".") {
+			if strings.Contains(label, "shaparak") || (label != "" && levenshtein(label, "shaparak") <= 2) {
+				return fmt.Sprintf("%s imitates the %s payment gateway domain", host, shaparakDomain)
+			}
+		}
+	}
+
+	if !underDomain(strings.ToLower(pageURL.Hostname()), shaparakDomain) &&
+		(strings.Contains(text, "shaparak") || strings.Contains(text, "شاپرک")) {
+		return "a card form presented as a Shaparak gateway outside " + shaparakDomain
+	}
+	return ""
+}
+
+// sameSite reports whether a and b are the same host or one is a subdomain of the other
+func sameSite(a, b string) bool {
+	a = strings.TrimPrefix(strings.ToLower(a), "www.")
+	b = strings.TrimPrefix(strings.ToLower(b), "www.")
+	return a == b || underDomain(a, b) || underDomain(b, a)
+}
+
+// underDomain reports whether host is domain or one of its subdomains
+func underDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func matchesAny(s string, hints []string) bool {
+	for _, hint := range hints {
+		if strings.Contains(s, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// levenshtein is the edit distance between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
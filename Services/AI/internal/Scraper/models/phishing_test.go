@@ -0,0 +1,139 @@
+package models
+
+import (
+	"net/url"
+	"sort"
+	"testing"
+)
+
+func findingKinds(findings []FormFinding) []string {
+	kinds := []string{}
+	for _, f := range findings {
+		kinds = append(kinds, f.Kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func hasKind(findings []FormFinding, kind string) bool {
+	for _, f := range findings {
+		if f.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDetectPhishingForms(t *testing.T) {
+	fi := NewDefaultFraudIndicators()
+	page, _ := url.Parse("https://example.com/login")
+
+	tests := []struct {
+		name  string
+		url   *url.URL
+		text  string
+		form  FormResult
+		want  []string
+		avoid []string
+	}{
+		{
+			name: "login posting to its own site",
+			url:  page,
+			form: FormResult{Action: "https://www.example.com/session", Inputs: []FormInput{{Name: "user", Type: "text"}, {Name: "pass", Type: "password"}}},
+			want: []string{FormPasswordInput},
+		},
+		{
+			name: "login posting elsewhere over http",
+			url:  page,
+			form: FormResult{Action: "http://collector.example.net/next.php", Inputs: []FormInput{{Name: "pass", Type: "password"}}},
+			want: []string{FormCrossDomain, FormInsecureAction, FormPasswordInput, FormSuspiciousEndpoint},
+		},
+		{
+			name: "mailto action",
+			url:  page,
+			form: FormResult{Action: "mailto:thief@example.net", Inputs: []FormInput{{Name: "email", Type: "email"}}},
+			want: []string{FormMailtoAction},
+		},
+		{
+			name: "telegram bot endpoint",
+			url:  page,
+			form: FormResult{Action: "https://api.telegram.org/bot1/sendMessage", Inputs: []FormInput{{Name: "code", Autocomplete: "one-time-code", Type: "text"}}},
+			want: []string{FormCrossDomain, FormOTPInput, FormSuspiciousEndpoint},
+		},
+		{
+			name: "split card number on a shaparak lookalike",
+			url:  mustParse(t, "https://shaparak-pay.ir/pay"),
+			form: FormResult{Action: "https://shaparak-pay.ir/pay", Inputs: []FormInput{
+				{Name: "c1", Type: "tel", MaxLength: 4}, {Name: "c2", Type: "tel", MaxLength: 4},
+				{Name: "c3", Type: "tel", MaxLength: 4}, {Name: "c4", Type: "tel", MaxLength: 4},
+				{Name: "cvv2", Type: "tel"}, {Name: "pin2", Type: "password"},
+			}},
+			want: []string{FormBankCard, FormCardInput, FormShaparakLookalike},
+		},
+		{
+			name:  "real gateway",
+			url:   mustParse(t, "https://sep.shaparak.ir/payment"),
+			text:  "شاپرک",
+			form:  FormResult{Action: "https://sep.shaparak.ir/payment", Inputs: []FormInput{{Name: "pan", Type: "tel", MaxLength: 19}, {Name: "cvv2", Type: "tel"}}},
+			want:  []string{FormBankCard, FormCardInput},
+			avoid: []string{FormShaparakLookalike, FormCrossDomain},
+		},
+		{
+			name: "merchant posting the card to the gateway",
+			url:  mustParse(t, "https://shop.ir/checkout"),
+			text: "پرداخت از طریق درگاه شاپرک",
+			form: FormResult{Action: "https://sep.shaparak.ir/payment", Inputs: []FormInput{{Name: "card_number", Type: "text"}, {Name: "exp_month", Type: "text"}}},
+			want: []string{FormBankCard, FormCardInput, FormShaparakLookalike},
+		},
+		{
+			name: "text inputs of card lengths",
+			url:  page,
+			form: FormResult{Action: "https://www.example.com/register", Inputs: []FormInput{
+				{Name: "username", Type: "text", MaxLength: 16}, {Name: "postal_code", Type: "text", MaxLength: 19},
+				{Name: "year", Type: "text", MaxLength: 4}, {Name: "month", Type: "text", MaxLength: 4},
+				{Name: "day", Type: "text", MaxLength: 4}, {Name: "pin", Type: "text", MaxLength: 4},
+			}},
+			want: []string{},
+		},
+		{
+			name: "newsletter form",
+			url:  page,
+			form: FormResult{Action: "https://list.example.net/subscribe", Inputs: []FormInput{{Name: "email", Type: "email"}}},
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		got := fi.DetectPhishingForms(tt.url, tt.text, []FormResult{tt.form})
+		kinds := findingKinds(got)
+		if len(kinds) != len(tt.want) {
+			t.Errorf("%s: got %v, wanted %v", tt.name, kinds, tt.want)
+			continue
+		}
+		for i := range kinds {
+			if kinds[i] != tt.want[i] {
+				t.Errorf("%s: got %v, wanted %v", tt.name, kinds, tt.want)
+				break
+			}
+		}
+		for _, kind := range tt.avoid {
+			if hasKind(got, kind) {
+				t.Errorf("%s: did not expect %s in %v", tt.name, kind, kinds)
+			}
+		}
+		for _, f := range got {
+			if f.Evidence == "" || f.Page != tt.url.String() {
+				t.Errorf("%s: got finding %+v", tt.name, f)
+			}
+		}
+	}
+}
+
+func mustParse(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("url.Parse returned an error: %v", err)
+	}
+	return u
+}
@@ -0,0 +1,290 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Kinds of RedirectHop
+const (
+	RedirectHTTP        = "http"
+	RedirectMetaRefresh = "meta_refresh"
+	RedirectJavaScript  = "javascript"
+)
+
+// cloakingSimilarity is the share of words two fetches of a page must have in common,
+// below it the content served depends on who asks
+const cloakingSimilarity = 0.5
+
+// cloakingMinWords is the smallest page the text comparison is meaningful for
+const cloakingMinWords = 20
+
+// jsRedirectPatterns catch scripts sending the browser to another url
+var jsRedirectPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?:\b(?:window|document|top|self)\.)?\blocation(?:\.href)?\s*=\s*["']([^"']+)["']`),
+	regexp.MustCompile(`\blocation\.(?:replace|assign)\(\s*["']([^"']+)["']\s*\)`),
+}
+
+// loadHandlerPattern matches the code opening a block that runs as the page loads: a load
+// handler, a timer, a jQuery ready callback or a try block
+var loadHandlerPattern = regexp.MustCompile(`(?:(?:\bonload\s*=|\baddEventListener\(\s*["'](?:load|DOMContentLoaded)["']\s*,|\bsetTimeout\(|\bready\(|\$\()\s*(?:function\s*\w*\s*\([^)]*\)|\([^)]*\)\s*=>|\w+\s*=>)|\btry)\s*$`)
+
+// RedirectHop is one response on the way to the final page
+type RedirectHop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	// Kind is how this response sent the client on, empty for the final page
+	Kind        string `json:"kind,omitempty"`
+	Location    string `json:"location,omitempty"`
+	CrossDomain bool   `json:"cross_domain"`
+}
+
+// RedirectChain is every hop from the scanned url to the page finally shown
+type RedirectChain struct {
+	Start           string        `json:"start"`
+	Final           string        `json:"final"`
+	FinalStatus     int           `json:"final_status"`
+	Hops            []RedirectHop `json:"hops"`
+	CrossDomainHops int           `json:"cross_domain_hops"`
+	// Domains are the hosts passed through, in order
+	Domains []string `json:"domains"`
+	// PossibleRedirects are the urls scripts on the way only go to on a condition or an event,
+	// like a click. They are not followed and do not count as hops.
+	PossibleRedirects []string `json:"possible_redirects"`
+	Error             string   `json:"error,omitempty"`
+}
+
+// NewRedirectChain starts an empty chain at start
+func NewRedirectChain(start string) *RedirectChain {
+	return &RedirectChain{Start: start, Hops: []RedirectHop{}, Domains: []string{}, PossibleRedirects: []string{}}
+}
+
+// AddPossible records the possible script redirects of the page at hopURL
+func (c *RedirectChain) AddPossible(hopURL *url.URL, locations []string) {
+	for _, location := range locations {
+		if next, err := hopURL.Parse(location); err == nil {
+			c.PossibleRedirects = append(c.PossibleRedirects, next.String())
+		}
+	}
+}
+
+// AddHop records a response, location is where it sends the client next, empty for the final page
+func (c *RedirectChain) AddHop(hopURL *url.URL, statusCode int, kind, location string) {
+	hop := RedirectHop{URL: hopURL.String(), StatusCode: statusCode, Kind: kind}
+	host := strings.ToLower(hopURL.Hostname())
+
+	if len(c.Domains) == 0 || c.Domains[len(c.Domains)-1] != host {
+		c.Domains = append(c.Domains, host)
+	}
+	if location != "" {
+		hop.Location = location
+		if next, err := hopURL.Parse(location); err == nil && !sameSite(next.Hostname(), host) {
+			hop.CrossDomain = true
+			c.CrossDomainHops++
+		}
+	}
+
+	c.Hops = append(c.Hops, hop)
+	c.Final = hop.URL
+	c.FinalStatus = statusCode
+}
+
+// Redirects is the number of hops before the final page
+func (c *RedirectChain) Redirects() int {
+	if len(c.Hops) == 0 {
+		return 0
+	}
+	return len(c.Hops) - 1
+}
+
+// DetectHTMLRedirect returns the url a page sends the browser to with a meta refresh
+// or a script, and which of the two it is. A script only redirects when the assignment
+// runs as the page loads, the urls of the other assignments are returned as possible.
+func DetectHTMLRedirect(body []byte) (string, string, []string) {
+	possible := []string{}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return "", "", possible
+	}
+
+	location, kind := "", ""
+	doc.Find("meta").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if !strings.EqualFold(strings.TrimSpace(s.AttrOr("http-equiv", "")), "refresh") {
+			return true
+		}
+		location = metaRefreshURL(s.AttrOr("content", ""))
+		return location == ""
+	})
+	if location != "" {
+		kind = RedirectMetaRefresh
+	}
+
+	doc.Find("script").Each(func(i int, s *goquery.Selection) {
+		code := s.Text()
+		for _, pattern := range jsRedirectPatterns {
+			for _, m := range pattern.FindAllStringSubmatchIndex(code, -1) {
+				target := code[m[2]:m[3]]
+				if location == "" && runsOnLoad(code[:m[0]]) {
+					location, kind = target, RedirectJavaScript
+					continue
+				}
+				if target != location {
+					possible = append(possible, target)
+				}
+			}
+		}
+	})
+	return location, kind, possible
+}
+
+// runsOnLoad reports whether code following before runs as the page loads: at the top level
+// of the script or only inside load handlers
+func runsOnLoad(before string) bool {
+	for _, brace := range openBlocks(before) {
+		opener := before[max(0, brace-200):brace]
+		if !loadHandlerPattern.MatchString(opener) {
+			return false
+		}
+	}
+	return true
+}
+
+// openBlocks returns the positions of the braces code leaves open, braces in strings and
+// comments are skipped
+func openBlocks(code string) []int {
+	var open []int
+	var quote byte
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case strings.HasPrefix(code[i:], "//"):
+			if end := strings.IndexByte(code[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(code)
+			}
+		case strings.HasPrefix(code[i:], "/*"):
+			if end := strings.Index(code[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(code)
+			}
+		case c == '{':
+			open = append(open, i)
+		case c == '}':
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return open
+}
+
+// metaRefreshURL reads the url of a refresh content like "0; url=https://example.com"
+func metaRefreshURL(content string) string {
+	_, rest, ok := strings.Cut(content, ";")
+	if !ok {
+		return ""
+	}
+	rest = strings.TrimSpace(rest)
+	if len(rest) < 4 || !strings.EqualFold(rest[:4], "url=") {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(rest[4:]), `"'`)
+}
+
+// PageText returns the lower case visible words of a page
+func PageText(body []byte) []string {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	doc.Find("script, style, noscript").Remove()
+	return strings.Fields(strings.ToLower(doc.Text()))
+}
+
+// TextSimilarity is the Jaccard index of the word sets of a and b, 1 when both are empty
+func TextSimilarity(a, b []string) float64 {
+	setA := make(map[string]bool, len(a))
+	for _, w := range a {
+		setA[w] = true
+	}
+	setB := make(map[string]bool, len(b))
+	for _, w := range b {
+		setB[w] = true
+	}
+	if len(setA) == 0 && len(setB) == 0 {
+		return 1
+	}
+
+	shared := 0
+	for w := range setA {
+		if setB[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(setA)+len(setB)-shared)
+}
+
+// CloakingResult compares the page served to a crawler with the one served to a browser
+type CloakingResult struct {
+	CrawlerFinal  string   `json:"crawler_final"`
+	BrowserFinal  string   `json:"browser_final"`
+	CrawlerStatus int      `json:"crawler_status"`
+	BrowserStatus int      `json:"browser_status"`
+	Similarity    float64  `json:"similarity"`
+	Cloaked       bool     `json:"cloaked"`
+	Evidence      []string `json:"evidence"`
+}
+
+// CompareFetches flags cloaking when the crawler and the browser end up on different
+// sites, get different statuses or read mostly different text
+func CompareFetches(crawler *RedirectChain, crawlerText []string, browser *RedirectChain, browserText []string) *CloakingResult {
+	result := &CloakingResult{
+		CrawlerFinal:  crawler.Final,
+		BrowserFinal:  browser.Final,
+		CrawlerStatus: crawler.FinalStatus,
+		BrowserStatus: browser.FinalStatus,
+		Similarity:    TextSimilarity(crawlerText, browserText),
+		Evidence:      []string{},
+	}
+	// a fetch that failed says nothing about what the site serves
+	if crawler.Error != "" || browser.Error != "" {
+		return result
+	}
+
+	crawlerHost, browserHost := hostOf(crawler.Final), hostOf(browser.Final)
+	if crawlerHost != "" && browserHost != "" && !sameSite(crawlerHost, browserHost) {
+		result.Evidence = append(result.Evidence, fmt.Sprintf("crawlers end on %s but browsers on %s", crawlerHost, browserHost))
+	}
+	if (crawler.FinalStatus < 400) != (browser.FinalStatus < 400) {
+		result.Evidence = append(result.Evidence, fmt.Sprintf("crawlers get status %d but browsers %d", crawler.FinalStatus, browser.FinalStatus))
+	}
+	if len(crawlerText) >= cloakingMinWords || len(browserText) >= cloakingMinWords {
+		if result.Similarity < cloakingSimilarity {
+			result.Evidence = append(result.Evidence, fmt.Sprintf("only %.0f%% of the words are shared between the crawler and browser pages", result.Similarity*100))
+		}
+	}
+
+	result.Cloaked = len(result.Evidence) > 0
+	return result
+}
+
+// RedirectReport is the redirect chain seen by a browser and the cloaking comparison
+type RedirectReport struct {
+	Chain    *RedirectChain  `json:"chain"`
+	Cloaking *CloakingResult `json:"cloaking"`
+}
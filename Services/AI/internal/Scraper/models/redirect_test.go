@@ -0,0 +1,58 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectHTMLRedirect(t *testing.T) {
+	tests := []struct {
+		body     string
+		location string
+		kind     string
+		possible []string
+	}{
+		{`<meta http-equiv="refresh" content="3;URL='https://evil.example/'">`, "https://evil.example/", RedirectMetaRefresh, []string{}},
+		{`<meta http-equiv="refresh" content="30">`, "", "", []string{}},
+		{`<script>setTimeout(function(){ location.replace("/next") }, 10)</script>`, "/next", RedirectJavaScript, []string{}},
+		{`<script>document.location = 'https://evil.example'</script>`, "https://evil.example", RedirectJavaScript, []string{}},
+		{`<script>window.onload = function() { window.location.href = "https://evil.example" }</script>`, "https://evil.example", RedirectJavaScript, []string{}},
+		{`<script>document.addEventListener("DOMContentLoaded", () => { location.assign("/next") })</script>`, "/next", RedirectJavaScript, []string{}},
+		{`<script>var geolocation = "tehran"</script>`, "", "", []string{}},
+		// text outside scripts is not run
+		{`<p>window.location = "https://evil.example"</p>`, "", "", []string{}},
+		// handlers of clicks, conditions and functions may never run
+		{`<script>document.getElementById("buy").onclick = function() { location.href = "https://pay.example/checkout" }</script>`, "", "", []string{"https://pay.example/checkout"}},
+		{`<script>if (navigator.userAgent.indexOf("Mobile") > -1) { location.href = "https://m.example" } // {</script>`, "", "", []string{"https://m.example"}},
+		{`<script>function search() { location.href = "/search?q={" } location.replace("/home")</script>`, "/home", RedirectJavaScript, []string{"/search?q={"}},
+	}
+
+	for _, tt := range tests {
+		location, kind, possible := DetectHTMLRedirect([]byte(tt.body))
+		if location != tt.location || kind != tt.kind || !reflect.DeepEqual(possible, tt.possible) {
+			t.Errorf("DetectHTMLRedirect(%q) got %q %q %q, wanted %q %q %q", tt.body, location, kind, possible, tt.location, tt.kind, tt.possible)
+		}
+	}
+}
+
+func TestCompareFetches(t *testing.T) {
+	page := PageText([]byte("<html><body>" + "one two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty" + "</body></html>"))
+	chain := func(final string, status int) *RedirectChain {
+		return &RedirectChain{Final: final, FinalStatus: status}
+	}
+
+	same := CompareFetches(chain("https://shop.ir/", 200), page, chain("https://www.shop.ir/", 200), page)
+	if same.Cloaked || same.Similarity != 1 {
+		t.Errorf("got %+v for identical pages", same)
+	}
+
+	moved := CompareFetches(chain("https://shop.ir/", 200), page, chain("https://evil.example/", 200), page)
+	if !moved.Cloaked {
+		t.Errorf("got %+v for a browser sent elsewhere", moved)
+	}
+
+	failed := &RedirectChain{Error: "timeout"}
+	if got := CompareFetches(failed, nil, chain("https://shop.ir/", 200), page); got.Cloaked {
+		t.Errorf("got %+v when a fetch failed", got)
+	}
+}
@@ -0,0 +1,293 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Registries a site can be looked up in
+const (
+	RegistryEnamad     = "enamad"
+	RegistrySamandehi  = "samandehi"
+	RegistryBusinessID = "business_id"
+)
+
+// Statuses of a RegistryResult
+const (
+	// RegistryRegistered is a registration the registry confirmed for the site
+	RegistryRegistered = "registered"
+	// RegistryDeclared is a registration the site states but no registry confirmed
+	RegistryDeclared      = "declared"
+	RegistryNotRegistered = "not_registered"
+	RegistryExpired       = "expired"
+	// RegistryInvalid is a registration the site claims that does not hold
+	RegistryInvalid     = "invalid"
+	RegistryUnavailable = "unavailable"
+)
+
+// RegistryQuery is what the registry checkers know about the scanned site
+type RegistryQuery struct {
+	Site string
+	// Seals are the trust badges of the crawled pages, they carry the registry ids
+	Seals []TrustSeal
+	// BusinessIDs are the national ids written on the crawled pages
+	BusinessIDs []string
+}
+
+// Samandehi_Data is the registration of a site at samandehi.ir, read from its verify page
+type Samandehi_Data struct {
+	ID      string   `json:"id"`
+	Domains []string `json:"domains"`
+	Name    string   `json:"name,omitempty"`
+	Owner   string   `json:"owner,omitempty"`
+	Status  string   `json:"status,omitempty"`
+	ExpDate string   `json:"expdate,omitempty"`
+	// ExpiresAt is ExpDate read from the solar calendar
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// BusinessID_Data is a national id of a legal entity written on the site
+type BusinessID_Data struct {
+	NationalID string `json:"national_id"`
+	// ValidChecksum is set when the control digit of the id is right
+	ValidChecksum bool `json:"valid_checksum"`
+}
+
+// RegistryResult is the answer of one registry, only the data of that registry is set
+type RegistryResult struct {
+	Registry string   `json:"registry"`
+	Status   string   `json:"status"`
+	Evidence []string `json:"evidence"`
+	Error    string   `json:"error,omitempty"`
+
+	Enamad      *Enamad_Data      `json:"enamad,omitempty"`
+	Samandehi   []Samandehi_Data  `json:"samandehi,omitempty"`
+	BusinessIDs []BusinessID_Data `json:"business_ids,omitempty"`
+}
+
+// ComplianceReport combines the registries the site was looked up in
+type ComplianceReport struct {
+	Registries []RegistryResult `json:"registries"`
+	// Compliant is set when a registry confirmed a valid registration of the site
+	Compliant bool `json:"compliant"`
+	// Registered lists the registries that confirmed the site
+	Registered []string `json:"registered"`
+	// Issues are the registrations the site claims that do not hold
+	Issues []string `json:"issues"`
+}
+
+// NewComplianceReport combines results, in the order of the registry names
+func NewComplianceReport(results []RegistryResult) *ComplianceReport {
+	report := &ComplianceReport{Registries: results, Registered: []string{}, Issues: []string{}}
+	sort.SliceStable(report.Registries, func(i, j int) bool {
+		return report.Registries[i].Registry < report.Registries[j].Registry
+	})
+	for _, r := range report.Registries {
+		switch r.Status {
+		case RegistryRegistered:
+			report.Compliant = true
+			report.Registered = append(report.Registered, r.Registry)
+		case RegistryInvalid:
+			report.Issues = append(report.Issues, r.Evidence...)
+		}
+	}
+	return report
+}
+
+// Result returns the result of registry, nil when it was not looked up
+func (c *ComplianceReport) Result(registry string) *RegistryResult {
+	if c == nil {
+		return nil
+	}
+	for i := range c.Registries {
+		if c.Registries[i].Registry == registry {
+			return &c.Registries[i]
+		}
+	}
+	return nil
+}
+
+// EnamadResult turns an Enamad lookup into a RegistryResult, data is nil when err is set
+func EnamadResult(data *Enamad_Data, err error) RegistryResult {
+	result := RegistryResult{Registry: RegistryEnamad, Evidence: []string{}, Enamad: data}
+	switch {
+	case err != nil:
+		result.Status, result.Error = RegistryUnavailable, err.Error()
+	case !data.Registered():
+		result.Status = RegistryNotRegistered
+	case data.IsExpired:
+		result.Status = RegistryExpired
+		result.Evidence = append(result.Evidence, "the Enamad certificate expired on "+data.ExpDate)
+	default:
+		result.Status = RegistryRegistered
+		result.Evidence = append(result.Evidence, "Enamad certificate "+data.Code+" is valid, "+data.StarLevel)
+	}
+	return result
+}
+
+// samandehiLabels map the labels of the verify page to the fields of Samandehi_Data
+var samandehiLabels = []struct {
+	hints []string
+	field func(d *Samandehi_Data) *string
+}{
+	{[]string{"صاحب امتیاز", "مالک", "owner"}, func(d *Samandehi_Data) *string { return &d.Owner }},
+	{[]string{"وضعیت", "status"}, func(d *Samandehi_Data) *string { return &d.Status }},
+	{[]string{"انقضا", "اعتبار", "expir"}, func(d *Samandehi_Data) *string { return &d.ExpDate }},
+	{[]string{"نام", "عنوان", "name", "title"}, func(d *Samandehi_Data) *string { return &d.Name }},
+}
+
+// ParseSamandehiPage reads the registration of the Samandehi verify page of badge id. The
+// page is a table of labels and values, the domains are read from the whole page.
+func ParseSamandehiPage(id string, body []byte) (*Samandehi_Data, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	data := &Samandehi_Data{ID: id}
+	doc.Find("tr, dl").Each(func(i int, row *goquery.Selection) {
+		cells := row.Children().Filter("td, th, dt, dd")
+		if cells.Length() < 2 {
+			return
+		}
+		label := strings.ToLower(strings.TrimSpace(cells.First().Text()))
+		value := strings.TrimSpace(cells.Eq(1).Text())
+		// the domain field is read by certificateDomains, "نام دامنه" is no name
+		if matchesAny(label, domainLabels) {
+			return
+		}
+		for _, l := range samandehiLabels {
+			if field := l.field(data); *field == "" && matchesAny(label, l.hints) {
+				*field = value
+				return
+			}
+		}
+	})
+	data.Domains = certificateDomains(doc)
+	data.ExpiresAt = parseEnamadDate(data.ExpDate)
+
+	if len(data.Domains) == 0 && data.Name == "" {
+		return nil, fmt.Errorf("samandehi verify page of %s lists no registration", id)
+	}
+	return data, nil
+}
+
+// SamandehiResult rates the Samandehi registrations read from the badges of site, errs are
+// the verify pages that could not be read
+func SamandehiResult(site string, records []Samandehi_Data, errs []string, now time.Time) RegistryResult {
+	result := RegistryResult{Registry: RegistrySamandehi, Evidence: []string{}, Samandehi: records}
+	if len(records) == 0 {
+		if len(errs) > 0 {
+			result.Status, result.Error = RegistryUnavailable, strings.Join(errs, "; ")
+			return result
+		}
+		result.Status = RegistryNotRegistered
+		result.Evidence = append(result.Evidence, "the site shows no Samandehi badge")
+		return result
+	}
+
+	host := hostOf(site)
+	for _, r := range records {
+		for _, domain := range r.Domains {
+			if !sameSite(domain, host) {
+				continue
+			}
+			if r.ExpiresAt != nil && now.After(r.ExpiresAt.AddDate(0, 0, 1)) {
+				result.Status = RegistryExpired
+				result.Evidence = append(result.Evidence, fmt.Sprintf("the Samandehi registration %s expired on %s", r.ID, r.ExpDate))
+				return result
+			}
+			result.Status = RegistryRegistered
+			result.Evidence = append(result.Evidence, fmt.Sprintf("Samandehi registration %s names %s", r.ID, domain))
+			return result
+		}
+	}
+
+	for _, r := range records {
+		if len(r.Domains) > 0 {
+			result.Status = RegistryInvalid
+			result.Evidence = append(result.Evidence, fmt.Sprintf("the Samandehi registration %s belongs to %s, not %s", r.ID, strings.Join(r.Domains, ", "), host))
+			return result
+		}
+	}
+	// the registration exists but its page does not tell which site it is for
+	result.Status = RegistryDeclared
+	result.Evidence = append(result.Evidence, fmt.Sprintf("the Samandehi registration %s lists no domain", records[0].ID))
+	return result
+}
+
+// BusinessIDResult rates the national ids written on the site. There is no public registry
+// to confirm them, a well formed id is only declared.
+func BusinessIDResult(ids []string) RegistryResult {
+	result := RegistryResult{Registry: RegistryBusinessID, Evidence: []string{}, BusinessIDs: []BusinessID_Data{}}
+	if len(ids) == 0 {
+		result.Status = RegistryNotRegistered
+		result.Evidence = append(result.Evidence, "the site states no national business id")
+		return result
+	}
+
+	declared, invalid := []string{}, []string{}
+	for _, id := range ids {
+		valid := ValidBusinessID(id)
+		result.BusinessIDs = append(result.BusinessIDs, BusinessID_Data{NationalID: id, ValidChecksum: valid})
+		if valid {
+			declared = append(declared, fmt.Sprintf("the site states the well formed national id %s", id))
+		} else {
+			invalid = append(invalid, fmt.Sprintf("the national id %s stated on the site fails its checksum", id))
+		}
+	}
+	// the evidence of an invalid result becomes the issues of the compliance report
+	if len(invalid) > 0 {
+		result.Status, result.Evidence = RegistryInvalid, invalid
+	} else {
+		result.Status, result.Evidence = RegistryDeclared, declared
+	}
+	return result
+}
+
+// businessIDPattern finds national ids after their label, in Persian or English
+var businessIDPattern = regexp.MustCompile(`(?i)(?:شناسه[\s\x{200c}ٔی]*ملی|national[\s_-]*id)[^0-9]{0,20}([0-9]{11})\b`)
+
+// detectBusinessIDs lists the national ids of legal entities written in text
+func detectBusinessIDs(text string) []string {
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, m := range businessIDPattern.FindAllStringSubmatch(persianDigits.Replace(text), -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			ids = append(ids, m[1])
+		}
+	}
+	return ids
+}
+
+// businessIDWeights are the weights of the first ten digits of a national id
+var businessIDWeights = []int{29, 27, 23, 19, 17, 29, 27, 23, 19, 17}
+
+// ValidBusinessID checks the control digit of the 11 digit national id of a legal entity
+func ValidBusinessID(id string) bool {
+	if len(id) != 11 || strings.Trim(id, "0123456789") != "" || strings.Trim(id, "0") == "" {
+		return false
+	}
+	digits := make([]int, 11)
+	for i, r := range id {
+		digits[i] = int(r - '0')
+	}
+	// the tenth digit plus two is added to every digit before weighting
+	offset := digits[9] + 2
+	sum := 0
+	for i, weight := range businessIDWeights {
+		sum += (digits[i] + offset) * weight
+	}
+	control := sum % 11
+	if control == 10 {
+		control = 0
+	}
+	return control == digits[10]
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidBusinessID(t *testing.T) {
+	tests := map[string]bool{
+		"10320860530": true,
+		"14003778990": true,
+		"10320860531": false,
+		"00000000000": false,
+		"1032086053":  false,
+		"1032086053a": false,
+	}
+	for id, want := range tests {
+		if got := ValidBusinessID(id); got != want {
+			t.Errorf("ValidBusinessID(%q) got %v, wanted %v", id, got, want)
+		}
+	}
+}
+
+func TestDetectBusinessIDs(t *testing.T) {
+	text := "شرکت نمونه، شناسه ملی: ۱۰۳۲۰۸۶۰۵۳۰ ، کد پستی 1234567890. National ID 14003778990 and شناسه‌ی ملی 10320860530"
+	if got := detectBusinessIDs(text); !reflect.DeepEqual(got, []string{"10320860530", "14003778990"}) {
+		t.Errorf("got %v", got)
+	}
+
+	result := BusinessIDResult([]string{"10320860530", "10320860531"})
+	if result.Status != RegistryInvalid || len(result.Evidence) != 1 || len(result.BusinessIDs) != 2 {
+		t.Errorf("got %+v for a site with a wrong id", result)
+	}
+	if result := BusinessIDResult(nil); result.Status != RegistryNotRegistered {
+		t.Errorf("got %+v for a site without an id", result)
+	}
+}
+
+const samandehiPage = `<html><body><table>
+<tr><td>نام سایت</td><td>فروشگاه نمونه</td></tr>
+<tr><td>صاحب امتیاز</td><td>شرکت نمونه</td></tr>
+<tr><td>آدرس سایت</td><td>www.shop.ir</td></tr>
+<tr><td>وضعیت</td><td>فعال</td></tr>
+<tr><td>تاریخ انقضا</td><td>1405/01/01</td></tr>
+</table><a href="https://samandehi.ir">samandehi.ir</a></body></html>`
+
+func TestSamandehiResult(t *testing.T) {
+	data, err := ParseSamandehiPage("678", []byte(samandehiPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Name != "فروشگاه نمونه" || data.Owner != "شرکت نمونه" || data.Status != "فعال" || data.ExpiresAt == nil || !reflect.DeepEqual(data.Domains, []string{"shop.ir"}) {
+		t.Errorf("got %+v", data)
+	}
+	if _, err := ParseSamandehiPage("1", []byte("<html><body>not found</body></html>")); err == nil {
+		t.Errorf("got no error for an empty verify page")
+	}
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if r := SamandehiResult("https://www.shop.ir/cart", []Samandehi_Data{*data}, nil, now); r.Status != RegistryRegistered {
+		t.Errorf("got %+v for the registered site", r)
+	}
+	if r := SamandehiResult("https://shop-ir.top", []Samandehi_Data{*data}, nil, now); r.Status != RegistryInvalid {
+		t.Errorf("got %+v for a copied badge", r)
+	}
+	if r := SamandehiResult("shop.ir", []Samandehi_Data{*data}, nil, now.AddDate(2, 0, 0)); r.Status != RegistryExpired {
+		t.Errorf("got %+v after the expiry", r)
+	}
+	if r := SamandehiResult("shop.ir", nil, []string{"timeout"}, now); r.Status != RegistryUnavailable || r.Error == "" {
+		t.Errorf("got %+v when the verify page failed", r)
+	}
+}
+
+func TestNewComplianceReport(t *testing.T) {
+	report := NewComplianceReport([]RegistryResult{
+		BusinessIDResult([]string{"10320860531"}),
+		EnamadResult(&Enamad_Data{ID: 1, Code: "a"}, nil),
+		SamandehiResult("shop.ir", nil, nil, time.Now()),
+	})
+	if !report.Compliant || !reflect.DeepEqual(report.Registered, []string{RegistryEnamad}) || len(report.Issues) != 1 {
+		t.Errorf("got %+v", report)
+	}
+	if report.Registries[0].Registry != RegistryBusinessID || report.Result(RegistrySamandehi) == nil {
+		t.Errorf("got registries %+v", report.Registries)
+	}
+
+	input := RiskInputFromReport(nil, 4000, nil, nil, nil, report)
+	score := NewRiskEngine(nil).Evaluate(RiskInput{HasContactInfo: true, DomainAgeDays: 4000, HasEnamad: input.HasEnamad, RegistryIssues: input.RegistryIssues})
+	// the false national id is scored by its own rule, not as a missing certificate
+	if !input.HasEnamad || score.Score != 30 {
+		t.Errorf("got %+v, score %d for a false national id", input, score.Score)
+	}
+	if triggered := score.Triggered(); len(triggered) != 1 || triggered[0].Rule != RuleFalseRegistration {
+		t.Errorf("got triggered rules %+v for a false national id", triggered)
+	}
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// SiteSummary aggregates the findings of every crawled page
+type SiteSummary struct {
+	PagesCrawled int `json:"pages_crawled"`
+	PagesFailed  int `json:"pages_failed"`
+	// Secure is true when every crawled page was served over https
+	Secure         bool     `json:"secure"`
+	Titles         []string `json:"titles"`
+	LogoTexts      []string `json:"logo_texts"`
+	Keywords       []string `json:"keywords"`
+	HiddenElements []string `json:"hidden_elements"`
+	FormCount      int      `json:"form_count"`
+	HasContactInfo bool     `json:"has_contact_info"`
+	ContactLinks   []string `json:"contact_links"`
+	// InsecurePages are the crawled pages served over plain http
+	InsecurePages []string `json:"insecure_pages"`
+	// Headers is the header audit of the landing page, the first page crawled
+	Headers *HeaderAudit `json:"headers,omitempty"`
+	// FormFindings are the phishing forms of every page
+	FormFindings []FormFinding `json:"form_findings"`
+	// TrustSeals are the distinct trust badges of every page
+	TrustSeals []TrustSeal `json:"trust_seals"`
+	// BusinessIDs are the distinct national ids of every page
+	BusinessIDs []string `json:"business_ids"`
+	// Findings are the indicators above with how often and where they were seen
+	Findings []Finding `json:"findings"`
+}
+
+// Indicator kinds of a Finding
+const (
+	IndicatorKeyword     = "keyword"
+	IndicatorHidden      = "hidden_element"
+	IndicatorContactLink = "contact_link"
+	IndicatorPhishing    = "phishing_form"
+)
+
+// Finding is one indicator merged across the crawled pages
+type Finding struct {
+	Indicator string `json:"indicator"`
+	Value     string `json:"value"`
+	// Count is how many times it was found, a page can report it more than once
+	Count int      `json:"count"`
+	Pages []string `json:"pages"`
+}
+
+// ScrapeReport is the result of crawling a site
+type ScrapeReport struct {
+	Domain     string       `json:"domain"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+	Pages      []PageResult `json:"pages"`
+	Errors     []PageError  `json:"errors"`
+	Site       SiteSummary  `json:"site"`
+}
+
+// NewScrapeReport returns an empty report for domain
+func NewScrapeReport(domain string) *ScrapeReport {
+	return &ScrapeReport{
+		Domain:    domain,
+		StartedAt: time.Now(),
+		Pages:     []PageResult{},
+		Errors:    []PageError{},
+	}
+}
+
+// AddPage records a crawled page
+func (r *ScrapeReport) AddPage(page PageResult) {
+	r.Pages = append(r.Pages, page)
+}
+
+// AddError records a page that could not be fetched
+func (r *ScrapeReport) AddError(pageErr PageError) {
+	r.Errors = append(r.Errors, pageErr)
+}
+
+// Finish computes the site summary from the pages
+func (r *ScrapeReport) Finish() {
+	r.FinishedAt = time.Now()
+
+	site := SiteSummary{
+		PagesCrawled: len(r.Pages),
+		PagesFailed:  len(r.Errors),
+		Secure:       len(r.Pages) > 0,
+	}
+
+	site.InsecurePages = []string{}
+	site.FormFindings = []FormFinding{}
+	seals := []TrustSeal{}
+	findings := newFindingSet()
+	// titles and logos are not indicators, they are only kept as distinct values
+	branding := newFindingSet()
+	for _, page := range r.Pages {
+		if !page.Secure {
+			site.Secure = false
+			site.InsecurePages = append(site.InsecurePages, page.URL)
+		}
+		if page.Title != "" {
+			branding.add("title", page.URL, page.Title)
+		}
+		branding.add("logo", page.URL, page.LogoTexts...)
+		findings.add(IndicatorKeyword, page.URL, page.Keywords...)
+		findings.add(IndicatorHidden, page.URL, page.HiddenElements...)
+		findings.add(IndicatorContactLink, page.URL, page.ContactLinks...)
+		for _, f := range page.FormFindings {
+			findings.add(IndicatorPhishing, page.URL, f.Kind)
+		}
+		site.FormFindings = append(site.FormFindings, page.FormFindings...)
+		seals = append(seals, page.TrustSeals...)
+		branding.add("business_id", page.URL, page.BusinessIDs...)
+		site.FormCount += len(page.Forms)
+	}
+
+	if len(r.Pages) > 0 {
+		site.Headers = r.Pages[0].Headers
+	}
+	site.Titles = branding.values("title")
+	site.LogoTexts = branding.values("logo")
+	site.Keywords = findings.values(IndicatorKeyword)
+	site.HiddenElements = findings.values(IndicatorHidden)
+	site.ContactLinks = findings.values(IndicatorContactLink)
+	site.HasContactInfo = len(site.ContactLinks) > 0
+	site.TrustSeals = uniqueSeals(seals)
+	site.BusinessIDs = branding.values("business_id")
+	site.Findings = findings.sorted()
+
+	r.Site = site
+}
+
+// findingSet merges the findings of the pages, keyed by indicator and value
+type findingSet map[[2]string]*Finding
+
+func newFindingSet() findingSet {
+	return make(findingSet)
+}
+
+func (s findingSet) add(indicator, pageURL string, values ...string) {
+	for _, v := range values {
+		key := [2]string{indicator, v}
+		f, ok := s[key]
+		if !ok {
+			f = &Finding{Indicator: indicator, Value: v}
+			s[key] = f
+		}
+		f.Count++
+		if len(f.Pages) == 0 || f.Pages[len(f.Pages)-1] != pageURL {
+			f.Pages = append(f.Pages, pageURL)
+		}
+	}
+}
+
+// values returns the distinct values found for indicator, sorted
+func (s findingSet) values(indicator string) []string {
+	values := []string{}
+	for key := range s {
+		if key[0] == indicator {
+			values = append(values, key[1])
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// sorted returns the findings grouped by indicator, the most frequent first
+func (s findingSet) sorted() []Finding {
+	findings := make([]Finding, 0, len(s))
+	for _, f := range s {
+		findings = append(findings, *f)
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Indicator != b.Indicator {
+			return a.Indicator < b.Indicator
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Value < b.Value
+	})
+	return findings
+}
@@ -0,0 +1,397 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Rule names, also used as keys of the configurable weights
+const (
+	RuleSuspiciousKeywords = "suspicious_keywords"
+	RuleHiddenElements     = "hidden_elements"
+	RuleNoContactInfo      = "no_contact_info"
+	RuleInsecureConnection = "insecure_connection"
+	RuleYoungDomain        = "young_domain"
+	RuleNoEnamad           = "no_enamad"
+	RuleImpersonation      = "impersonation"
+	RuleForgedSeal         = "forged_seal"
+	RuleFalseRegistration  = "false_registration"
+)
+
+// DomainAgeUnknown marks a RiskInput whose registration date could not be determined
+const DomainAgeUnknown = -1
+
+// RiskInput is the normalized view of the scan findings the rules are evaluated on
+type RiskInput struct {
+	Keywords           []string `json:"keywords"`
+	HiddenElements     int      `json:"hidden_elements"`
+	HasContactInfo     bool     `json:"has_contact_info"`
+	UnsecureConnection bool     `json:"unsecure_connection"`
+	DomainAgeDays      int      `json:"domain_age_days"`
+	HasEnamad          bool     `json:"has_enamad"`
+	// EnamadExpired is set when the domain only holds an expired certificate
+	EnamadExpired bool `json:"enamad_expired"`
+	// EnamadUnknown is set when enamad.ir could not be asked, the domain may hold a certificate
+	EnamadUnknown bool `json:"enamad_unknown"`
+	// PagesCrawled and KeywordPages tell how widespread the keywords are on the site
+	PagesCrawled int `json:"pages_crawled"`
+	KeywordPages int `json:"keyword_pages"`
+	// Impersonation are the signs the site poses as a protected brand
+	Impersonation []ImpersonationFinding `json:"impersonation"`
+	// CertificateIssues are the problems of the TLS certificate of the site
+	CertificateIssues []string `json:"certificate_issues"`
+	// ForgedSeals is the evidence of the trust badges on the page not belonging to the site
+	ForgedSeals []string `json:"forged_seals"`
+	// HasSamandehi is set when samandehi.ir confirmed a registration of the site
+	HasSamandehi bool `json:"has_samandehi"`
+	// RegistryIssues are the registrations the site claims that do not hold
+	RegistryIssues []string `json:"registry_issues"`
+}
+
+// RiskInputFromReport builds the rule input from the crawl report, the domain age from
+// WHOIS (DomainAgeUnknown when missing), the impersonation findings, the TLS handshake,
+// the trust badge checks and the registry lookups, tlsReport and compliance may be nil
+// when they were not made
+func RiskInputFromReport(report *ScrapeReport, domainAgeDays int, impersonation []ImpersonationFinding, tlsReport *TLSReport, seals []SealVerification, compliance *ComplianceReport) RiskInput {
+	input := RiskInput{
+		Keywords:          []string{},
+		DomainAgeDays:     DomainAgeUnknown,
+		Impersonation:     []ImpersonationFinding{},
+		CertificateIssues: []string{},
+		ForgedSeals:       ForgedSealEvidence(seals),
+		RegistryIssues:    []string{},
+	}
+	if enamad := compliance.Result(RegistryEnamad); enamad != nil {
+		input.HasEnamad = enamad.Status == RegistryRegistered
+		input.EnamadExpired = enamad.Status == RegistryExpired
+		input.EnamadUnknown = enamad.Status == RegistryUnavailable
+	} else {
+		input.EnamadUnknown = true
+	}
+	if samandehi := compliance.Result(RegistrySamandehi); samandehi != nil {
+		input.HasSamandehi = samandehi.Status == RegistryRegistered
+	}
+	if compliance != nil {
+		input.RegistryIssues = append(input.RegistryIssues, compliance.Issues...)
+	}
+	if tlsReport != nil {
+		input.CertificateIssues = tlsReport.Issues()
+	}
+	input.Impersonation = append(input.Impersonation, impersonation...)
+
+	if report != nil {
+		input.Keywords = append(input.Keywords, report.Site.Keywords...)
+		input.HiddenElements = len(report.Site.HiddenElements)
+		input.HasContactInfo = report.Site.HasContactInfo
+		// a site nothing could be crawled from has not shown it is secure either
+		input.UnsecureConnection = !report.Site.Secure && report.Site.PagesCrawled > 0
+		input.PagesCrawled = report.Site.PagesCrawled
+		for _, page := range report.Pages {
+			if len(page.Keywords) > 0 {
+				input.KeywordPages++
+			}
+		}
+	}
+	if domainAgeDays >= 0 {
+		input.DomainAgeDays = domainAgeDays
+	}
+
+	return input
+}
+
+// RiskRule rates one aspect of the input. Evaluate returns how strongly the rule
+// applies, from 0 (not at all) to 1 (fully), and a human readable evidence
+type RiskRule struct {
+	Name        string
+	Description string
+	Evaluate    func(input RiskInput) (float64, string)
+	// Penalty rules rate evidence of fraud, they add their weight on top of the
+	// weighted sum when they apply and do not dilute it when they do not
+	Penalty bool
+}
+
+// RuleContribution is the share of the final score produced by one rule
+type RuleContribution struct {
+	Rule     string  `json:"rule"`
+	Weight   float64 `json:"weight"`
+	Severity float64 `json:"severity"`
+	Points   float64 `json:"points"`
+	Evidence string  `json:"evidence"`
+}
+
+// RiskScore is the deterministic result of the rule engine, higher is riskier
+type RiskScore struct {
+	Score         int                `json:"score"`
+	TrustScore    int                `json:"trustScore"`
+	RiskLevel     string             `json:"riskLevel"`
+	Contributions []RuleContribution `json:"contributions"`
+}
+
+// RiskEngine computes a 0-100 risk score as the weighted sum of its rules
+type RiskEngine struct {
+	Rules   []RiskRule
+	Weights map[string]float64
+
+	// scores at or above these thresholds are medium and high risk
+	MediumThreshold int
+	HighThreshold   int
+}
+
+// DefaultRiskWeights is the relative importance of every built-in rule
+func DefaultRiskWeights() map[string]float64 {
+	return map[string]float64{
+		RuleSuspiciousKeywords: 15,
+		RuleHiddenElements:     5,
+		RuleNoContactInfo:      15,
+		RuleInsecureConnection: 15,
+		RuleYoungDomain:        20,
+		RuleNoEnamad:           10,
+		RuleImpersonation:      20,
+		RuleForgedSeal:         40,
+		RuleFalseRegistration:  30,
+	}
+}
+
+// RiskConfig tunes the rule engine
+type RiskConfig struct {
+	// Weights override the default weights of the rules they name, a weight of 0 disables a rule
+	Weights map[string]float64 `yaml:"weights"`
+	// scores at or above these thresholds are medium and high risk
+	MediumThreshold int `yaml:"medium_threshold"`
+	HighThreshold   int `yaml:"high_threshold"`
+}
+
+// DefaultRiskConfig returns the default weights and thresholds
+func DefaultRiskConfig() RiskConfig {
+	return RiskConfig{
+		Weights:         DefaultRiskWeights(),
+		MediumThreshold: 35,
+		HighThreshold:   65,
+	}
+}
+
+// Engine returns the rule engine with the configured weights and thresholds
+func (c RiskConfig) Engine() *RiskEngine {
+	engine := NewRiskEngine(c.Weights)
+	engine.MediumThreshold = c.MediumThreshold
+	engine.HighThreshold = c.HighThreshold
+	return engine
+}
+
+// NewRiskEngine returns the built-in rules, overriding the default weights with the given ones.
+// A weight of 0 disables a rule.
+func NewRiskEngine(weights map[string]float64) *RiskEngine {
+	defaults := DefaultRiskConfig()
+	merged := defaults.Weights
+	for name, weight := range weights {
+		merged[name] = weight
+	}
+
+	return &RiskEngine{
+		Rules:           DefaultRiskRules(),
+		Weights:         merged,
+		MediumThreshold: defaults.MediumThreshold,
+		HighThreshold:   defaults.HighThreshold,
+	}
+}
+
+// DefaultRiskRules returns the built-in rules
+func DefaultRiskRules() []RiskRule {
+	return []RiskRule{
+		{
+			Name:        RuleSuspiciousKeywords,
+			Description: "Page text uses wording typical of scams",
+			Evaluate: func(input RiskInput) (float64, string) {
+				if len(input.Keywords) == 0 {
+					return 0, ""
+				}
+				evidence := "found keywords: " + strings.Join(input.Keywords, ", ")
+				if input.PagesCrawled > 0 {
+					evidence += fmt.Sprintf(" on %d of %d pages", input.KeywordPages, input.PagesCrawled)
+				}
+				return math.Min(float64(len(input.Keywords))/3, 1), evidence
+			},
+		},
+		{
+			Name:        RuleHiddenElements,
+			Description: "Content is hidden from the visitor",
+			Evaluate: func(input RiskInput) (float64, string) {
+				if input.HiddenElements == 0 {
+					return 0, ""
+				}
+				return math.Min(float64(input.HiddenElements)/5, 1), fmt.Sprintf("%d hidden elements", input.HiddenElements)
+			},
+		},
+		{
+			Name:        RuleNoContactInfo,
+			Description: "No contact, about or support information",
+			Evaluate: func(input RiskInput) (float64, string) {
+				if input.HasContactInfo {
+					return 0, ""
+				}
+				return 1, "no contact information was found"
+			},
+		},
+		{
+			Name:        RuleInsecureConnection,
+			Description: "Pages are served over plain HTTP or with a bad certificate",
+			Evaluate: func(input RiskInput) (float64, string) {
+				if input.UnsecureConnection {
+					return 1, "site is reachable without https"
+				}
+				if len(input.CertificateIssues) > 0 {
+					return 0.8, strings.Join(input.CertificateIssues, "; ")
+				}
+				return 0, ""
+			},
+		},
+		{
+			Name:        RuleYoungDomain,
+			Description: "Domain was registered recently",
+			Evaluate: func(input RiskInput) (float64, string) {
+				switch age := input.DomainAgeDays; {
+				case age == DomainAgeUnknown:
+					return 0.5, "domain age is unknown"
+				case age < 30:
+					return 1, fmt.Sprintf("domain is %d days old", age)
+				case age < 120:
+					return 0.7, fmt.Sprintf("domain is %d days old", age)
+				case age < 365:
+					return 0.3, fmt.Sprintf("domain is %d days old", age)
+				default:
+					return 0, ""
+				}
+			},
+		},
+		{
+			Name:        RuleNoEnamad,
+			Description: "No Enamad certification",
+			Evaluate: func(input RiskInput) (float64, string) {
+				if input.HasEnamad {
+					return 0, ""
+				}
+				// like an unknown domain age, a failed lookup is no proof the certificate is missing
+				if input.EnamadUnknown {
+					if input.HasSamandehi {
+						return 0.25, "the Enamad certificate could not be checked, the site is registered with Samandehi"
+					}
+					return 0.5, "the Enamad certificate could not be checked, enamad.ir did not answer"
+				}
+				// a lapsed certificate counts fully, a Samandehi registration does not make up for it
+				if input.EnamadExpired {
+					return 1, "the Enamad certificate of the domain has expired"
+				}
+				// Samandehi registers the site too, but checks less than Enamad
+				if input.HasSamandehi {
+					return 0.5, "no Enamad certificate, the site is only registered with Samandehi"
+				}
+				return 1, "no Enamad certificate is registered for the domain"
+			},
+		},
+		{
+			Name:        RuleImpersonation,
+			Description: "Domain or branding imitates a protected brand",
+			Evaluate: func(input RiskInput) (float64, string) {
+				var severity float64
+				evidence := make([]string, 0, len(input.Impersonation))
+				for _, f := range input.Impersonation {
+					severity = math.Max(severity, f.Severity())
+					evidence = append(evidence, f.Evidence)
+				}
+				return severity, strings.Join(evidence, "; ")
+			},
+		},
+		{
+			Name:        RuleForgedSeal,
+			Description: "Trust badges on the page belong to another site",
+			Penalty:     true,
+			Evaluate: func(input RiskInput) (float64, string) {
+				// a forged badge is worse than none, whatever the domain holds
+				if len(input.ForgedSeals) == 0 {
+					return 0, ""
+				}
+				return 1, "forged trust badge: " + strings.Join(input.ForgedSeals, "; ")
+			},
+		},
+		{
+			Name:        RuleFalseRegistration,
+			Description: "Registrations the site claims do not hold",
+			Penalty:     true,
+			Evaluate: func(input RiskInput) (float64, string) {
+				if len(input.RegistryIssues) == 0 {
+					return 0, ""
+				}
+				return 1, "false registration claims: " + strings.Join(input.RegistryIssues, "; ")
+			},
+		},
+	}
+}
+
+// Evaluate runs every enabled rule and returns the score with each rule's contribution.
+// Penalty rules add their weight times the severity to the score as is, the other rules
+// share the 100 points by their weight
+func (e *RiskEngine) Evaluate(input RiskInput) *RiskScore {
+	var totalWeight float64
+	for _, rule := range e.Rules {
+		if weight := e.Weights[rule.Name]; weight > 0 && !rule.Penalty {
+			totalWeight += weight
+		}
+	}
+
+	result := &RiskScore{}
+	var points float64
+	for _, rule := range e.Rules {
+		weight := e.Weights[rule.Name]
+		if weight <= 0 {
+			continue
+		}
+
+		severity, evidence := rule.Evaluate(input)
+		severity = math.Max(0, math.Min(severity, 1))
+		share := weight * severity / 100
+		if !rule.Penalty {
+			share = weight * severity / totalWeight
+		}
+		contribution := RuleContribution{
+			Rule:     rule.Name,
+			Weight:   weight,
+			Severity: severity,
+			Points:   math.Round(share*1000) / 10,
+			Evidence: evidence,
+		}
+		points += contribution.Points
+		result.Contributions = append(result.Contributions, contribution)
+	}
+
+	result.Score = int(math.Round(math.Min(points, 100)))
+	result.TrustScore = 100 - result.Score
+	result.RiskLevel = e.level(result.Score)
+	return result
+}
+
+func (e *RiskEngine) level(score int) string {
+	switch {
+	case score >= e.HighThreshold:
+		return "high"
+	case score >= e.MediumThreshold:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// Triggered returns the contributions that added to the score, highest first
+func (s *RiskScore) Triggered() []RuleContribution {
+	var triggered []RuleContribution
+	for _, c := range s.Contributions {
+		if c.Points > 0 {
+			triggered = append(triggered, c)
+		}
+	}
+	sort.SliceStable(triggered, func(i, j int) bool {
+		return triggered[i].Points > triggered[j].Points
+	})
+	return triggered
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRiskInputFromReport(t *testing.T) {
+	report := NewScrapeReport("example.ir")
+	report.AddPage(PageResult{
+		URL:            "https://example.ir/",
+		Secure:         true,
+		Keywords:       []string{"free", "act now"},
+		HiddenElements: []string{"Hidden by style: display:none"},
+		ContactLinks:   []string{"/contact"},
+	})
+	report.AddPage(PageResult{
+		URL:            "http://example.ir/offer",
+		Keywords:       []string{"free", "prize"},
+		HiddenElements: []string{"Hidden by class: hidden", "Hidden by style: display:none"},
+	})
+	report.Finish()
+
+	compliance := NewComplianceReport([]RegistryResult{EnamadResult(&Enamad_Data{ID: 7}, nil)})
+	got := RiskInputFromReport(report, 42, nil, &TLSReport{Host: "example.ir", SelfSigned: true, CoversHost: true}, nil, compliance)
+	want := RiskInput{
+		Keywords:           []string{"act now", "free", "prize"},
+		HiddenElements:     2,
+		HasContactInfo:     true,
+		UnsecureConnection: true,
+		DomainAgeDays:      42,
+		HasEnamad:          true,
+		PagesCrawled:       2,
+		KeywordPages:       2,
+		Impersonation:      []ImpersonationFinding{},
+		CertificateIssues:  []string{"the certificate is self-signed"},
+		ForgedSeals:        []string{},
+		RegistryIssues:     []string{},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, wanted %+v", got, want)
+	}
+
+	empty := RiskInputFromReport(nil, DomainAgeUnknown, nil, nil, nil, nil)
+	if empty.DomainAgeDays != DomainAgeUnknown || empty.HasEnamad || !empty.EnamadUnknown || empty.UnsecureConnection {
+		t.Errorf("got %+v for an empty report", empty)
+	}
+
+	unavailable := NewComplianceReport([]RegistryResult{EnamadResult(nil, errors.New("timeout"))})
+	if got := RiskInputFromReport(nil, DomainAgeUnknown, nil, nil, nil, unavailable); got.HasEnamad || !got.EnamadUnknown {
+		t.Errorf("got %+v for an unavailable Enamad lookup", got)
+	}
+	missing := NewComplianceReport([]RegistryResult{EnamadResult(&Enamad_Data{}, nil)})
+	if got := RiskInputFromReport(nil, DomainAgeUnknown, nil, nil, nil, missing); got.HasEnamad || got.EnamadUnknown {
+		t.Errorf("got %+v for a domain without a certificate", got)
+	}
+}
+
+func TestRiskEngineEvaluate(t *testing.T) {
+	engine := NewRiskEngine(nil)
+
+	trusted := engine.Evaluate(RiskInput{HasContactInfo: true, DomainAgeDays: 4000, HasEnamad: true})
+	if trusted.Score != 0 || trusted.TrustScore != 100 || trusted.RiskLevel != "low" {
+		t.Errorf("got %+v for a trusted site", trusted)
+	}
+	if len(trusted.Contributions) != len(DefaultRiskRules()) {
+		t.Errorf("got %d contributions, wanted one per rule", len(trusted.Contributions))
+	}
+
+	scam := engine.Evaluate(RiskInput{
+		Keywords:           []string{"act now", "guaranteed profit", "winner"},
+		HiddenElements:     10,
+		UnsecureConnection: true,
+		DomainAgeDays:      3,
+		Impersonation:      []ImpersonationFinding{{Brand: "Digikala", Technique: ImpersonationHomoglyph}},
+	})
+	if scam.Score != 100 || scam.RiskLevel != "high" {
+		t.Errorf("got %+v for an obvious scam", scam)
+	}
+
+	// 15 (no contact) + 20*0.5 (unknown age) + 10 (no enamad) out of 100
+	partial := engine.Evaluate(RiskInput{DomainAgeDays: DomainAgeUnknown})
+	if partial.Score != 35 || partial.RiskLevel != "medium" {
+		t.Errorf("got %+v, wanted a score of 35", partial)
+	}
+
+	// a forged badge counts against a domain even when it holds a certificate
+	forgedSeals := []string{"the enamad badge links to enamad-ir.shop"}
+	forged := engine.Evaluate(RiskInput{HasContactInfo: true, DomainAgeDays: 4000, HasEnamad: true, ForgedSeals: forgedSeals})
+	if forged.Score != 40 || forged.RiskLevel != "medium" {
+		t.Errorf("got %+v for a forged badge, wanted a score of 40", forged)
+	}
+
+	// enamad.ir not answering counts half, like an unknown domain age
+	unknown := engine.Evaluate(RiskInput{HasContactInfo: true, DomainAgeDays: 4000, EnamadUnknown: true})
+	if unknown.Score != 5 {
+		t.Errorf("got %+v for an unknown certificate, wanted a score of 5", unknown)
+	}
+	if triggered := unknown.Triggered(); len(triggered) != 1 || !strings.Contains(triggered[0].Evidence, "could not be checked") {
+		t.Errorf("got triggered rules %+v for an unknown certificate", triggered)
+	}
+
+	// Samandehi halves a missing certificate but not an expired one
+	samandehi := engine.Evaluate(RiskInput{HasContactInfo: true, DomainAgeDays: 4000, HasSamandehi: true})
+	expired := engine.Evaluate(RiskInput{HasContactInfo: true, DomainAgeDays: 4000, HasSamandehi: true, EnamadExpired: true})
+	if samandehi.Score != 5 || expired.Score != 10 {
+		t.Errorf("got %d with Samandehi only and %d with an expired certificate, wanted 5 and 10", samandehi.Score, expired.Score)
+	}
+
+	triggered := partial.Triggered()
+	if len(triggered) != 3 || triggered[0].Rule != RuleNoContactInfo {
+		t.Errorf("got triggered rules %+v", triggered)
+	}
+}
+
+func TestRiskEngineForgedSeal(t *testing.T) {
+	engine := NewRiskEngine(nil)
+
+	absent := engine.Evaluate(RiskInput{HasContactInfo: true, DomainAgeDays: 4000})
+	forged := engine.Evaluate(RiskInput{HasContactInfo: true, DomainAgeDays: 4000, ForgedSeals: []string{"the enamad badge belongs to digikala.com"}})
+	if forged.Score <= absent.Score {
+		t.Errorf("got %d for a forged badge and %d for no badge, wanted forged to score worse", forged.Score, absent.Score)
+	}
+	// 10 (no enamad) + 40 (forged badge)
+	if forged.Score != 50 || forged.RiskLevel != "medium" {
+		t.Errorf("got %+v for a forged badge, wanted a score of 50", forged)
+	}
+	if triggered := forged.Triggered(); len(triggered) != 2 || triggered[0].Rule != RuleForgedSeal {
+		t.Errorf("got triggered rules %+v", triggered)
+	}
+}
+
+func TestRiskConfigEngine(t *testing.T) {
+	cfg := DefaultRiskConfig()
+	cfg.Weights = map[string]float64{RuleYoungDomain: 0}
+	cfg.MediumThreshold, cfg.HighThreshold = 10, 20
+
+	engine := cfg.Engine()
+	if engine.Weights[RuleYoungDomain] != 0 || engine.Weights[RuleNoEnamad] != DefaultRiskWeights()[RuleNoEnamad] {
+		t.Errorf("got weights %v", engine.Weights)
+	}
+	// 15 (no contact) out of 80 falls between the lowered thresholds
+	if got := engine.Evaluate(RiskInput{DomainAgeDays: 1, HasEnamad: true}); got.RiskLevel != "medium" {
+		t.Errorf("got %+v, wanted a medium risk", got)
+	}
+}
+
+func TestRiskEngineWeights(t *testing.T) {
+	// only the Enamad rule is left enabled so it alone decides the score
+	engine := NewRiskEngine(map[string]float64{
+		RuleSuspiciousKeywords: 0,
+		RuleHiddenElements:     0,
+		RuleNoContactInfo:      0,
+		RuleInsecureConnection: 0,
+		RuleYoungDomain:        0,
+		RuleImpersonation:      0,
+		RuleForgedSeal:         0,
+		RuleFalseRegistration:  0,
+	})
+
+	got := engine.Evaluate(RiskInput{DomainAgeDays: 1})
+	if got.Score != 100 || len(got.Contributions) != 1 || got.Contributions[0].Rule != RuleNoEnamad {
+		t.Errorf("got %+v", got)
+	}
+
+	engine.Weights[RuleNoEnamad] = 0
+	if got := engine.Evaluate(RiskInput{}); got.Score != 0 || got.TrustScore != 100 {
+		t.Errorf("got %+v with every rule disabled", got)
+	}
+}
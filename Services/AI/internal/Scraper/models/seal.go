@@ -0,0 +1,299 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Issuers of a TrustSeal
+const (
+	SealEnamad    = "enamad"
+	SealSamandehi = "samandehi"
+)
+
+// sealDomains are the domains a genuine badge of each issuer links to and loads its image from
+var sealDomains = map[string]string{
+	SealEnamad:    "enamad.ir",
+	SealSamandehi: "samandehi.ir",
+}
+
+// sealLinkPattern finds the verification links, also inside the onclick of Samandehi badges
+var sealLinkPattern = regexp.MustCompile(`(?i)https?://[a-z0-9.-]*(?:enamad|samandehi)[a-z0-9.-]*/[^\s"'<>)]*`)
+
+// certificateDomainPattern finds the domains listed on a certificate page
+var certificateDomainPattern = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+(?:ir|com|net|org|shop|store|online|site|info|biz|co|xyz|app)\b`)
+
+// TrustSeal is an Enamad or Samandehi badge found on a page
+type TrustSeal struct {
+	Kind string `json:"kind"`
+	Page string `json:"page"`
+	// Link is the certificate page the badge opens
+	Link  string `json:"link"`
+	Image string `json:"image,omitempty"`
+	// ID and Code identify the certificate in the link
+	ID   string `json:"id,omitempty"`
+	Code string `json:"code,omitempty"`
+}
+
+// sealKind tells which issuer the alt text of a badge image names
+func sealKind(alt string) string {
+	alt = strings.ToLower(alt)
+	switch {
+	case strings.Contains(alt, "enamad") || strings.Contains(alt, "اینماد") || strings.Contains(alt, "نماد اعتماد"):
+		return SealEnamad
+	case strings.Contains(alt, "samandehi") || strings.Contains(alt, "ساماندهی"):
+		return SealSamandehi
+	}
+	return ""
+}
+
+// sealHostKind tells which issuer serves ref, empty when ref is not on an issuer's domain
+func sealHostKind(ref string) string {
+	host := hostOf(ref)
+	for kind := range sealDomains {
+		if isSealHost(kind, host) {
+			return kind
+		}
+	}
+	return ""
+}
+
+// detectTrustSeals lists the trust badges of the page. A badge is a link or image served by an
+// issuer, or an image whose alt text names one. Links that only mention an issuer, like a blog
+// post about Enamad, are no badge.
+func detectTrustSeals(doc *goquery.Document, pageURL *url.URL) []TrustSeal {
+	seals := []TrustSeal{}
+	seen := map[string]bool{}
+	absolute := func(ref string) string {
+		if ref == "" || pageURL == nil {
+			return ref
+		}
+		if u, err := pageURL.Parse(ref); err == nil {
+			return u.String()
+		}
+		return ref
+	}
+
+	doc.Find("img, a").Each(func(i int, s *goquery.Selection) {
+		image, link, kind := "", "", ""
+		switch goquery.NodeName(s) {
+		case "img":
+			image = absolute(strings.TrimSpace(s.AttrOr("src", "")))
+			link = absolute(strings.TrimSpace(s.Closest("a").AttrOr("href", "")))
+			if m := sealLinkPattern.FindString(s.AttrOr("onclick", "")); m != "" {
+				link = m
+			}
+			kind = firstNonEmpty(sealHostKind(link), sealHostKind(image), sealKind(s.AttrOr("alt", "")))
+		case "a":
+			// links wrapping an image are handled with the image
+			if s.Find("img").Length() > 0 {
+				return
+			}
+			link = absolute(strings.TrimSpace(s.AttrOr("href", "")))
+			kind = sealHostKind(link)
+		}
+
+		if kind == "" || seen[link+" "+image] {
+			return
+		}
+		seen[link+" "+image] = true
+
+		seal := TrustSeal{Kind: kind, Link: link, Image: image}
+		if pageURL != nil {
+			seal.Page = pageURL.String()
+		}
+		for _, ref := range []string{link, image} {
+			if u, err := url.Parse(ref); err == nil {
+				q := u.Query()
+				if seal.ID == "" {
+					seal.ID = q.Get("id")
+				}
+				if seal.Code == "" {
+					seal.Code = firstNonEmpty(q.Get("Code"), q.Get("code"), q.Get("p"))
+				}
+			}
+		}
+		seals = append(seals, seal)
+	})
+	return seals
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// uniqueSeals keeps one badge per issuer, certificate and link, the order of first appearance
+func uniqueSeals(seals []TrustSeal) []TrustSeal {
+	unique := []TrustSeal{}
+	seen := map[string]bool{}
+	for _, s := range seals {
+		key := s.Kind + " " + s.ID + " " + s.Link
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
+
+// SealVerification is what the certificate page of a badge says about the scanned site
+type SealVerification struct {
+	Seal TrustSeal `json:"seal"`
+	// Verified is set when the certificate names the scanned site
+	Verified bool `json:"verified"`
+	// Forged is set when the badge provably does not belong to the scanned site
+	Forged bool `json:"forged"`
+	// CertificateDomains are the domains listed on the certificate page
+	CertificateDomains []string `json:"certificate_domains"`
+	Evidence           []string `json:"evidence"`
+	// Error is set when the certificate page could not be read, the badge is then unverified
+	Error string `json:"error,omitempty"`
+}
+
+// NewSealVerification starts the verification of seal, checking the hosts it points to
+func NewSealVerification(seal TrustSeal) *SealVerification {
+	v := &SealVerification{Seal: seal, CertificateDomains: []string{}, Evidence: []string{}}
+	if seal.Link == "" {
+		v.Evidence = append(v.Evidence, fmt.Sprintf("the %s badge does not link to a certificate", seal.Kind))
+	} else if host := hostOf(seal.Link); !isSealHost(seal.Kind, host) {
+		v.forged(fmt.Sprintf("the %s badge links to %s instead of the %s site", seal.Kind, host, seal.Kind))
+	}
+	// a genuine badge loads its image from the issuer, a copied image proves nothing by itself
+	if seal.Image != "" && !isSealHost(seal.Kind, hostOf(seal.Image)) {
+		v.Evidence = append(v.Evidence, fmt.Sprintf("the %s badge image is served from %s", seal.Kind, hostOf(seal.Image)))
+	}
+	return v
+}
+
+func isSealHost(kind, host string) bool {
+	return host != "" && underDomain(host, sealDomains[kind])
+}
+
+func (v *SealVerification) forged(evidence string) {
+	v.Forged = true
+	v.Verified = false
+	v.Evidence = append(v.Evidence, evidence)
+}
+
+// CheckCertificatePage compares the domain field of the certificate page with site. A page
+// without that field only verifies a badge that names the site, it never proves a forgery.
+func (v *SealVerification) CheckCertificatePage(site string, body []byte) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		v.Error = err.Error()
+		return
+	}
+	v.CertificateDomains = certificateDomains(doc)
+
+	host := hostOf(site)
+	for _, domain := range v.CertificateDomains {
+		if sameSite(domain, host) {
+			if !v.Forged {
+				v.Verified = true
+			}
+			return
+		}
+	}
+	if len(v.CertificateDomains) > 0 {
+		v.forged(fmt.Sprintf("the %s certificate %s belongs to %s, not %s", v.Seal.Kind, v.Seal.ID, strings.Join(v.CertificateDomains, ", "), host))
+		return
+	}
+
+	// pages rendered by scripts have no domain field, the site may still be named in the text
+	for _, domain := range pageDomains(doc) {
+		if sameSite(domain, host) {
+			if !v.Forged {
+				v.Verified = true
+			}
+			return
+		}
+	}
+	v.Error = "the certificate page lists no domain field"
+}
+
+// domainLabels are the labels of the domain field of certificate pages. Samandehi labels it
+// "آدرس" alone, a postal address in the same field lists no domain.
+var domainLabels = []string{"آدرس", "دامنه", "domain", "website", "url"}
+
+// certificateDomains lists the domains of the domain field of a certificate page
+func certificateDomains(doc *goquery.Document) []string {
+	var values []string
+	doc.Find("tr, dl").Each(func(i int, row *goquery.Selection) {
+		cells := row.Children().Filter("td, th, dt, dd")
+		if cells.Length() < 2 {
+			return
+		}
+		if matchesAny(strings.ToLower(strings.TrimSpace(cells.First().Text())), domainLabels) {
+			values = append(values, cells.Eq(1).Text())
+			cells.Eq(1).Find("a[href]").Each(func(i int, a *goquery.Selection) {
+				values = append(values, a.AttrOr("href", ""))
+			})
+		}
+	})
+	return findDomains(strings.Join(values, " "))
+}
+
+// pageDomains lists every domain named in the text and links of a page
+func pageDomains(doc *goquery.Document) []string {
+	doc.Find("script, style, noscript").Remove()
+	text := doc.Text()
+	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		text += " " + s.AttrOr("href", "")
+	})
+	return findDomains(text)
+}
+
+// findDomains lists the domains found in text, without the issuers' own
+func findDomains(text string) []string {
+	domains := []string{}
+	seen := map[string]bool{}
+	for _, m := range certificateDomainPattern.FindAllString(text, -1) {
+		domain := strings.TrimPrefix(strings.ToLower(m), "www.")
+		// the issuer's own links are on every certificate page
+		if seen[domain] || underDomain(domain, "enamad.ir") || underDomain(domain, "samandehi.ir") {
+			continue
+		}
+		seen[domain] = true
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// CheckEnamadRecord compares an Enamad badge with the certificate enamad.ir lists for the
+// domain, enamad may be nil when the lookup failed
+func (v *SealVerification) CheckEnamadRecord(enamad *Enamad_Data) {
+	if v.Seal.Kind != SealEnamad || enamad == nil {
+		return
+	}
+	if enamad.ID == 0 {
+		v.forged("enamad.ir has no certificate for this domain although the page shows its badge")
+		return
+	}
+	if id, err := strconv.Atoi(v.Seal.ID); err == nil && id != enamad.ID {
+		v.forged(fmt.Sprintf("the badge shows certificate %d but enamad.ir lists %d for this domain", id, enamad.ID))
+	}
+}
+
+// ForgedSealEvidence is the evidence of every forged badge
+func ForgedSealEvidence(verifications []SealVerification) []string {
+	evidence := []string{}
+	for _, v := range verifications {
+		if v.Forged {
+			evidence = append(evidence, v.Evidence...)
+		}
+	}
+	return evidence
+}
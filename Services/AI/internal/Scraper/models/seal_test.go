@@ -0,0 +1,105 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const sealPage = `<html><body>
+<a referrerpolicy="origin" target="_blank" href="https://trustseal.enamad.ir/?id=12345&Code=AbC"><img referrerpolicy="origin" src="https://trustseal.enamad.ir/logo.aspx?id=12345&Code=AbC" alt="" code="AbC"></a>
+<img id="nbqeoeuk" onclick='window.open("https://logo.samandehi.ir/Verify.aspx?id=678&p=xyz", "Popup")' alt="logo-samandehi" src="https://logo.samandehi.ir/logo.aspx?id=678&p=xyz">
+<a href="/static/enamad-fake.html"><img src="/img/enamad.png" alt="نماد اعتماد"></a>
+<a href="https://example.ir/about">about</a>
+<a href="/blog/how-to-get-enamad">how to get an enamad badge</a>
+<img src="/img/enamad-guide.png" alt="guide">
+</body></html>`
+
+func TestDetectTrustSeals(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(sealPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageURL, _ := url.Parse("https://example.ir/")
+
+	seals := detectTrustSeals(doc, pageURL)
+	if len(seals) != 3 {
+		t.Fatalf("got %d seals, wanted 3: %+v", len(seals), seals)
+	}
+	if s := seals[0]; s.Kind != SealEnamad || s.ID != "12345" || s.Code != "AbC" || s.Page != "https://example.ir/" {
+		t.Errorf("got %+v for the enamad badge", s)
+	}
+	if s := seals[1]; s.Kind != SealSamandehi || s.ID != "678" || s.Code != "xyz" || !strings.Contains(s.Link, "Verify.aspx") {
+		t.Errorf("got %+v for the samandehi badge", s)
+	}
+	if s := seals[2]; s.Kind != SealEnamad || s.Link != "https://example.ir/static/enamad-fake.html" {
+		t.Errorf("got %+v for the self hosted badge", s)
+	}
+
+	// the same badge in the footer of every page is listed once
+	if unique := uniqueSeals(append(seals, seals...)); len(unique) != 3 {
+		t.Errorf("got %d unique seals, wanted 3", len(unique))
+	}
+}
+
+func TestSealVerification(t *testing.T) {
+	genuine := TrustSeal{Kind: SealEnamad, Link: "https://trustseal.enamad.ir/?id=12345&Code=AbC", ID: "12345"}
+	certificate := []byte(`<html><body><a href="https://enamad.ir">enamad</a>
+<table><tr><td>آدرس سایت</td><td>www.example.ir</td></tr></table></body></html>`)
+
+	v := NewSealVerification(genuine)
+	v.CheckEnamadRecord(&Enamad_Data{ID: 12345, Domain: "example.ir"})
+	v.CheckCertificatePage("https://shop.example.ir/", certificate)
+	if !v.Verified || v.Forged || len(v.CertificateDomains) != 1 || v.CertificateDomains[0] != "example.ir" {
+		t.Errorf("got %+v for a genuine badge", v)
+	}
+
+	// a badge copied from another shop
+	v = NewSealVerification(genuine)
+	v.CheckCertificatePage("https://digikala-offer.shop/", certificate)
+	if v.Verified || !v.Forged {
+		t.Errorf("got %+v for a copied badge", v)
+	}
+
+	// the badge number is not the certificate of the domain
+	v = NewSealVerification(genuine)
+	v.CheckEnamadRecord(&Enamad_Data{ID: 999})
+	if !v.Forged {
+		t.Errorf("got %+v for a badge of another certificate", v)
+	}
+
+	v = NewSealVerification(TrustSeal{Kind: SealEnamad, Link: "https://example.ir/static/enamad-fake.html"})
+	if !v.Forged {
+		t.Errorf("got %+v for a badge linking to the site itself", v)
+	}
+
+	// the links of the footer are not the domain of the certificate
+	footer := []byte(`<html><body><table><tr><td>آدرس سایت</td><td>www.example.ir</td></tr></table>
+<a href="https://shaparak.ir">shaparak</a> <a href="https://www.mimt.gov.ir">mimt.gov.ir</a></body></html>`)
+	v = NewSealVerification(genuine)
+	v.CheckCertificatePage("https://example.ir/", footer)
+	if !v.Verified || v.Forged || len(v.CertificateDomains) != 1 {
+		t.Errorf("got %+v for a certificate with footer links", v)
+	}
+
+	// a page rendered by scripts has no domain field, other domains on it prove nothing
+	v = NewSealVerification(genuine)
+	v.CheckCertificatePage("https://digikala-offer.shop/", []byte(`<html><body><a href="https://www.mimt.gov.ir">mimt.gov.ir</a></body></html>`))
+	if v.Verified || v.Forged || v.Error == "" {
+		t.Errorf("got %+v for a certificate page without a domain field", v)
+	}
+
+	// an unreadable certificate proves nothing
+	v = NewSealVerification(genuine)
+	v.CheckCertificatePage("https://example.ir/", []byte("<html><body>please try again later</body></html>"))
+	if v.Verified || v.Forged || v.Error == "" {
+		t.Errorf("got %+v for an empty certificate page", v)
+	}
+
+	evidence := ForgedSealEvidence([]SealVerification{*NewSealVerification(TrustSeal{Kind: SealSamandehi, Link: "https://samandehi-ir.shop/verify"})})
+	if len(evidence) != 1 || !strings.Contains(evidence[0], "samandehi-ir.shop") {
+		t.Errorf("got evidence %q", evidence)
+	}
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Validation levels of a certificate, from the CA/Browser forum policy identifiers
+const (
+	ValidationDV      = "DV"
+	ValidationOV      = "OV"
+	ValidationIV      = "IV"
+	ValidationEV      = "EV"
+	ValidationUnknown = "unknown"
+)
+
+// validationPolicies maps the CA/Browser forum certificate policies to their level
+var validationPolicies = map[string]string{
+	"2.23.140.1.1":   ValidationEV,
+	"2.23.140.1.2.1": ValidationDV,
+	"2.23.140.1.2.2": ValidationOV,
+	"2.23.140.1.2.3": ValidationIV,
+}
+
+// jurisdictionOID is the jurisdiction of incorporation, only EV subjects carry it
+var jurisdictionOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 60, 2, 1, 3}
+
+// freeCAs are the issuers handing out certificates for free, anyone can get one in minutes
+var freeCAs = []string{"let's encrypt", "zerossl", "buypass", "cpanel", "cloudflare", "google trust services", "ssl.com free"}
+
+// TLSReport is what the TLS handshake with a site tells about it
+type TLSReport struct {
+	Host string `json:"host"`
+	// Error is set when no handshake could be made, the other fields are then empty
+	Error       string `json:"error,omitempty"`
+	Version     string `json:"version,omitempty"`
+	CipherSuite string `json:"cipher_suite,omitempty"`
+
+	Subject            string    `json:"subject,omitempty"`
+	Issuer             string    `json:"issuer,omitempty"`
+	IssuerOrganization string    `json:"issuer_organization,omitempty"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	AgeDays            int       `json:"age_days"`
+	DaysRemaining      int       `json:"days_remaining"`
+	SANs               []string  `json:"sans"`
+	CoversHost         bool      `json:"covers_host"`
+
+	SelfSigned  bool   `json:"self_signed"`
+	Expired     bool   `json:"expired"`
+	NotYetValid bool   `json:"not_yet_valid"`
+	Trusted     bool   `json:"trusted"`
+	VerifyError string `json:"verify_error,omitempty"`
+
+	Validation string `json:"validation"`
+	FreeCA     bool   `json:"free_ca"`
+}
+
+// AnalyzeTLS describes the connection made to host. roots are the trusted authorities,
+// nil uses the system pool. The leaf is checked by hand so that an invalid certificate
+// is still described.
+func AnalyzeTLS(host string, state tls.ConnectionState, roots *x509.CertPool, now time.Time) *TLSReport {
+	report := &TLSReport{
+		Host:        host,
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		SANs:        []string{},
+		Validation:  ValidationUnknown,
+	}
+	if len(state.PeerCertificates) == 0 {
+		report.Error = "the server sent no certificate"
+		return report
+	}
+
+	leaf := state.PeerCertificates[0]
+	report.Subject = leaf.Subject.CommonName
+	report.Issuer = leaf.Issuer.CommonName
+	if len(leaf.Issuer.Organization) > 0 {
+		report.IssuerOrganization = leaf.Issuer.Organization[0]
+	}
+	report.NotBefore = leaf.NotBefore
+	report.NotAfter = leaf.NotAfter
+	report.AgeDays = int(now.Sub(leaf.NotBefore).Hours() / 24)
+	report.DaysRemaining = int(leaf.NotAfter.Sub(now).Hours() / 24)
+	report.SANs = append(report.SANs, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		report.SANs = append(report.SANs, ip.String())
+	}
+	report.CoversHost = leaf.VerifyHostname(host) == nil
+
+	report.Expired = now.After(leaf.NotAfter)
+	report.NotYetValid = now.Before(leaf.NotBefore)
+	report.SelfSigned = bytes.Equal(leaf.RawIssuer, leaf.RawSubject) && leaf.CheckSignatureFrom(leaf) == nil
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	report.Trusted = err == nil
+	if err != nil {
+		report.VerifyError = err.Error()
+	}
+
+	report.Validation = validationLevel(leaf)
+	issuer := strings.ToLower(report.Issuer + " " + report.IssuerOrganization)
+	for _, ca := range freeCAs {
+		if strings.Contains(issuer, ca) {
+			report.FreeCA = true
+			break
+		}
+	}
+
+	return report
+}
+
+// validationLevel reads the level from the certificate policies, certificates without one
+// are OV when they name an organization
+func validationLevel(cert *x509.Certificate) string {
+	level := ""
+	rank := map[string]int{ValidationDV: 1, ValidationIV: 2, ValidationOV: 2, ValidationEV: 3}
+	for _, policy := range cert.PolicyIdentifiers {
+		if l, ok := validationPolicies[policy.String()]; ok && rank[l] > rank[level] {
+			level = l
+		}
+	}
+	for _, name := range cert.Subject.Names {
+		if name.Type.Equal(jurisdictionOID) {
+			level = ValidationEV
+		}
+	}
+
+	switch {
+	case level != "":
+		return level
+	case len(cert.Subject.Organization) > 0:
+		return ValidationOV
+	case len(cert.DNSNames) > 0:
+		return ValidationDV
+	}
+	return ValidationUnknown
+}
+
+// Issues lists the problems of the certificate in words, empty for a healthy one
+func (r *TLSReport) Issues() []string {
+	issues := []string{}
+	if r == nil {
+		return issues
+	}
+	if r.Error != "" {
+		return append(issues, "no TLS connection could be made: "+r.Error)
+	}
+	// a self-signed certificate only passes when it is trusted explicitly
+	if r.SelfSigned && !r.Trusted {
+		issues = append(issues, "the certificate is self-signed")
+	}
+	if r.Expired {
+		issues = append(issues, fmt.Sprintf("the certificate expired on %s", r.NotAfter.Format("2006-01-02")))
+	}
+	if r.NotYetValid {
+		issues = append(issues, fmt.Sprintf("the certificate is only valid from %s", r.NotBefore.Format("2006-01-02")))
+	}
+	if !r.CoversHost {
+		issues = append(issues, "the certificate does not cover "+r.Host)
+	}
+	if !r.Trusted && !r.SelfSigned && !r.Expired && !r.NotYetValid && r.CoversHost {
+		issues = append(issues, "the certificate is not issued by a trusted authority")
+	}
+	if r.Version == "TLS 1.0" || r.Version == "TLS 1.1" || r.Version == "SSLv3" {
+		issues = append(issues, "the server negotiated the outdated "+r.Version)
+	}
+	return issues
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTLSReportIssues(t *testing.T) {
+	expired := &TLSReport{
+		Host:       "example.ir",
+		Version:    "TLS 1.0",
+		NotAfter:   time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		Expired:    true,
+		CoversHost: true,
+	}
+	want := []string{"the certificate expired on 2024-01-02", "the server negotiated the outdated TLS 1.0"}
+	if got := expired.Issues(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, wanted %v", got, want)
+	}
+
+	healthy := &TLSReport{Host: "example.ir", Version: "TLS 1.3", Trusted: true, CoversHost: true}
+	if got := healthy.Issues(); len(got) != 0 {
+		t.Errorf("got %v for a healthy certificate", got)
+	}
+
+	var missing *TLSReport
+	if got := missing.Issues(); len(got) != 0 {
+		t.Errorf("got %v for a missing report", got)
+	}
+}
+
+func TestAnalyzeTLSValidation(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	leaf := &x509.Certificate{
+		Subject:           pkix.Name{CommonName: "shop.ir", Organization: []string{"Shop Co"}},
+		Issuer:            pkix.Name{CommonName: "R3", Organization: []string{"Let's Encrypt"}},
+		NotBefore:         now.Add(-72 * time.Hour),
+		NotAfter:          now.Add(30 * 24 * time.Hour),
+		DNSNames:          []string{"shop.ir", "www.shop.ir"},
+		PolicyIdentifiers: []asn1.ObjectIdentifier{{2, 23, 140, 1, 2, 1}},
+	}
+
+	got := AnalyzeTLS("www.shop.ir", tls.ConnectionState{Version: tls.VersionTLS13, PeerCertificates: []*x509.Certificate{leaf}}, x509.NewCertPool(), now)
+	if got.Version != "TLS 1.3" || got.AgeDays != 3 || got.DaysRemaining != 30 || !got.CoversHost {
+		t.Errorf("got %+v", got)
+	}
+	// the DV policy wins over the organization in the subject
+	if got.Validation != ValidationDV || !got.FreeCA || got.Trusted {
+		t.Errorf("got validation %s free %v trusted %v", got.Validation, got.FreeCA, got.Trusted)
+	}
+}
@@ -5,12 +5,12 @@ import (
 	"github.com/gorilla/mux"
 )
 
-func NewRouter(screenshotHandler *handlers.ScreenshotHandler) *mux.Router {
+func NewRouter(scraperHandler *handlers.ScraperHandler, screenshotHandler *handlers.ScreenshotHandler, enamadClient *handlers.EnamadClient) *mux.Router {
 
 	r := mux.NewRouter()
 
-	r.HandleFunc("/scrape", handlers.Scrape).Methods("POST")
-	r.HandleFunc("/enamad", handlers.EnamadHandler).Methods("POST")
+	r.HandleFunc("/scrape", scraperHandler.Scrape).Methods("POST")
+	r.HandleFunc("/enamad", enamadClient.EnamadHandler).Methods("POST")
 	r.HandleFunc("/screenshot", screenshotHandler.ScreenShotHandler).Methods("POST")
 	r.HandleFunc("/screenshot/get", screenshotHandler.GetScreenshotByID).Methods("GET")
 	r.HandleFunc("/screenshot/domain", screenshotHandler.GetScreenshotByDomain).Methods("GET")
@@ -0,0 +1,399 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/llm"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/AI/models"
+	scraperModels "github.com/ArminEbrahimpour/scamSleuthAI/internal/Scraper/models"
+	"github.com/ArminEbrahimpour/scamSleuthAI/internal/safedial"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting of the service, it is loaded once at startup
+type Config struct {
+	ListenAddress string `yaml:"listen_address"`
+	MongoURI      string `yaml:"mongo_uri"`
+	PostgresURI   string `yaml:"postgres_uri"`
+
+	Server     ServerConfig                   `yaml:"server"`
+	Outbound   OutboundConfig                 `yaml:"outbound"`
+	LLM        llm.Config                     `yaml:"llm"`
+	Crawler    scraperModels.CrawlerConfig    `yaml:"crawler"`
+	Screenshot scraperModels.ScreenshotConfig `yaml:"screenshot"`
+	Enamad     scraperModels.EnamadConfig     `yaml:"enamad"`
+	Samandehi  scraperModels.SamandehiConfig  `yaml:"samandehi"`
+	Cache      models.CachePolicy             `yaml:"cache"`
+	Risk       scraperModels.RiskConfig       `yaml:"risk"`
+	Scans      ScanConfig                     `yaml:"scans"`
+	DNS        DNSConfig                      `yaml:"dns"`
+	Whois      WhoisConfig                    `yaml:"whois"`
+	// Brands are the protected brands lookalike domains are compared against
+	Brands []scraperModels.Brand `yaml:"brands"`
+}
+
+// ServerConfig bounds the http connections and the graceful shutdown
+type ServerConfig struct {
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	// WriteTimeout must leave room for a synchronous scan and its event stream
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout is how long in-flight requests and scans may drain on SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// OutboundConfig restricts the connections made to scanned sites
+type OutboundConfig struct {
+	// AllowedNetworks are private ranges fetchers may still reach, empty in production
+	AllowedNetworks []string `yaml:"allowed_networks"`
+}
+
+// Dialer returns the safe dialer shared by every fetcher
+func (c OutboundConfig) Dialer() (*safedial.Dialer, error) {
+	allowed, err := safedial.ParsePrefixes(c.AllowedNetworks)
+	if err != nil {
+		return nil, err
+	}
+	return safedial.New(allowed...), nil
+}
+
+// ScanConfig sizes the asynchronous scan queue
+type ScanConfig struct {
+	Workers   int           `yaml:"workers"`
+	QueueSize int           `yaml:"queue_size"`
+	Timeout   time.Duration `yaml:"timeout"`
+	// MaxBatchSize bounds the urls of one batch, its jobs wait for a worker free of single scans and take no room in the queue
+	MaxBatchSize int `yaml:"max_batch_size"`
+}
+
+// DNSConfig chooses the resolver asked about scanned domains
+type DNSConfig struct {
+	// Resolver is the host:port of a recursive resolver, empty uses the one of the system
+	Resolver string        `yaml:"resolver"`
+	Timeout  time.Duration `yaml:"timeout"`
+	// CheckTimeout bounds all the queries about one scanned domain, the hosting lookups included
+	CheckTimeout time.Duration `yaml:"check_timeout"`
+}
+
+// WhoisConfig chooses where registration data is looked up and how long it is cached
+type WhoisConfig struct {
+	// RDAPBootstrap is the IANA registry listing the RDAP server of every top level domain
+	RDAPBootstrap string `yaml:"rdap_bootstrap"`
+	// RDAPServer is asked about every domain when set, instead of the bootstrap servers
+	RDAPServer string `yaml:"rdap_server"`
+	// Server is the port 43 whois server used when RDAP fails, empty asks the registry's
+	Server  string        `yaml:"server"`
+	Timeout time.Duration `yaml:"timeout"`
+	// CacheTTL is how long a record is reused, zero disables the cache
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// Default returns the settings used when neither the file nor the environment sets them.
+// The database URIs have no default, they carry credentials.
+func Default() *Config {
+	return &Config{
+		ListenAddress: "0.0.0.0:6996",
+		Server: ServerConfig{
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      10 * time.Minute,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   2 * time.Minute,
+		},
+		LLM: llm.Config{
+			Provider: llm.ProviderOpenRouter,
+			Timeout:  2 * time.Minute,
+		},
+		Crawler:    scraperModels.DefaultCrawlerConfig(),
+		Screenshot: scraperModels.DefaultScreenshotConfig(),
+		Enamad:     scraperModels.DefaultEnamadConfig(),
+		Samandehi:  scraperModels.DefaultSamandehiConfig(),
+		Cache:      models.DefaultCachePolicy(),
+		Risk:       scraperModels.DefaultRiskConfig(),
+		Scans: ScanConfig{
+			Workers:      4,
+			QueueSize:    100,
+			Timeout:      5 * time.Minute,
+			MaxBatchSize: 1000,
+		},
+		DNS: DNSConfig{
+			Timeout:      5 * time.Second,
+			CheckTimeout: 30 * time.Second,
+		},
+		Whois: WhoisConfig{
+			RDAPBootstrap: "https://data.iana.org/rdap/dns.json",
+			Timeout:       20 * time.Second,
+			CacheTTL:      24 * time.Hour,
+		},
+		Brands: scraperModels.DefaultBrands(),
+	}
+}
+
+// Load builds the configuration from the defaults, the optional YAML file at path and the
+// environment, in that order of precedence, and validates it. A .env file in the working
+// directory is read into the environment first when present.
+func Load(path string) (*Config, error) {
+	// the .env file is optional, real environment variables take precedence
+	_ = godotenv.Load()
+
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+	default:
+		return fmt.Errorf("unsupported config file %q, only YAML files are supported", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %v", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	// an empty file keeps the defaults
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error parsing config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// envVar binds an environment variable to a setting
+type envVar struct {
+	name  string
+	apply func(c *Config, value string) error
+}
+
+// envVars lists the environment variables read by Load. APIKEY, POSTGRES_URL and
+// MONGODB_URL keep the names already used by the .env and compose files.
+var envVars = []envVar{
+	{"LISTEN_ADDRESS", setString(func(c *Config) *string { return &c.ListenAddress })},
+	{"MONGODB_URL", setString(func(c *Config) *string { return &c.MongoURI })},
+	{"POSTGRES_URL", setString(func(c *Config) *string { return &c.PostgresURI })},
+
+	{"SERVER_READ_HEADER_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.Server.ReadHeaderTimeout })},
+	{"SERVER_READ_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.Server.ReadTimeout })},
+	{"SERVER_WRITE_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.Server.WriteTimeout })},
+	{"SERVER_IDLE_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.Server.IdleTimeout })},
+	{"SHUTDOWN_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout })},
+
+	{"OUTBOUND_ALLOWED_NETWORKS", func(c *Config, value string) error {
+		c.Outbound.AllowedNetworks = strings.Split(value, ",")
+		return nil
+	}},
+
+	{"LLM_PROVIDER", setString(func(c *Config) *string { return &c.LLM.Provider })},
+	{"LLM_BASE_URL", setString(func(c *Config) *string { return &c.LLM.BaseURL })},
+	{"APIKEY", setString(func(c *Config) *string { return &c.LLM.APIKey })},
+	{"LLM_MODEL", setString(func(c *Config) *string { return &c.LLM.Model })},
+	{"LLM_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.LLM.Timeout })},
+
+	{"CRAWLER_MAX_DURATION", setDuration(func(c *Config) *time.Duration { return &c.Crawler.MaxDuration })},
+	{"CRAWLER_REQUEST_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.Crawler.RequestTimeout })},
+	{"CRAWLER_PARALLELISM", setInt(func(c *Config) *int { return &c.Crawler.Parallelism })},
+	{"CRAWLER_RANDOM_DELAY", setDuration(func(c *Config) *time.Duration { return &c.Crawler.RandomDelay })},
+	{"CRAWLER_MAX_ACTIVE_REQUESTS", setInt(func(c *Config) *int { return &c.Crawler.MaxActiveRequests })},
+	{"CRAWLER_CACHE_DIR", setString(func(c *Config) *string { return &c.Crawler.CacheDir })},
+
+	{"SCREENSHOT_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.Screenshot.Timeout })},
+
+	{"ENAMAD_BASE_URL", setString(func(c *Config) *string { return &c.Enamad.BaseURL })},
+	{"ENAMAD_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.Enamad.Timeout })},
+	{"ENAMAD_RETRIES", setInt(func(c *Config) *int { return &c.Enamad.Retries })},
+	{"ENAMAD_CACHE_TTL", setDuration(func(c *Config) *time.Duration { return &c.Enamad.CacheTTL })},
+
+	{"SAMANDEHI_BASE_URL", setString(func(c *Config) *string { return &c.Samandehi.BaseURL })},
+	{"SAMANDEHI_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.Samandehi.Timeout })},
+
+	{"CACHE_HIGH_RISK", setDuration(func(c *Config) *time.Duration { return &c.Cache.HighRisk })},
+	{"CACHE_MEDIUM_RISK", setDuration(func(c *Config) *time.Duration { return &c.Cache.MediumRisk })},
+	{"CACHE_LOW_RISK", setDuration(func(c *Config) *time.Duration { return &c.Cache.LowRisk })},
+	{"CACHE_DEFAULT", setDuration(func(c *Config) *time.Duration { return &c.Cache.Default })},
+
+	{"RISK_MEDIUM_THRESHOLD", setInt(func(c *Config) *int { return &c.Risk.MediumThreshold })},
+	{"RISK_HIGH_THRESHOLD", setInt(func(c *Config) *int { return &c.Risk.HighThreshold })},
+
+	{"SCAN_WORKERS", setInt(func(c *Config) *int { return &c.Scans.Workers })},
+	{"SCAN_QUEUE_SIZE", setInt(func(c *Config) *int { return &c.Scans.QueueSize })},
+	{"SCAN_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.Scans.Timeout })},
+	{"SCAN_MAX_BATCH_SIZE", setInt(func(c *Config) *int { return &c.Scans.MaxBatchSize })},
+
+	{"DNS_RESOLVER", setString(func(c *Config) *string { return &c.DNS.Resolver })},
+	{"DNS_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.DNS.Timeout })},
+	{"DNS_CHECK_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.DNS.CheckTimeout })},
+
+	{"WHOIS_RDAP_BOOTSTRAP", setString(func(c *Config) *string { return &c.Whois.RDAPBootstrap })},
+	{"WHOIS_RDAP_SERVER", setString(func(c *Config) *string { return &c.Whois.RDAPServer })},
+	{"WHOIS_SERVER", setString(func(c *Config) *string { return &c.Whois.Server })},
+	{"WHOIS_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.Whois.Timeout })},
+	{"WHOIS_CACHE_TTL", setDuration(func(c *Config) *time.Duration { return &c.Whois.CacheTTL })},
+}
+
+func setString(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setInt(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+func setDuration(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = parsed
+		return nil
+	}
+}
+
+func (c *Config) loadEnv(lookup func(string) (string, bool)) error {
+	for _, v := range envVars {
+		value, ok := lookup(v.name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := v.apply(c, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", v.name, err)
+		}
+	}
+	return nil
+}
+
+// ValidationError lists every invalid setting
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks that every setting is usable
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.ListenAddress != "", "listen_address is required")
+	check(strings.HasPrefix(c.MongoURI, "mongodb://") || strings.HasPrefix(c.MongoURI, "mongodb+srv://"),
+		"mongo_uri (MONGODB_URL) must be a mongodb:// uri")
+	check(strings.HasPrefix(c.PostgresURI, "postgres://") || strings.HasPrefix(c.PostgresURI, "postgresql://"),
+		"postgres_uri (POSTGRES_URL) must be a postgresql:// uri")
+
+	check(c.Server.ReadHeaderTimeout > 0 && c.Server.ReadTimeout > 0 && c.Server.WriteTimeout > 0 && c.Server.IdleTimeout > 0,
+		"every server timeout must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+
+	if _, err := safedial.ParsePrefixes(c.Outbound.AllowedNetworks); err != nil {
+		problems = append(problems, "outbound.allowed_networks: "+err.Error())
+	}
+
+	switch strings.ToLower(c.LLM.Provider) {
+	case "", llm.ProviderOpenRouter:
+		check(c.LLM.APIKey != "", "llm.api_key (APIKEY) is required by openrouter")
+	case llm.ProviderOpenAI:
+		check(c.LLM.BaseURL != "", "llm.base_url is required by the openai provider")
+	case llm.ProviderOllama, llm.ProviderFake:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown llm.provider %q", c.LLM.Provider))
+	}
+	check(c.LLM.Timeout > 0, "llm.timeout must be positive")
+
+	check(c.Crawler.MaxDuration > 0, "crawler.max_duration must be positive")
+	check(c.Crawler.RequestTimeout > 0, "crawler.request_timeout must be positive")
+	check(c.Crawler.Parallelism > 0, "crawler.parallelism must be at least 1")
+	check(c.Crawler.RandomDelay >= 0, "crawler.random_delay cannot be negative")
+	check(c.Crawler.MaxActiveRequests > 0, "crawler.max_active_requests must be at least 1")
+
+	check(c.Screenshot.Timeout > 0, "screenshot.timeout must be positive")
+	check(c.Screenshot.Width > 0 && c.Screenshot.Height > 0, "screenshot.width and screenshot.height must be positive")
+
+	check(strings.HasPrefix(c.Enamad.BaseURL, "https://") || strings.HasPrefix(c.Enamad.BaseURL, "http://"), "enamad.base_url must be an http(s) url")
+	check(c.Enamad.Timeout > 0, "enamad.timeout must be positive")
+	check(c.Enamad.Retries >= 0 && c.Enamad.RetryDelay >= 0, "enamad.retries and enamad.retry_delay cannot be negative")
+	check(c.Enamad.CacheTTL >= 0, "enamad.cache_ttl cannot be negative")
+	check(strings.HasPrefix(c.Samandehi.BaseURL, "https://") || strings.HasPrefix(c.Samandehi.BaseURL, "http://"), "samandehi.base_url must be an http(s) url")
+	check(c.Samandehi.Timeout > 0, "samandehi.timeout must be positive")
+
+	check(c.Cache.HighRisk > 0 && c.Cache.MediumRisk > 0 && c.Cache.LowRisk > 0 && c.Cache.Default > 0,
+		"every cache window must be positive")
+
+	rules := scraperModels.DefaultRiskWeights()
+	for name, weight := range c.Risk.Weights {
+		_, known := rules[name]
+		check(known, "unknown rule %q in risk.weights", name)
+		check(weight >= 0, "risk.weights.%s cannot be negative", name)
+	}
+	check(0 < c.Risk.MediumThreshold && c.Risk.MediumThreshold < c.Risk.HighThreshold && c.Risk.HighThreshold <= 100,
+		"risk thresholds must satisfy 0 < medium_threshold < high_threshold <= 100")
+
+	check(c.Scans.Workers > 0, "scans.workers must be at least 1")
+	check(c.Scans.QueueSize > 0, "scans.queue_size must be at least 1")
+	check(c.Scans.Timeout > 0, "scans.timeout must be positive")
+	check(c.Scans.MaxBatchSize > 0, "scans.max_batch_size must be at least 1")
+
+	if c.DNS.Resolver != "" {
+		_, _, err := net.SplitHostPort(c.DNS.Resolver)
+		check(err == nil, "dns.resolver must be a host:port address")
+	}
+	check(c.DNS.Timeout > 0, "dns.timeout must be positive")
+	check(c.DNS.CheckTimeout >= c.DNS.Timeout, "dns.check_timeout must be at least dns.timeout")
+
+	for name, value := range map[string]string{"whois.rdap_bootstrap": c.Whois.RDAPBootstrap, "whois.rdap_server": c.Whois.RDAPServer} {
+		check(value == "" || strings.HasPrefix(value, "https://") || strings.HasPrefix(value, "http://"), "%s must be an http(s) url", name)
+	}
+	check(c.Whois.Timeout > 0, "whois.timeout must be positive")
+	check(c.Whois.CacheTTL >= 0, "whois.cache_ttl cannot be negative")
+
+	for i, brand := range c.Brands {
+		check(brand.Name != "" && len(brand.Domains) > 0, "brands[%d] needs a name and at least one domain", i)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package safedial
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress is returned when a connection would reach a private or reserved address
+var ErrBlockedAddress = errors.New("address is not allowed")
+
+// blockedPrefixes are the private, local and reserved ranges no fetcher may reach
+var blockedPrefixes = mustParsePrefixes(
+	"0.0.0.0/8",       // this network
+	"10.0.0.0/8",      // private
+	"100.64.0.0/10",   // carrier grade NAT
+	"127.0.0.0/8",     // loopback
+	"169.254.0.0/16",  // link-local, cloud metadata
+	"172.16.0.0/12",   // private
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // documentation
+	"192.88.99.0/24",  // 6to4 relay
+	"192.168.0.0/16",  // private
+	"198.18.0.0/15",   // benchmarking
+	"198.51.100.0/24", // documentation
+	"203.0.113.0/24",  // documentation
+	"224.0.0.0/4",     // multicast
+	"240.0.0.0/4",     // reserved and broadcast
+	"::/128",          // unspecified
+	"::1/128",         // loopback
+	"64:ff9b::/96",    // NAT64, embeds IPv4 addresses
+	"64:ff9b:1::/48",  // local NAT64
+	"100::/64",        // discard
+	"2001::/23",       // IETF protocol assignments
+	"2001:db8::/32",   // documentation
+	"2002::/16",       // 6to4, embeds IPv4 addresses
+	"fc00::/7",        // unique local
+	"fe80::/10",       // link-local
+	"ff00::/8",        // multicast
+)
+
+func mustParsePrefixes(prefixes ...string) []netip.Prefix {
+	parsed := make([]netip.Prefix, len(prefixes))
+	for i, p := range prefixes {
+		parsed[i] = netip.MustParsePrefix(p)
+	}
+	return parsed
+}
+
+// IsBlocked reports whether ip is in a private or reserved range
+func IsBlocked(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dialer connects only to public addresses. The check runs on the address actually
+// connected to, after name resolution, so DNS rebinding and redirects are covered too.
+type Dialer struct {
+	// Allowed are exceptions to the blocked ranges, for internal deployments and tests
+	Allowed []netip.Prefix
+	// Resolver resolves host names, nil uses the system resolver
+	Resolver *net.Resolver
+	Timeout  time.Duration
+}
+
+// New returns a dialer blocking every private and reserved range except allowed
+func New(allowed ...netip.Prefix) *Dialer {
+	return &Dialer{Allowed: allowed, Timeout: 10 * time.Second}
+}
+
+// ParsePrefixes parses CIDR ranges or single addresses, as found in the configuration
+func ParsePrefixes(values []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q: %v", value, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %v", value, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Allows reports whether the dialer may connect to ip
+func (d *Dialer) Allows(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	for _, prefix := range d.Allowed {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return !IsBlocked(ip)
+}
+
+// control runs right before each connect with the resolved address
+func (d *Dialer) control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+	}
+	if !d.Allows(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// DialContext connects like net.Dialer.DialContext but refuses blocked addresses
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := net.Dialer{
+		Timeout:  d.Timeout,
+		Resolver: d.Resolver,
+		Control:  d.control,
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+// Dial connects without a context, for clients that only accept a proxy.Dialer
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// Transport is an http.Transport dialing through d. Environment proxies are ignored
+// since a proxy would make the connection on our behalf.
+func (d *Dialer) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = d.DialContext
+	return transport
+}
+
+// maxRedirects matches the limit of the standard client
+const maxRedirects = 10
+
+// Client is an http.Client dialing through d, redirects are only followed to http and https urls
+func (d *Dialer) Client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: d.Transport(),
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return CheckScheme(req.URL)
+		},
+	}
+}
+
+// CheckScheme rejects urls that are not plain web urls
+func CheckScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return errors.New("invalid url: missing host")
+	}
+	return nil
+}
+
+// CheckURL validates rawURL before it is handed to a fetcher that cannot use the dialer
+// directly, every address the host resolves to must be allowed. The connection itself
+// must still go through the dialer, the answer may change by then.
+func (d *Dialer) CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL format: %v", err)
+	}
+	if err := CheckScheme(u); err != nil {
+		return err
+	}
+
+	host := u.Hostname()
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if !d.Allows(ip) {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, ip)
+		}
+		return nil
+	}
+
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %v", host, err)
+	}
+	for _, addr := range addrs {
+		if !d.Allows(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrBlockedAddress, host, addr.Unmap())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package safedial
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsBlocked(t *testing.T) {
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"8.8.8.8", false},
+		{"185.143.232.10", false},
+		{"2606:4700:4700::1111", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsBlocked(netip.MustParseAddr(tt.ip)); got != tt.blocked {
+			t.Errorf("IsBlocked(%s) = %v, wanted %v", tt.ip, got, tt.blocked)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	d := New()
+	ctx := context.Background()
+
+	for _, blocked := range []string{
+		"http://127.0.0.1/",
+		"http://[::1]:8080/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://localhost/",
+		"ftp://example.com/",
+		"file:///etc/passwd",
+	} {
+		if err := d.CheckURL(ctx, blocked); err == nil {
+			t.Errorf("CheckURL(%q) expected an error", blocked)
+		}
+	}
+
+	// hosts are not matched by substring any more
+	if err := d.CheckURL(ctx, "http://8.8.8.8/example10.com"); err != nil {
+		t.Errorf("CheckURL returned an error for a public address: %v", err)
+	}
+}
+
+func TestDialerRefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer srv.Close()
+
+	_, err := New().Client(0).Get(srv.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("got %v, wanted ErrBlockedAddress", err)
+	}
+
+	allowed := New(netip.MustParsePrefix("127.0.0.1/32"))
+	resp, err := allowed.Client(0).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("an allowed network must be reachable: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestProxyCloseEndsTunnels(t *testing.T) {
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	go func() {
+		// the upstream end stays open, only the proxy can end the tunnel
+		conn, err := upstream.Accept()
+		if err == nil {
+			defer conn.Close()
+			io.Copy(io.Discard, conn)
+		}
+	}()
+
+	proxy, err := New(netip.MustParsePrefix("127.0.0.1/32")).StartProxy()
+	if err != nil {
+		t.Fatalf("StartProxy returned an error: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(proxy.URL(), "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "CONNECT %[1]s HTTP/1.1\r\nHost: %[1]s\r\n\r\n", upstream.Addr())
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %v, %v for CONNECT", resp, err)
+	}
+
+	proxy.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("got %v reading a tunnel of a closed proxy, wanted io.EOF", err)
+	}
+}
+
+func TestDialerChecksRedirects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 127.0.0.2 is loopback as well but not in the allowed range
+		target := strings.Replace("http://"+r.Host+"/internal", "127.0.0.1", "127.0.0.2", 1)
+		http.Redirect(w, r, target, http.StatusFound)
+	}))
+	defer srv.Close()
+
+	d := New(netip.MustParsePrefix("127.0.0.1/32"))
+	_, err := d.Client(0).Get(srv.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("got %v, wanted the redirect to be blocked", err)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	proxy, err := New(netip.MustParsePrefix("127.0.0.1/32")).StartProxy()
+	if err != nil {
+		t.Fatalf("StartProxy returned an error: %v", err)
+	}
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL())
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get through the proxy returned an error: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello" {
+		t.Errorf("got body %q", body)
+	}
+
+	blocked := strings.Replace(srv.URL, "127.0.0.1", "127.0.0.2", 1)
+	resp, err = client.Get(blocked)
+	if err != nil {
+		t.Fatalf("Get through the proxy returned an error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("got status %d for a blocked address, wanted 403", resp.StatusCode)
+	}
+
+	// https goes through CONNECT
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsSrv.Close()
+	transport := tlsSrv.Client().Transport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	resp, err = (&http.Client{Transport: transport}).Get(tlsSrv.URL)
+	if err != nil {
+		t.Fatalf("https through the proxy returned an error: %v", err)
+	}
+	resp.Body.Close()
+}